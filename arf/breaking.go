@@ -0,0 +1,78 @@
+package arf
+
+import (
+	"errors"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/breaking"
+	"github.com/arf-rpc/idl"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+)
+
+// Breaking reports breaking changes introduced by the IDL file provided as
+// argument over the one provided through --against. When --against points to
+// a directory, such as a checkout of a previous revision, the file is looked
+// up under it using the same relative path.
+func Breaking(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.Exit("exactly one input file must be provided", 1)
+	}
+	current := c.Args().First()
+	against := c.String("against")
+
+	// Failing to read either file must fail the run, as a missing or broken
+	// baseline would otherwise pass as having no breaking changes.
+	stat, err := os.Stat(against)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+	if stat.IsDir() {
+		rel := current
+		if filepath.IsAbs(rel) {
+			wd, err := os.Getwd()
+			if err != nil {
+				return cli.Exit(err, 1)
+			}
+			if rel, err = filepath.Rel(wd, current); err != nil {
+				return cli.Exit(err, 1)
+			}
+		}
+		against = filepath.Join(against, rel)
+	}
+
+	previousTree, err := parseTree(against)
+	if err != nil {
+		return cli.Exit(fmt.Errorf("Error parsing %s: %w", against, err), 1)
+	}
+	currentTree, err := parseTree(current)
+	if err != nil {
+		return cli.Exit(fmt.Errorf("Error parsing %s: %w", current, err), 1)
+	}
+
+	wireOnly := c.Bool("wire-only")
+	found := 0
+	for _, change := range breaking.Compare(previousTree, currentTree) {
+		if wireOnly && !change.Wire {
+			continue
+		}
+		fmt.Println(change)
+		found++
+	}
+	if found > 0 {
+		return cli.Exit(fmt.Sprintf("%d breaking change(s) found", found), 1)
+	}
+	return nil
+}
+
+func parseTree(path string) (*ast.Tree, error) {
+	var errs []error
+	tree, err := idl.ParseFile(path, func(err error) {
+		errs = append(errs, err)
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return tree, err
+}
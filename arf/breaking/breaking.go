@@ -0,0 +1,430 @@
+package breaking
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/idlfmt"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Category identifies the kind of a breaking change.
+type Category string
+
+const (
+	PackageRemoved          Category = "PACKAGE_REMOVED"
+	StructRemoved           Category = "STRUCT_REMOVED"
+	StructIDChanged         Category = "STRUCT_ID_CHANGED"
+	StructRenamed           Category = "STRUCT_RENAMED"
+	FieldRemoved            Category = "FIELD_REMOVED"
+	FieldIDChanged          Category = "FIELD_ID_CHANGED"
+	FieldIDReused           Category = "FIELD_ID_REUSED"
+	FieldRenamed            Category = "FIELD_RENAMED"
+	FieldTypeChanged        Category = "FIELD_TYPE_CHANGED"
+	FieldOptionalityChanged Category = "FIELD_OPTIONALITY_CHANGED"
+	EnumRemoved             Category = "ENUM_REMOVED"
+	EnumMemberRemoved       Category = "ENUM_MEMBER_REMOVED"
+	EnumMemberRenamed       Category = "ENUM_MEMBER_RENAMED"
+	EnumMemberValueChanged  Category = "ENUM_MEMBER_VALUE_CHANGED"
+	ServiceRemoved          Category = "SERVICE_REMOVED"
+	MethodRemoved           Category = "METHOD_REMOVED"
+	MethodParamsChanged     Category = "METHOD_PARAMS_CHANGED"
+	MethodParamRenamed      Category = "METHOD_PARAM_RENAMED"
+	MethodReturnsChanged    Category = "METHOD_RETURNS_CHANGED"
+	MethodStreamChanged     Category = "METHOD_STREAM_CHANGED"
+)
+
+// Change represents a single breaking change between two schemas. Wire
+// indicates that peers built from different versions can no longer
+// interoperate, while Source indicates that code depending on generated
+// sources may no longer compile.
+type Change struct {
+	Category Category
+	Wire     bool
+	Source   bool
+	Message  string
+	Position ast.Position
+}
+
+func (c Change) String() string {
+	var kinds []string
+	if c.Wire {
+		kinds = append(kinds, "wire")
+	}
+	if c.Source {
+		kinds = append(kinds, "source")
+	}
+	return fmt.Sprintf("%s:%d:%d: %s (%s): %s", c.Position.Filename, c.Position.Line, c.Position.Column,
+		c.Category, strings.Join(kinds, ", "), c.Message)
+}
+
+type comparer struct {
+	changes []Change
+}
+
+func (c *comparer) report(cat Category, wire, source bool, pos ast.Position, format string, args ...any) {
+	c.changes = append(c.changes, Change{
+		Category: cat,
+		Wire:     wire,
+		Source:   source,
+		Message:  fmt.Sprintf(format, args...),
+		Position: pos,
+	})
+}
+
+// Compare returns breaking changes introduced by current over previous,
+// sorted by position.
+func Compare(previous, current *ast.Tree) []Change {
+	c := &comparer{}
+	for _, name := range slices.Sorted(maps.Keys(previous.Packages)) {
+		old := previous.Packages[name]
+		cur, ok := current.Packages[name]
+		if !ok {
+			pos := ast.Position{}
+			if len(old.Files) > 0 {
+				pos = old.Files[0].Package.Position
+			}
+			c.report(PackageRemoved, true, true, pos, "package %s was removed", name)
+			continue
+		}
+		c.comparePackage(old, cur)
+	}
+
+	slices.SortStableFunc(c.changes, func(a, b Change) int {
+		if v := strings.Compare(a.Position.Filename, b.Position.Filename); v != 0 {
+			return v
+		}
+		if a.Position.Line != b.Position.Line {
+			return a.Position.Line - b.Position.Line
+		}
+		return a.Position.Column - b.Position.Column
+	})
+	return c.changes
+}
+
+// declarations holds all structs and enums of a package, including nested
+// ones, keyed by their dotted paths.
+type declarations struct {
+	structs map[string]*ast.Struct
+	enums   map[string]*ast.Enum
+}
+
+func collect(t *ast.PackageTree) declarations {
+	d := declarations{structs: map[string]*ast.Struct{}, enums: map[string]*ast.Enum{}}
+	var walk func(structs []ast.Struct, enums []ast.Enum, prefix string)
+	walk = func(structs []ast.Struct, enums []ast.Enum, prefix string) {
+		for i := range enums {
+			d.enums[prefix+enums[i].Name] = &enums[i]
+		}
+		for i := range structs {
+			name := prefix + structs[i].Name
+			d.structs[name] = &structs[i]
+			walk(structs[i].Structs, structs[i].Enums, name+".")
+		}
+	}
+	walk(t.Structures, t.Enums, "")
+	return d
+}
+
+// structID returns the identifier of a struct on the wire, given its dotted
+// path within pkg.
+func structID(pkg, path string) string {
+	names := strings.Split(path, ".")
+	for i, v := range names {
+		names[i] = strcase.ToSnake(v)
+	}
+	return pkg + "/" + strings.Join(names, "/")
+}
+
+func lastComponent(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+func (c *comparer) comparePackage(old, cur *ast.PackageTree) {
+	pkg := old.Package
+	oldDecls, curDecls := collect(old), collect(cur)
+
+	curIDs := map[string]string{}
+	for path := range curDecls.structs {
+		curIDs[structID(pkg, path)] = path
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(oldDecls.structs)) {
+		s := oldDecls.structs[path]
+		if n, ok := curDecls.structs[path]; ok {
+			c.compareStruct(pkg+"."+path, s, n)
+			continue
+		}
+		if renamed, ok := curIDs[structID(pkg, path)]; ok {
+			n := curDecls.structs[renamed]
+			c.report(StructRenamed, false, true, n.Position, "struct %s.%s was renamed to %s; its wire ID is unchanged",
+				pkg, path, renamed)
+			c.compareStruct(pkg+"."+renamed, s, n)
+			continue
+		}
+		if moved := findByName(curDecls.structs, lastComponent(path)); moved != "" {
+			n := curDecls.structs[moved]
+			c.report(StructIDChanged, true, true, n.Position, "struct %s.%s was moved to %s, changing its ID from %s to %s",
+				pkg, path, moved, structID(pkg, path), structID(pkg, moved))
+			continue
+		}
+		c.report(StructRemoved, true, true, s.Position, "struct %s.%s was removed", pkg, path)
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(oldDecls.enums)) {
+		e := oldDecls.enums[path]
+		n, ok := curDecls.enums[path]
+		if !ok {
+			c.report(EnumRemoved, true, true, e.Position, "enum %s.%s was removed", pkg, path)
+			continue
+		}
+		c.compareEnum(pkg+"."+path, e, n)
+	}
+
+	curServices := map[string]*ast.Service{}
+	for i := range cur.Services {
+		curServices[cur.Services[i].Name] = &cur.Services[i]
+	}
+	for i := range old.Services {
+		s := &old.Services[i]
+		n, ok := curServices[s.Name]
+		if !ok {
+			c.report(ServiceRemoved, true, true, s.Position, "service %s.%s was removed", pkg, s.Name)
+			continue
+		}
+		c.compareService(pkg+"."+s.Name, s, n)
+	}
+}
+
+// findByName returns the path of a struct named name in structs, or an empty
+// string in case none exists.
+func findByName(structs map[string]*ast.Struct, name string) string {
+	for _, path := range slices.Sorted(maps.Keys(structs)) {
+		if lastComponent(path) == name {
+			return path
+		}
+	}
+	return ""
+}
+
+func (c *comparer) compareStruct(name string, old, cur *ast.Struct) {
+	curByID := map[int]*ast.StructField{}
+	curByName := map[string]*ast.StructField{}
+	for i := range cur.Fields {
+		curByID[cur.Fields[i].ID] = &cur.Fields[i]
+		curByName[cur.Fields[i].Name] = &cur.Fields[i]
+	}
+
+	for _, f := range old.Fields {
+		n, ok := curByID[f.ID]
+		if !ok {
+			if moved, ok := curByName[f.Name]; ok {
+				c.report(FieldIDChanged, true, false, moved.Position, "field %s of %s changed its ID from %d to %d",
+					f.Name, name, f.ID, moved.ID)
+				continue
+			}
+			// Peers lacking a field that is not optional cannot decode
+			// messages produced by newer ones.
+			_, optional := f.Type.(*ast.OptionalType)
+			c.report(FieldRemoved, !optional, true, cur.Position, "field %s (ID %d) of %s was removed", f.Name, f.ID, name)
+			continue
+		}
+
+		sameType := typeKey(f.Type) == typeKey(n.Type)
+		switch {
+		case f.Name != n.Name && !sameType:
+			c.report(FieldIDReused, true, true, n.Position, "ID %d of %s was reused by field %s %s, previously used by %s %s",
+				f.ID, name, n.Name, idlfmt.TypeName(n.Type), f.Name, idlfmt.TypeName(f.Type))
+			continue
+		case f.Name != n.Name:
+			c.report(FieldRenamed, false, true, n.Position, "field %s (ID %d) of %s was renamed to %s", f.Name, f.ID, name, n.Name)
+		}
+		if sameType {
+			continue
+		}
+		if typeKey(unwrapOptional(f.Type)) == typeKey(unwrapOptional(n.Type)) {
+			c.report(FieldOptionalityChanged, true, true, n.Position, "field %s of %s changed from %s to %s",
+				n.Name, name, idlfmt.TypeName(f.Type), idlfmt.TypeName(n.Type))
+			continue
+		}
+		c.report(FieldTypeChanged, true, true, n.Position, "field %s of %s changed its type from %s to %s",
+			n.Name, name, idlfmt.TypeName(f.Type), idlfmt.TypeName(n.Type))
+	}
+}
+
+func unwrapOptional(t ast.Type) ast.Type {
+	if o, ok := t.(*ast.OptionalType); ok {
+		return o.Type
+	}
+	return t
+}
+
+// typeKey returns a representation of t that only changes when its encoding
+// does. Structs are represented by their wire IDs, and enums by their
+// fully-qualified names.
+func typeKey(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		return v.Name
+	case *ast.OptionalType:
+		return "optional<" + typeKey(v.Type) + ">"
+	case *ast.ArrayType:
+		return "array<" + typeKey(v.Type) + ">"
+	case *ast.MapType:
+		return "map<" + typeKey(v.Key) + "," + typeKey(v.Value) + ">"
+	case *ast.SimpleUserType:
+		return objectKey(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return objectKey(v.ResolvedType)
+	}
+	return "INVALID"
+}
+
+func objectKey(obj ast.Object) string {
+	path := common.ObjectPath(obj)
+	if path == nil {
+		return "INVALID"
+	}
+	pkg := obj.Pos().File.Package.Value
+	switch obj.(type) {
+	case *ast.Struct:
+		return "struct " + structID(pkg, strings.Join(path, "."))
+	default:
+		return "enum " + pkg + "." + strings.Join(path, ".")
+	}
+}
+
+func (c *comparer) compareEnum(name string, old, cur *ast.Enum) {
+	curByName := map[string]*ast.EnumMember{}
+	for i := range cur.Members {
+		curByName[cur.Members[i].Name] = &cur.Members[i]
+	}
+	oldNames := map[string]bool{}
+	for _, m := range old.Members {
+		oldNames[m.Name] = true
+	}
+
+	for _, m := range old.Members {
+		if n, ok := curByName[m.Name]; ok {
+			if n.Value != m.Value {
+				c.report(EnumMemberValueChanged, true, false, n.Position, "member %s of %s changed its value from %d to %d",
+					m.Name, name, m.Value, n.Value)
+			}
+			continue
+		}
+		renamed := ""
+		for _, n := range cur.Members {
+			if n.Value == m.Value && !oldNames[n.Name] {
+				renamed = n.Name
+				break
+			}
+		}
+		if renamed != "" {
+			c.report(EnumMemberRenamed, false, true, curByName[renamed].Position, "member %s (value %d) of %s was renamed to %s",
+				m.Name, m.Value, name, renamed)
+			continue
+		}
+		c.report(EnumMemberRemoved, true, true, cur.Position, "member %s (value %d) of %s was removed", m.Name, m.Value, name)
+	}
+}
+
+// methodShape splits the params or returns of a method into values and an
+// optional stream.
+type methodShape struct {
+	names  []string
+	values []ast.Type
+	stream ast.Type
+}
+
+func paramsShape(m *ast.ServiceMethod) methodShape {
+	var s methodShape
+	for _, p := range m.Params {
+		if p.Stream {
+			s.stream = p.Type
+			continue
+		}
+		name := ""
+		if p.Name != nil {
+			name = *p.Name
+		}
+		s.names = append(s.names, name)
+		s.values = append(s.values, p.Type)
+	}
+	return s
+}
+
+func returnsShape(m *ast.ServiceMethod) methodShape {
+	var s methodShape
+	for _, r := range m.Returns {
+		if r.Stream {
+			s.stream = r.Type
+			continue
+		}
+		s.values = append(s.values, r.Type)
+	}
+	return s
+}
+
+func typeList(types []ast.Type) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = idlfmt.TypeName(t)
+	}
+	return "(" + strings.Join(names, ", ") + ")"
+}
+
+func sameTypes(a, b []ast.Type) bool {
+	return slices.EqualFunc(a, b, func(x, y ast.Type) bool { return typeKey(x) == typeKey(y) })
+}
+
+func streamName(t ast.Type) string {
+	if t == nil {
+		return "no stream"
+	}
+	return "stream " + idlfmt.TypeName(t)
+}
+
+func (c *comparer) compareService(name string, old, cur *ast.Service) {
+	curMethods := map[string]*ast.ServiceMethod{}
+	for _, m := range cur.Methods {
+		curMethods[m.Name] = m
+	}
+
+	for _, m := range old.Methods {
+		n, ok := curMethods[m.Name]
+		if !ok {
+			c.report(MethodRemoved, true, true, cur.Position, "method %s of %s was removed", m.Name, name)
+			continue
+		}
+
+		op, np := paramsShape(m), paramsShape(n)
+		if !sameTypes(op.values, np.values) {
+			c.report(MethodParamsChanged, true, true, n.Position, "parameters of %s.%s changed from %s to %s",
+				name, m.Name, typeList(op.values), typeList(np.values))
+		} else {
+			for i := range op.names {
+				if op.names[i] != np.names[i] {
+					c.report(MethodParamRenamed, false, true, n.Position, "parameter %s of %s.%s was renamed to %s",
+						op.names[i], name, m.Name, np.names[i])
+				}
+			}
+		}
+		if (op.stream == nil) != (np.stream == nil) || op.stream != nil && typeKey(op.stream) != typeKey(np.stream) {
+			c.report(MethodStreamChanged, true, true, n.Position, "input of %s.%s changed from %s to %s",
+				name, m.Name, streamName(op.stream), streamName(np.stream))
+		}
+
+		or, nr := returnsShape(m), returnsShape(n)
+		if !sameTypes(or.values, nr.values) {
+			c.report(MethodReturnsChanged, true, true, n.Position, "return values of %s.%s changed from %s to %s",
+				name, m.Name, typeList(or.values), typeList(nr.values))
+		}
+		if (or.stream == nil) != (nr.stream == nil) || or.stream != nil && typeKey(or.stream) != typeKey(nr.stream) {
+			c.report(MethodStreamChanged, true, true, n.Position, "output of %s.%s changed from %s to %s",
+				name, m.Name, streamName(or.stream), streamName(nr.stream))
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package arf
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+const breakingSchema = `package org.example.test;
+
+enum Size {
+    SMALL = 0;
+    LARGE = 1;
+}
+
+struct Item {
+    name string = 0;
+    size Size   = 1;
+}
+
+service Items {
+    get(name string) -> Item;
+    remove(name string);
+}
+`
+
+// runBreaking runs the breaking command with args, returning its exit code
+// along with the changes it reported.
+func runBreaking(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	app := &cli.App{
+		// Exit codes are returned instead of terminating the test binary.
+		ExitErrHandler: func(*cli.Context, error) {},
+		Writer:         os.Stderr,
+		Commands: []*cli.Command{{
+			Name: "breaking",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "against", Required: true},
+				&cli.BoolFlag{Name: "wire-only"},
+			},
+			Action: Breaking,
+		}},
+	}
+
+	// Changes are printed to stdout.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	err = app.Run(append([]string{"arfc", "breaking"}, args...))
+	os.Stdout = stdout
+	_ = w.Close()
+	reported := <-output
+
+	if err == nil {
+		return 0, reported
+	}
+	var exit cli.ExitCoder
+	if !errors.As(err, &exit) {
+		t.Fatalf("expected an exit error, got %v", err)
+	}
+	return exit.ExitCode(), reported
+}
+
+func writeSchema(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreakingExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeSchema(t, dir, "valid.arf", breakingSchema)
+	broken := writeSchema(t, dir, "broken.arf", "package org.example.test;\n\nstruct Item {\n")
+	changed := func(name, old, new string) string {
+		t.Helper()
+		if !strings.Contains(breakingSchema, old) {
+			t.Fatalf("%q not found in schema", old)
+		}
+		return writeSchema(t, dir, name, strings.Replace(breakingSchema, old, new, 1))
+	}
+	typeChanged := changed("type.arf", "name string = 0", "name int32 = 0")
+	memberRemoved := changed("member.arf", "    LARGE = 1;\n", "")
+	methodRemoved := changed("method.arf", "    remove(name string);\n", "")
+	idChanged := changed("id.arf", "name string = 0", "name string = 2")
+	renamed := changed("renamed.arf", "name string = 0", "title string = 0")
+	added := changed("added.arf", "size Size   = 1;", "size Size   = 1;\n    note optional<string> = 2;")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expects  int
+		category string
+	}{
+		{"unchanged", []string{"--against", valid, valid}, 0, ""},
+		{"missing baseline", []string{"--against", filepath.Join(dir, "missing.arf"), valid}, 1, ""},
+		{"missing baseline directory", []string{"--against", filepath.Join(dir, "missing"), valid}, 1, ""},
+		{"broken baseline", []string{"--against", broken, valid}, 1, ""},
+		{"broken input", []string{"--against", valid, broken}, 1, ""},
+		{"field type changed", []string{"--against", valid, typeChanged}, 1, "FIELD_TYPE_CHANGED"},
+		{"enum member removed", []string{"--against", valid, memberRemoved}, 1, "ENUM_MEMBER_REMOVED"},
+		{"method removed", []string{"--against", valid, methodRemoved}, 1, "METHOD_REMOVED"},
+		{"field ID changed", []string{"--against", valid, idChanged}, 1, "FIELD_ID_CHANGED"},
+		{"field ID changed on the wire", []string{"--wire-only", "--against", valid, idChanged}, 1, "FIELD_ID_CHANGED"},
+		{"field renamed", []string{"--against", valid, renamed}, 1, "FIELD_RENAMED"},
+		{"field renamed on the wire", []string{"--wire-only", "--against", valid, renamed}, 0, ""},
+		{"optional field added", []string{"--against", valid, added}, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reported := runBreaking(t, tt.args...)
+			if code != tt.expects {
+				t.Errorf("expected exit code %d, got %d", tt.expects, code)
+			}
+			if tt.category == "" {
+				if reported != "" {
+					t.Errorf("expected no changes to be reported, got %q", reported)
+				}
+				return
+			}
+			lines := strings.Split(strings.TrimSpace(reported), "\n")
+			if len(lines) != 1 || !strings.Contains(lines[0], " "+tt.category+" ") {
+				t.Errorf("expected a single %s change to be reported, got %q", tt.category, reported)
+			}
+		})
+	}
+}
@@ -5,3 +5,16 @@ import "github.com/urfave/cli/v2"
 type Generator interface {
 	GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string)
 }
+
+// File represents a single file emitted by a MultiFileGenerator.
+type File struct {
+	Data       []byte
+	TargetDir  string
+	TargetFile string
+}
+
+// MultiFileGenerator is implemented by generators that emit more than one
+// file for each package.
+type MultiFileGenerator interface {
+	GenFiles(ctx *cli.Context) []File
+}
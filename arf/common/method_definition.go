@@ -23,6 +23,9 @@ func PackageName(t ast.Object) string {
 	return comps[len(comps)-1]
 }
 
+// ConvertType returns the Go type representing t, as used by the Go
+// generator, its only caller. resolver is called with the name and package of
+// user types, returning the qualifier to prefix them with, if any.
 func ConvertType(t ast.Type, resolver PackageResolver) string {
 	switch v := t.(type) {
 	case *ast.PrimitiveType:
@@ -41,11 +44,19 @@ func ConvertType(t ast.Type, resolver PackageResolver) string {
 	case *ast.MapType:
 		return fmt.Sprintf("map[%s]%s", ConvertType(v.Key, resolver), ConvertType(v.Value, resolver))
 	case *ast.SimpleUserType:
+		// Simple types are declared within the package using them, which
+		// resolvers qualify when generating code outside of it.
+		prefix := ""
+		if v.ResolvedType != nil && resolver != nil {
+			if p := resolver(v.Name, v.ResolvedType.Pos().File.BaseFQN()); p != "" {
+				prefix = p + "."
+			}
+		}
 		switch a := v.ResolvedType.(type) {
 		case *ast.Enum:
-			return EnumName(a)
+			return prefix + EnumName(a)
 		case *ast.Struct:
-			return StructName(a)
+			return prefix + StructName(a)
 		default:
 			return "INVALID"
 		}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"github.com/arf-rpc/idl/ast"
+	"strings"
+)
+
+// objectKey identifies a struct or enum across the copies held by the
+// files referring to it.
+func objectKey(obj ast.Object) string {
+	pos := obj.Pos()
+	if pos == nil || pos.File == nil {
+		return ""
+	}
+	return pos.File.Package.Value + "/" + strings.Join(ObjectPath(obj), ".")
+}
+
+// RefersTo reports whether values of t may hold a value of s, either directly
+// or through the fields of the structs t refers to. Fields of s for which it
+// holds make s a recursive type.
+func RefersTo(t ast.Type, s *ast.Struct) bool {
+	target := objectKey(s)
+	seen := map[string]bool{}
+	var walk func(t ast.Type) bool
+	visit := func(obj ast.Object) bool {
+		st, ok := obj.(*ast.Struct)
+		if !ok {
+			return false
+		}
+		key := objectKey(st)
+		if key == target {
+			return true
+		}
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		for _, f := range st.Fields {
+			if walk(f.Type) {
+				return true
+			}
+		}
+		return false
+	}
+	walk = func(t ast.Type) bool {
+		switch v := t.(type) {
+		case *ast.OptionalType:
+			return walk(v.Type)
+		case *ast.ArrayType:
+			return walk(v.Type)
+		case *ast.MapType:
+			return walk(v.Key) || walk(v.Value)
+		case *ast.SimpleUserType:
+			return visit(v.ResolvedType)
+		case *ast.FullQualifiedType:
+			return visit(v.ResolvedType)
+		}
+		return false
+	}
+	return walk(t)
+}
@@ -20,3 +20,56 @@ func CanonicalStructName(pkg string, s *ast.Struct) string {
 	}
 	return pkg + "/" + strings.Join(names, "/")
 }
+
+// ObjectPath returns the name of a given struct or enum, preceded by the names
+// of all structs enclosing it. Paths are obtained by locating the object
+// within the file declaring it, as parents of nested objects may not be
+// complete. Returns nil if the object cannot be found.
+func ObjectPath(obj ast.Object) []string {
+	pos := obj.Pos()
+	if pos == nil || pos.File == nil {
+		return nil
+	}
+	same := func(p ast.Position) bool {
+		return p.Line == pos.Line && p.Column == pos.Column
+	}
+	var walk func(structs []ast.Struct, enums []ast.Enum, path []string) []string
+	walk = func(structs []ast.Struct, enums []ast.Enum, path []string) []string {
+		for _, e := range enums {
+			if same(e.Position) {
+				return append(slices.Clone(path), e.Name)
+			}
+		}
+		for _, s := range structs {
+			p := append(slices.Clone(path), s.Name)
+			if same(s.Position) {
+				return p
+			}
+			if found := walk(s.Structs, s.Enums, p); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return walk(pos.File.Structs, pos.File.Enums, nil)
+}
+
+// PathName returns the name of a given struct or enum in PascalCase, prefixed
+// by the names of all structs enclosing it (e.g. InvoiceLineKind), for
+// languages declaring nested types at the top level.
+func PathName(obj ast.Object) string {
+	path := ObjectPath(obj)
+	if path == nil {
+		switch v := obj.(type) {
+		case *ast.Struct:
+			return StructName(v)
+		case *ast.Enum:
+			return EnumName(v)
+		}
+		return ""
+	}
+	for i, v := range path {
+		path[i] = strcase.ToCamel(v)
+	}
+	return strings.Join(path, "")
+}
@@ -6,6 +6,10 @@ import (
 )
 
 type Writer struct {
+	// Indentation is the string emitted for each indentation level. Defaults
+	// to two spaces when empty.
+	Indentation string
+
 	lines       []string
 	indentLevel int
 	needsIndent bool
@@ -17,7 +21,11 @@ func (w *Writer) indent() {
 	if !w.needsIndent {
 		return
 	}
-	w.lines = append(w.lines, strings.Repeat("  ", w.indentLevel))
+	indentation := w.Indentation
+	if indentation == "" {
+		indentation = "  "
+	}
+	w.lines = append(w.lines, strings.Repeat(indentation, w.indentLevel))
 	w.needsIndent = false
 }
 
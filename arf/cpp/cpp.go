@@ -0,0 +1,684 @@
+package cpp
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.MultiFileGenerator {
+	return &Generator{
+		t:  tree,
+		h:  &common.Writer{},
+		cc: &common.Writer{},
+	}
+}
+
+type Generator struct {
+	t  *ast.PackageTree
+	h  *common.Writer
+	cc *common.Writer
+
+	includes []string
+}
+
+var keywords = map[string]struct{}{
+	"alignas": {}, "alignof": {}, "and": {}, "asm": {}, "auto": {},
+	"bool": {}, "break": {}, "case": {}, "catch": {}, "char": {},
+	"class": {}, "concept": {}, "const": {}, "consteval": {}, "constexpr": {},
+	"continue": {}, "co_await": {}, "co_return": {}, "co_yield": {},
+	"decltype": {}, "default": {}, "delete": {}, "do": {}, "double": {},
+	"else": {}, "enum": {}, "explicit": {}, "export": {}, "extern": {},
+	"false": {}, "float": {}, "for": {}, "friend": {}, "goto": {}, "if": {},
+	"inline": {}, "int": {}, "long": {}, "mutable": {}, "namespace": {},
+	"new": {}, "noexcept": {}, "not": {}, "nullptr": {}, "operator": {},
+	"or": {}, "private": {}, "protected": {}, "public": {}, "register": {},
+	"requires": {}, "return": {}, "short": {}, "signed": {}, "sizeof": {},
+	"static": {}, "struct": {}, "switch": {}, "template": {}, "this": {},
+	"throw": {}, "true": {}, "try": {}, "typedef": {}, "typeid": {},
+	"typename": {}, "union": {}, "unsigned": {}, "using": {}, "virtual": {},
+	"void": {}, "volatile": {}, "while": {}, "xor": {}, "ctx": {},
+}
+
+func identifier(name string) string {
+	name = strcase.ToSnake(name)
+	if _, ok := keywords[name]; ok {
+		return name + "_"
+	}
+	return name
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Namespace returns the C++ namespace for a given arf package.
+func Namespace(pkg string) string {
+	comps := strings.Split(pkg, ".")
+	for i, c := range comps {
+		comps[i] = identifier(c)
+	}
+	return strings.Join(comps, "::")
+}
+
+// HeaderPath returns the path of the header generated for a given arf
+// package, relative to the output directory.
+func HeaderPath(pkg string) string {
+	comps := strings.Split(pkg, ".")
+	for i, c := range comps {
+		comps[i] = strcase.ToSnake(c)
+	}
+	return filepath.Join(comps...) + ".arf.h"
+}
+
+func (g *Generator) GenFiles(ctx *cli.Context) []common.File {
+	header := HeaderPath(g.t.Package)
+	targetDir := filepath.Join(ctx.String("output"), filepath.Dir(header))
+	base := strings.TrimSuffix(filepath.Base(header), ".h")
+
+	ns := Namespace(g.t.Package)
+	g.h.Break()
+	g.h.Writelnf("namespace %s {", ns)
+	g.cc.Writelnf("// Code generated by arfc. DO NOT EDIT.")
+	g.cc.Break()
+	g.cc.Writelnf("#include %s", quote(filepath.ToSlash(header)))
+	g.cc.Break()
+	g.cc.Writelnf("#include <utility>")
+	g.cc.Break()
+	g.cc.Writelnf("namespace %s {", ns)
+
+	var enums []*ast.Enum
+	var structs []*ast.Struct
+	for i := range g.t.Enums {
+		enums = append(enums, &g.t.Enums[i])
+	}
+	for i := range g.t.Structures {
+		collectStruct(&g.t.Structures[i], &structs, &enums)
+	}
+
+	for _, e := range enums {
+		g.makeEnum(e)
+	}
+
+	if len(structs) > 0 {
+		g.h.Break()
+		for _, s := range structs {
+			g.h.Writelnf("struct %s;", common.StructName(s))
+		}
+	}
+	for _, s := range g.sortStructs(structs) {
+		g.makeStruct(s)
+	}
+
+	for i := range g.t.Services {
+		g.makeService(&g.t.Services[i])
+		g.makeClient(&g.t.Services[i])
+	}
+
+	g.h.Break()
+	g.h.Writelnf("}  // namespace %s", ns)
+	g.h.Merge(g.makeHeader())
+	g.cc.Break()
+	g.cc.Writelnf("}  // namespace %s", ns)
+
+	return []common.File{
+		{Data: []byte(g.h.String()), TargetDir: targetDir, TargetFile: base + ".h"},
+		{Data: []byte(g.cc.String()), TargetDir: targetDir, TargetFile: base + ".cc"},
+		{
+			Data:       []byte(transport),
+			TargetDir:  filepath.Join(ctx.String("output"), filepath.Dir(transportHeader)),
+			TargetFile: filepath.Base(transportHeader),
+		},
+	}
+}
+
+func (g *Generator) makeHeader() *common.Writer {
+	w := &common.Writer{}
+	w.Writelnf("// Code generated by arfc. DO NOT EDIT.")
+	w.Break()
+	w.Writelnf("#pragma once")
+	w.Break()
+	w.Writelnf("#include <chrono>")
+	w.Writelnf("#include <cstdint>")
+	w.Writelnf("#include <memory>")
+	w.Writelnf("#include <optional>")
+	w.Writelnf("#include <string>")
+	w.Writelnf("#include <string_view>")
+	w.Writelnf("#include <unordered_map>")
+	w.Writelnf("#include <vector>")
+	w.Break()
+	w.Writelnf("#include %s", quote(transportHeader))
+	if len(g.includes) > 0 {
+		w.Break()
+	}
+	for _, pkg := range g.includes {
+		w.Writelnf("#include %s", quote(filepath.ToSlash(HeaderPath(pkg))))
+	}
+	return w
+}
+
+// collectStruct flattens s and its nested types into structs and enums,
+// placing nested types before their parents.
+func collectStruct(s *ast.Struct, structs *[]*ast.Struct, enums *[]*ast.Enum) {
+	for i := range s.Enums {
+		*enums = append(*enums, &s.Enums[i])
+	}
+	for i := range s.Structs {
+		collectStruct(&s.Structs[i], structs, enums)
+	}
+	*structs = append(*structs, s)
+}
+
+// sortStructs orders structs so that every struct is defined after the
+// structs of the same package it depends on. Boxed fields do not require
+// their type to be defined, and remaining dependency cycles are broken by
+// keeping the declaration order.
+func (g *Generator) sortStructs(structs []*ast.Struct) []*ast.Struct {
+	byName := map[string]*ast.Struct{}
+	for _, s := range structs {
+		byName[common.StructName(s)] = s
+	}
+	var sorted []*ast.Struct
+	visited := map[*ast.Struct]bool{}
+	var visit func(s *ast.Struct)
+	visit = func(s *ast.Struct) {
+		if visited[s] {
+			return
+		}
+		visited[s] = true
+		for _, f := range s.Fields {
+			if g.boxed(s, f.Type) {
+				continue
+			}
+			for _, dep := range g.localDependencies(f.Type) {
+				if d, ok := byName[common.StructName(dep)]; ok {
+					visit(d)
+				}
+			}
+		}
+		sorted = append(sorted, s)
+	}
+	for _, s := range structs {
+		visit(s)
+	}
+	return sorted
+}
+
+func (g *Generator) structDependencies(t ast.Type) []*ast.Struct {
+	switch v := t.(type) {
+	case *ast.OptionalType:
+		return g.structDependencies(v.Type)
+	case *ast.ArrayType:
+		return g.structDependencies(v.Type)
+	case *ast.MapType:
+		return append(g.structDependencies(v.Key), g.structDependencies(v.Value)...)
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		if s, ok := resolved(v).(*ast.Struct); ok {
+			return []*ast.Struct{s}
+		}
+	}
+	return nil
+}
+
+// localDependencies returns the structs of the generated package t refers
+// to.
+func (g *Generator) localDependencies(t ast.Type) []*ast.Struct {
+	var deps []*ast.Struct
+	for _, dep := range g.structDependencies(t) {
+		if dep.Position.File.Package.Value == g.t.Package {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// boxed reports whether a field of s with type t is held by an ::arf::Box
+// instead of a std::optional, as is the case for optional structs referring
+// back to s, which cannot be complete where s is defined.
+func (g *Generator) boxed(s *ast.Struct, t ast.Type) bool {
+	opt, ok := t.(*ast.OptionalType)
+	if !ok {
+		return false
+	}
+	target, ok := resolved(opt.Type).(*ast.Struct)
+	if !ok || target.Position.File.Package.Value != g.t.Package {
+		return false
+	}
+	name := common.StructName(s)
+	seen := map[string]bool{}
+	var reaches func(d *ast.Struct) bool
+	reaches = func(d *ast.Struct) bool {
+		n := common.StructName(d)
+		if n == name {
+			return true
+		}
+		if seen[n] {
+			return false
+		}
+		seen[n] = true
+		for _, f := range d.Fields {
+			for _, dep := range g.localDependencies(f.Type) {
+				if reaches(dep) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return reaches(target)
+}
+
+func (g *Generator) requirePackage(pkg string) {
+	if !slices.Contains(g.includes, pkg) {
+		g.includes = append(g.includes, pkg)
+	}
+}
+
+func (g *Generator) writeComments(c []string) {
+	for _, c := range c {
+		g.h.Writelnf("//%s", c)
+	}
+}
+
+func deprecation(set ast.AnnotationSet) string {
+	ann := set.ByName("deprecated")
+	if ann == nil {
+		return ""
+	}
+	if len(ann.Arguments) == 0 {
+		return "[[deprecated]]"
+	}
+	return fmt.Sprintf("[[deprecated(%s)]]", quote(fmt.Sprint(ann.Arguments[0])))
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	name := common.EnumName(e)
+	g.h.Break()
+	g.writeComments(e.Comment)
+	g.h.Writef("enum class ")
+	if dep := deprecation(e.Annotations); dep != "" {
+		g.h.Writef("%s ", dep)
+	}
+	g.h.Writelnf("%s : int32_t {", name)
+	g.h.IncreaseIndent()
+	for _, v := range e.Members {
+		g.writeComments(v.Comment)
+		g.h.Writef("k%s", strcase.ToCamel(v.Name))
+		if dep := deprecation(v.Annotations); dep != "" {
+			g.h.Writef(" %s", dep)
+		}
+		g.h.Writelnf(" = %d,", v.Value)
+	}
+	g.h.DecreaseIndent()
+	g.h.Writelnf("};")
+	g.h.Break()
+	g.h.Writelnf("// Returns the name of the first member of %s declared with the", name)
+	g.h.Writelnf("// value of v, or an empty string_view in case none matches.")
+	g.h.Writelnf("std::string_view ToString(%s v);", name)
+
+	g.cc.Break()
+	g.cc.Writelnf("std::string_view ToString(%s v) {", name)
+	g.cc.IncreaseIndent()
+	g.cc.Writelnf("switch (static_cast<int32_t>(v)) {")
+	g.cc.IncreaseIndent()
+	seen := map[int]bool{}
+	for _, v := range e.Members {
+		if seen[v.Value] {
+			continue
+		}
+		seen[v.Value] = true
+		g.cc.Writelnf("case %d:", v.Value)
+		g.cc.IncreaseIndent()
+		g.cc.Writelnf("return %s;", quote(v.Name))
+		g.cc.DecreaseIndent()
+	}
+	g.cc.DecreaseIndent()
+	g.cc.Writelnf("}")
+	g.cc.Writelnf("return {};")
+	g.cc.DecreaseIndent()
+	g.cc.Writelnf("}")
+}
+
+func (g *Generator) makeStruct(s *ast.Struct) {
+	name := common.StructName(s)
+	g.h.Break()
+	g.writeComments(s.Comment)
+	g.h.Writef("struct ")
+	if dep := deprecation(s.Annotations); dep != "" {
+		g.h.Writef("%s ", dep)
+	}
+	g.h.Writelnf("%s {", name)
+	g.h.IncreaseIndent()
+	g.h.Writelnf("static constexpr std::string_view kArfStructId = %s;", quote(common.CanonicalStructName(g.t.Package, s)))
+
+	for _, f := range s.Fields {
+		g.h.Break()
+		g.writeComments(f.Comment)
+		if dep := deprecation(f.Annotations); dep != "" {
+			g.h.Writef("%s ", dep)
+		}
+		typ := g.convertType(f.Type)
+		if g.boxed(s, f.Type) {
+			typ = fmt.Sprintf("::arf::Box<%s>", g.convertType(f.Type.(*ast.OptionalType).Type))
+		}
+		g.h.Writelnf("%s %s%s;", typ, identifier(f.Name), g.defaultValue(f.Type))
+	}
+
+	// Field visitors allow the runtime to (de)serialize structures by field
+	// ID without relying on reflection.
+	for _, constness := range []string{"", " const"} {
+		g.h.Break()
+		g.h.Writelnf("template <typename Visitor>")
+		if len(s.Fields) == 0 {
+			g.h.Writelnf("void ArfVisitFields(Visitor&&)%s {}", constness)
+			continue
+		}
+		g.h.Writelnf("void ArfVisitFields(Visitor&& visitor)%s {", constness)
+		g.h.IncreaseIndent()
+		for _, f := range s.Fields {
+			g.h.Writelnf("visitor(%d, %s, %s);", f.ID, quote(f.Name), identifier(f.Name))
+		}
+		g.h.DecreaseIndent()
+		g.h.Writelnf("}")
+	}
+
+	g.h.Break()
+	g.h.Writelnf("bool operator==(const %s&) const = default;", name)
+	g.h.DecreaseIndent()
+	g.h.Writelnf("};")
+}
+
+func (g *Generator) defaultValue(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string", "bytes", "timestamp":
+			return ""
+		case "bool":
+			return " = false"
+		default:
+			return " = 0"
+		}
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		if e, ok := resolved(v).(*ast.Enum); ok {
+			return fmt.Sprintf(" = %s::k%s", g.convertType(v), strcase.ToCamel(e.Members[0].Name))
+		}
+	}
+	return ""
+}
+
+type method struct {
+	m            *ast.ServiceMethod
+	name         string
+	params       []*ast.MethodParam
+	outputs      []ast.Type
+	inputStream  ast.Type
+	outputStream ast.Type
+}
+
+func (g *Generator) makeMethod(m *ast.ServiceMethod) *method {
+	def := &method{m: m, name: strcase.ToCamel(m.Name)}
+	for _, p := range m.Params {
+		if p.Stream {
+			def.inputStream = p.Type
+			continue
+		}
+		def.params = append(def.params, p)
+	}
+	for _, r := range m.Returns {
+		if r.Stream {
+			def.outputStream = r.Type
+			continue
+		}
+		def.outputs = append(def.outputs, r.Type)
+	}
+	return def
+}
+
+func (g *Generator) outputTypes(m *method) []string {
+	var types []string
+	for _, o := range m.outputs {
+		types = append(types, g.convertType(o))
+	}
+	return types
+}
+
+// serverSignature returns the parameter list of a method as implemented by
+// services. Unary outputs are written through pointers, unless the method
+// also streams responses, in which case they are provided to the writer's
+// Respond method before any item is written.
+func (g *Generator) serverSignature(m *method) string {
+	args := []string{"::arf::ServerContext& ctx"}
+	for _, p := range m.params {
+		args = append(args, g.paramDecl(p.Type, identifier(*p.Name)))
+	}
+	if m.inputStream != nil {
+		args = append(args, fmt.Sprintf("::arf::ServerReader<%s>& requests", g.convertType(m.inputStream)))
+	}
+	if m.outputStream != nil {
+		targs := append([]string{g.convertType(m.outputStream)}, g.outputTypes(m)...)
+		args = append(args, fmt.Sprintf("::arf::ServerWriter<%s>& responses", strings.Join(targs, ", ")))
+	} else {
+		for i, o := range g.outputTypes(m) {
+			args = append(args, fmt.Sprintf("%s* out%d", o, i))
+		}
+	}
+	return strings.Join(args, ", ")
+}
+
+// clientSignature returns the parameter list of a method as invoked by
+// clients. Unary outputs of methods taking an input stream are obtained
+// through the writer's Finish method once all items have been written.
+func (g *Generator) clientSignature(m *method) string {
+	args := []string{"::arf::ClientContext& ctx"}
+	for _, p := range m.params {
+		args = append(args, g.paramDecl(p.Type, identifier(*p.Name)))
+	}
+	if m.inputStream != nil {
+		targs := append([]string{g.convertType(m.inputStream)}, g.outputTypes(m)...)
+		args = append(args, fmt.Sprintf("std::unique_ptr<::arf::ClientWriter<%s>>* requests", strings.Join(targs, ", ")))
+	} else {
+		for i, o := range g.outputTypes(m) {
+			args = append(args, fmt.Sprintf("%s* out%d", o, i))
+		}
+	}
+	if m.outputStream != nil {
+		args = append(args, fmt.Sprintf("std::unique_ptr<::arf::ClientReader<%s>>* responses", g.convertType(m.outputStream)))
+	}
+	return strings.Join(args, ", ")
+}
+
+func (g *Generator) paramDecl(t ast.Type, name string) string {
+	if isScalar(t) {
+		return fmt.Sprintf("%s %s", g.convertType(t), name)
+	}
+	return fmt.Sprintf("const %s& %s", g.convertType(t), name)
+}
+
+func (g *Generator) makeService(s *ast.Service) {
+	name := strcase.ToCamel(s.Name)
+	g.h.Break()
+	g.writeComments(s.Comment)
+	g.h.Writef("class ")
+	if dep := deprecation(s.Annotations); dep != "" {
+		g.h.Writef("%s ", dep)
+	}
+	g.h.Writelnf("%s {", name)
+	g.h.Writelnf(" public:")
+	g.h.IncreaseIndent()
+	g.h.Writelnf("static constexpr std::string_view kArfServiceId = %s;", quote(fmt.Sprintf("%s/%s", g.t.Package, s.Name)))
+	g.h.Break()
+	g.h.Writelnf("virtual ~%s() = default;", name)
+	for _, m := range s.Methods {
+		def := g.makeMethod(m)
+		g.h.Break()
+		g.writeComments(m.Comment)
+		if dep := deprecation(m.Annotations); dep != "" {
+			g.h.Writelnf("%s", dep)
+		}
+		g.h.Writelnf("virtual ::arf::Status %s(%s) = 0;", def.name, g.serverSignature(def))
+	}
+	g.h.DecreaseIndent()
+	g.h.Writelnf("};")
+}
+
+func (g *Generator) makeClient(s *ast.Service) {
+	service := strcase.ToCamel(s.Name)
+	name := service + "Client"
+	g.h.Break()
+	g.writeComments(s.Comment)
+	g.h.Writelnf("class %s {", name)
+	g.h.Writelnf(" public:")
+	g.h.IncreaseIndent()
+	g.h.Writelnf("explicit %s(std::shared_ptr<::arf::Channel> channel);", name)
+
+	g.cc.Break()
+	g.cc.Writelnf("%s::%s(std::shared_ptr<::arf::Channel> channel)", name, name)
+	g.cc.Writelnf("    : channel_(std::move(channel)) {}")
+
+	for _, m := range s.Methods {
+		def := g.makeMethod(m)
+		g.h.Break()
+		g.writeComments(m.Comment)
+		if dep := deprecation(m.Annotations); dep != "" {
+			g.h.Writelnf("%s", dep)
+		}
+		g.h.Writelnf("::arf::Status %s(%s);", def.name, g.clientSignature(def))
+		g.makeClientMethod(name, service, def)
+	}
+
+	g.h.DecreaseIndent()
+	g.h.Break()
+	g.h.Writelnf(" private:")
+	g.h.IncreaseIndent()
+	g.h.Writelnf("std::shared_ptr<::arf::Channel> channel_;")
+	g.h.DecreaseIndent()
+	g.h.Writelnf("};")
+}
+
+func (g *Generator) makeClientMethod(client, service string, m *method) {
+	streaming := m.inputStream != nil || m.outputStream != nil
+	g.cc.Break()
+	g.cc.Writelnf("::arf::Status %s::%s(%s) {", client, m.name, g.clientSignature(m))
+	g.cc.IncreaseIndent()
+	g.cc.Writelnf("auto call = channel_->StartCall(ctx, %s::kArfServiceId, %s, /*streaming=*/%t);", service, quote(m.m.Name), streaming)
+	var params []string
+	for _, p := range m.params {
+		params = append(params, identifier(*p.Name))
+	}
+	g.cc.Writelnf("if (auto status = call->SendParams(%s); !status.ok()) {", strings.Join(params, ", "))
+	g.cc.IncreaseIndent()
+	g.cc.Writelnf("return status;")
+	g.cc.DecreaseIndent()
+	g.cc.Writelnf("}")
+
+	if m.inputStream != nil {
+		targs := append([]string{g.convertType(m.inputStream)}, g.outputTypes(m)...)
+		g.cc.Writelnf("*requests = call->MakeWriter<%s>();", strings.Join(targs, ", "))
+	} else if len(m.outputs) > 0 || m.outputStream == nil {
+		var outs []string
+		for i := range m.outputs {
+			outs = append(outs, fmt.Sprintf("out%d", i))
+		}
+		if m.outputStream == nil {
+			g.cc.Writelnf("return call->ReceiveResult(%s);", strings.Join(outs, ", "))
+			g.cc.DecreaseIndent()
+			g.cc.Writelnf("}")
+			return
+		}
+		g.cc.Writelnf("if (auto status = call->ReceiveResult(%s); !status.ok()) {", strings.Join(outs, ", "))
+		g.cc.IncreaseIndent()
+		g.cc.Writelnf("return status;")
+		g.cc.DecreaseIndent()
+		g.cc.Writelnf("}")
+	}
+	if m.outputStream != nil {
+		g.cc.Writelnf("*responses = call->MakeReader<%s>();", g.convertType(m.outputStream))
+	}
+	g.cc.Writelnf("return ::arf::Status::OK();")
+	g.cc.DecreaseIndent()
+	g.cc.Writelnf("}")
+}
+
+func resolved(t ast.Type) ast.Object {
+	switch v := t.(type) {
+	case *ast.SimpleUserType:
+		return v.ResolvedType
+	case *ast.FullQualifiedType:
+		return v.ResolvedType
+	}
+	return nil
+}
+
+// isScalar reports whether values of t are cheap to copy, and are therefore
+// passed by value.
+func isScalar(t ast.Type) bool {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string", "bytes":
+			return false
+		}
+		return true
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		return !common.IsUserType(v)
+	}
+	return false
+}
+
+func (g *Generator) userTypeName(obj ast.Object) string {
+	var name, pkg string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name, pkg = common.StructName(v), v.Position.File.Package.Value
+	case *ast.Enum:
+		name, pkg = common.EnumName(v), v.Position.File.Package.Value
+	default:
+		return "INVALID"
+	}
+	if pkg != g.t.Package {
+		g.requirePackage(pkg)
+		return "::" + Namespace(pkg) + "::" + name
+	}
+	return name
+}
+
+func (g *Generator) convertType(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			return "std::string"
+		case "bool":
+			return "bool"
+		case "float32":
+			return "float"
+		case "float64":
+			return "double"
+		case "bytes":
+			return "std::vector<uint8_t>"
+		case "timestamp":
+			return "std::chrono::system_clock::time_point"
+		default:
+			return v.Name + "_t"
+		}
+	case *ast.OptionalType:
+		return fmt.Sprintf("std::optional<%s>", g.convertType(v.Type))
+	case *ast.ArrayType:
+		return fmt.Sprintf("std::vector<%s>", g.convertType(v.Type))
+	case *ast.MapType:
+		return fmt.Sprintf("std::unordered_map<%s, %s>", g.convertType(v.Key), g.convertType(v.Value))
+	case *ast.SimpleUserType:
+		return g.userTypeName(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return g.userTypeName(v.ResolvedType)
+	default:
+		return "INVALID"
+	}
+}
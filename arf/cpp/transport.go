@@ -0,0 +1,507 @@
+package cpp
+
+// transportHeader is the path of the header declaring the types generated
+// code relies on, relative to the output directory.
+const transportHeader = "arf/arf.h"
+
+// transport declares the types generated services and clients rely on.
+// Applications implement Channel and Call over the network stack of their
+// choice, encoding values through Encoder and Decoder.
+const transport = `// Code generated by arfc. DO NOT EDIT.
+
+#pragma once
+
+#include <chrono>
+#include <concepts>
+#include <cstddef>
+#include <cstdint>
+#include <functional>
+#include <memory>
+#include <optional>
+#include <string>
+#include <string_view>
+#include <type_traits>
+#include <unordered_map>
+#include <utility>
+#include <vector>
+
+namespace arf {
+
+// Status is the outcome of a call. Code 0 denotes success, while other codes
+// are defined by the transport.
+class Status {
+ public:
+  Status() = default;
+  Status(int32_t code, std::string message)
+      : code_(code), message_(std::move(message)) {}
+
+  static Status OK() { return Status(); }
+
+  bool ok() const { return code_ == 0; }
+  int32_t code() const { return code_; }
+  const std::string& message() const { return message_; }
+
+ private:
+  int32_t code_ = 0;
+  std::string message_;
+};
+
+// Metadata holds values sent along with calls and their responses.
+using Metadata = std::unordered_map<std::string, std::vector<uint8_t>>;
+
+// ClientContext holds the metadata a call is sent with, and receives the
+// metadata of its response.
+struct ClientContext {
+  Metadata metadata;
+  Metadata response_metadata;
+};
+
+// ServerContext holds the metadata a call was received with, and the
+// metadata its response is sent with.
+struct ServerContext {
+  Metadata metadata;
+  Metadata response_metadata;
+};
+
+// Box holds an optional value of a type that may still be incomplete where
+// the Box is declared, such as a struct referring to itself.
+template <typename T>
+class Box {
+ public:
+  Box() = default;
+  Box(std::nullopt_t) {}
+  Box(T value) : value_(std::make_unique<T>(std::move(value))) {}
+  Box(const Box& other)
+      : value_(other.value_ ? std::make_unique<T>(*other.value_) : nullptr) {}
+  Box(Box&&) noexcept = default;
+  Box& operator=(const Box& other) {
+    value_ = other.value_ ? std::make_unique<T>(*other.value_) : nullptr;
+    return *this;
+  }
+  Box& operator=(Box&&) noexcept = default;
+
+  bool has_value() const { return value_ != nullptr; }
+  explicit operator bool() const { return has_value(); }
+  T& operator*() { return *value_; }
+  const T& operator*() const { return *value_; }
+  T* operator->() { return value_.get(); }
+  const T* operator->() const { return value_.get(); }
+
+  T& emplace() {
+    value_ = std::make_unique<T>();
+    return *value_;
+  }
+  void reset() { value_.reset(); }
+
+  bool operator==(const Box& other) const {
+    if (!has_value() || !other.has_value()) {
+      return has_value() == other.has_value();
+    }
+    return *value_ == *other.value_;
+  }
+
+ private:
+  std::unique_ptr<T> value_;
+};
+
+// Encoder writes values in the representation used by a transport. Arrays
+// are written as their size followed by each item, maps as their size
+// followed by each key and value, and structs as the ID of each field
+// followed by its value.
+class Encoder {
+ public:
+  virtual ~Encoder() = default;
+
+  virtual void WriteNull() = 0;
+  virtual void WriteBool(bool v) = 0;
+  virtual void WriteInt(int64_t v) = 0;
+  virtual void WriteUint(uint64_t v) = 0;
+  virtual void WriteFloat32(float v) = 0;
+  virtual void WriteFloat64(double v) = 0;
+  virtual void WriteString(std::string_view v) = 0;
+  virtual void WriteBytes(const std::vector<uint8_t>& v) = 0;
+  virtual void WriteTimestamp(std::chrono::system_clock::time_point v) = 0;
+  virtual void BeginArray(size_t size) = 0;
+  virtual void EndArray() = 0;
+  virtual void BeginMap(size_t size) = 0;
+  virtual void EndMap() = 0;
+  virtual void BeginStruct(std::string_view id) = 0;
+  virtual void WriteField(int id) = 0;
+  virtual void EndStruct() = 0;
+};
+
+// Decoder reads values written by an Encoder. Methods return false when the
+// next value cannot be read as requested.
+class Decoder {
+ public:
+  virtual ~Decoder() = default;
+
+  // Reports whether the next value is null, consuming it if so.
+  virtual bool ReadNull() = 0;
+  virtual bool ReadBool(bool* v) = 0;
+  virtual bool ReadInt(int64_t* v) = 0;
+  virtual bool ReadUint(uint64_t* v) = 0;
+  virtual bool ReadFloat32(float* v) = 0;
+  virtual bool ReadFloat64(double* v) = 0;
+  virtual bool ReadString(std::string* v) = 0;
+  virtual bool ReadBytes(std::vector<uint8_t>* v) = 0;
+  virtual bool ReadTimestamp(std::chrono::system_clock::time_point* v) = 0;
+  virtual bool BeginArray(size_t* size) = 0;
+  virtual bool EndArray() = 0;
+  virtual bool BeginMap(size_t* size) = 0;
+  virtual bool EndMap() = 0;
+  virtual bool BeginStruct() = 0;
+  // Reads the ID of the next field, returning false once all fields of the
+  // struct have been read.
+  virtual bool NextField(int* id) = 0;
+  virtual bool EndStruct() = 0;
+  // Skips the next value, such as a field unknown to the generated struct.
+  virtual bool Skip() = 0;
+};
+
+// ArfStruct is satisfied by generated structs.
+template <typename T>
+concept ArfStruct = requires { T::kArfStructId; };
+
+inline void Encode(Encoder& e, bool v);
+template <std::signed_integral T>
+void Encode(Encoder& e, T v);
+template <std::unsigned_integral T>
+void Encode(Encoder& e, T v);
+inline void Encode(Encoder& e, float v);
+inline void Encode(Encoder& e, double v);
+inline void Encode(Encoder& e, const std::string& v);
+inline void Encode(Encoder& e, const std::vector<uint8_t>& v);
+inline void Encode(Encoder& e, std::chrono::system_clock::time_point v);
+template <typename T>
+  requires std::is_enum_v<T>
+void Encode(Encoder& e, T v);
+template <typename T>
+void Encode(Encoder& e, const std::optional<T>& v);
+template <typename T>
+void Encode(Encoder& e, const Box<T>& v);
+template <typename T>
+void Encode(Encoder& e, const std::vector<T>& v);
+template <typename K, typename V>
+void Encode(Encoder& e, const std::unordered_map<K, V>& v);
+template <ArfStruct T>
+void Encode(Encoder& e, const T& v);
+
+inline bool Decode(Decoder& d, bool* v);
+template <std::signed_integral T>
+bool Decode(Decoder& d, T* v);
+template <std::unsigned_integral T>
+bool Decode(Decoder& d, T* v);
+inline bool Decode(Decoder& d, float* v);
+inline bool Decode(Decoder& d, double* v);
+inline bool Decode(Decoder& d, std::string* v);
+inline bool Decode(Decoder& d, std::vector<uint8_t>* v);
+inline bool Decode(Decoder& d, std::chrono::system_clock::time_point* v);
+template <typename T>
+  requires std::is_enum_v<T>
+bool Decode(Decoder& d, T* v);
+template <typename T>
+bool Decode(Decoder& d, std::optional<T>* v);
+template <typename T>
+bool Decode(Decoder& d, Box<T>* v);
+template <typename T>
+bool Decode(Decoder& d, std::vector<T>* v);
+template <typename K, typename V>
+bool Decode(Decoder& d, std::unordered_map<K, V>* v);
+template <ArfStruct T>
+bool Decode(Decoder& d, T* v);
+
+inline void Encode(Encoder& e, bool v) { e.WriteBool(v); }
+template <std::signed_integral T>
+void Encode(Encoder& e, T v) { e.WriteInt(v); }
+template <std::unsigned_integral T>
+void Encode(Encoder& e, T v) { e.WriteUint(v); }
+inline void Encode(Encoder& e, float v) { e.WriteFloat32(v); }
+inline void Encode(Encoder& e, double v) { e.WriteFloat64(v); }
+inline void Encode(Encoder& e, const std::string& v) { e.WriteString(v); }
+inline void Encode(Encoder& e, const std::vector<uint8_t>& v) { e.WriteBytes(v); }
+inline void Encode(Encoder& e, std::chrono::system_clock::time_point v) {
+  e.WriteTimestamp(v);
+}
+template <typename T>
+  requires std::is_enum_v<T>
+void Encode(Encoder& e, T v) {
+  e.WriteInt(static_cast<std::underlying_type_t<T>>(v));
+}
+template <typename T>
+void Encode(Encoder& e, const std::optional<T>& v) {
+  if (v) {
+    Encode(e, *v);
+  } else {
+    e.WriteNull();
+  }
+}
+template <typename T>
+void Encode(Encoder& e, const Box<T>& v) {
+  if (v) {
+    Encode(e, *v);
+  } else {
+    e.WriteNull();
+  }
+}
+template <typename T>
+void Encode(Encoder& e, const std::vector<T>& v) {
+  e.BeginArray(v.size());
+  for (const auto& item : v) {
+    Encode(e, item);
+  }
+  e.EndArray();
+}
+template <typename K, typename V>
+void Encode(Encoder& e, const std::unordered_map<K, V>& v) {
+  e.BeginMap(v.size());
+  for (const auto& [key, value] : v) {
+    Encode(e, key);
+    Encode(e, value);
+  }
+  e.EndMap();
+}
+template <ArfStruct T>
+void Encode(Encoder& e, const T& v) {
+  e.BeginStruct(T::kArfStructId);
+  v.ArfVisitFields([&](int id, std::string_view, const auto& field) {
+    e.WriteField(id);
+    Encode(e, field);
+  });
+  e.EndStruct();
+}
+
+inline bool Decode(Decoder& d, bool* v) { return d.ReadBool(v); }
+template <std::signed_integral T>
+bool Decode(Decoder& d, T* v) {
+  int64_t value;
+  if (!d.ReadInt(&value)) {
+    return false;
+  }
+  *v = static_cast<T>(value);
+  return true;
+}
+template <std::unsigned_integral T>
+bool Decode(Decoder& d, T* v) {
+  uint64_t value;
+  if (!d.ReadUint(&value)) {
+    return false;
+  }
+  *v = static_cast<T>(value);
+  return true;
+}
+inline bool Decode(Decoder& d, float* v) { return d.ReadFloat32(v); }
+inline bool Decode(Decoder& d, double* v) { return d.ReadFloat64(v); }
+inline bool Decode(Decoder& d, std::string* v) { return d.ReadString(v); }
+inline bool Decode(Decoder& d, std::vector<uint8_t>* v) { return d.ReadBytes(v); }
+inline bool Decode(Decoder& d, std::chrono::system_clock::time_point* v) {
+  return d.ReadTimestamp(v);
+}
+template <typename T>
+  requires std::is_enum_v<T>
+bool Decode(Decoder& d, T* v) {
+  int64_t value;
+  if (!d.ReadInt(&value)) {
+    return false;
+  }
+  *v = static_cast<T>(value);
+  return true;
+}
+template <typename T>
+bool Decode(Decoder& d, std::optional<T>* v) {
+  if (d.ReadNull()) {
+    v->reset();
+    return true;
+  }
+  return Decode(d, &v->emplace());
+}
+template <typename T>
+bool Decode(Decoder& d, Box<T>* v) {
+  if (d.ReadNull()) {
+    v->reset();
+    return true;
+  }
+  return Decode(d, &v->emplace());
+}
+template <typename T>
+bool Decode(Decoder& d, std::vector<T>* v) {
+  size_t size;
+  if (!d.BeginArray(&size)) {
+    return false;
+  }
+  v->clear();
+  v->reserve(size);
+  for (size_t i = 0; i < size; i++) {
+    if (!Decode(d, &v->emplace_back())) {
+      return false;
+    }
+  }
+  return d.EndArray();
+}
+template <typename K, typename V>
+bool Decode(Decoder& d, std::unordered_map<K, V>* v) {
+  size_t size;
+  if (!d.BeginMap(&size)) {
+    return false;
+  }
+  v->clear();
+  for (size_t i = 0; i < size; i++) {
+    K key;
+    if (!Decode(d, &key) || !Decode(d, &(*v)[key])) {
+      return false;
+    }
+  }
+  return d.EndMap();
+}
+template <ArfStruct T>
+bool Decode(Decoder& d, T* v) {
+  if (!d.BeginStruct()) {
+    return false;
+  }
+  int id;
+  bool ok = true;
+  while (ok && d.NextField(&id)) {
+    bool found = false;
+    v->ArfVisitFields([&](int field_id, std::string_view, auto& field) {
+      if (field_id == id) {
+        found = true;
+        ok = Decode(d, &field);
+      }
+    });
+    if (!found) {
+      ok = d.Skip();
+    }
+  }
+  return ok && d.EndStruct();
+}
+
+template <typename T, typename... Outputs>
+class ClientWriter;
+template <typename T>
+class ClientReader;
+
+// Call is a call started through a Channel. Generated clients send its
+// parameters, then write items of its input stream, if any, before reading
+// its result and items of its output stream, if any.
+class Call : public std::enable_shared_from_this<Call> {
+ public:
+  virtual ~Call() = default;
+
+  // Sends the parameters of the call, written as an array by params.
+  virtual Status WriteParams(const std::function<void(Encoder&)>& params) = 0;
+  // Sends an item of the input stream, written by item.
+  virtual Status WriteItem(const std::function<void(Encoder&)>& item) = 0;
+  // Closes the input stream.
+  virtual Status CloseWrites() = 0;
+  // Waits for the service to respond, and reads the values it responded
+  // with, as an array, through result. Returns the status of the call.
+  virtual Status ReadResult(const std::function<bool(Decoder&)>& result) = 0;
+  // Reads the next item of the output stream through item, or sets done
+  // once the stream ends. Returns the status of the call when it fails or
+  // ends.
+  virtual Status ReadItem(const std::function<bool(Decoder&)>& item, bool* done) = 0;
+
+  template <typename... Params>
+  Status SendParams(const Params&... params) {
+    return WriteParams([&](Encoder& e) {
+      e.BeginArray(sizeof...(Params));
+      (Encode(e, params), ...);
+      e.EndArray();
+    });
+  }
+
+  template <typename... Outputs>
+  Status ReceiveResult(Outputs*... outputs) {
+    return ReadResult([&](Decoder& d) {
+      size_t size;
+      return d.BeginArray(&size) && (Decode(d, outputs) && ...) && d.EndArray();
+    });
+  }
+
+  template <typename T, typename... Outputs>
+  std::unique_ptr<ClientWriter<T, Outputs...>> MakeWriter() {
+    return std::make_unique<ClientWriter<T, Outputs...>>(shared_from_this());
+  }
+
+  template <typename T>
+  std::unique_ptr<ClientReader<T>> MakeReader() {
+    return std::make_unique<ClientReader<T>>(shared_from_this());
+  }
+};
+
+// ClientWriter writes items of the input stream of a call. Values the
+// service responds with are obtained through Finish.
+template <typename T, typename... Outputs>
+class ClientWriter {
+ public:
+  explicit ClientWriter(std::shared_ptr<Call> call) : call_(std::move(call)) {}
+
+  Status Write(const T& item) {
+    return call_->WriteItem([&](Encoder& e) { Encode(e, item); });
+  }
+
+  // Closes the stream, and waits for the service to respond.
+  Status Finish(Outputs*... outputs) {
+    if (auto status = call_->CloseWrites(); !status.ok()) {
+      return status;
+    }
+    return call_->ReceiveResult(outputs...);
+  }
+
+ private:
+  std::shared_ptr<Call> call_;
+};
+
+// ClientReader reads items of the output stream of a call.
+template <typename T>
+class ClientReader {
+ public:
+  explicit ClientReader(std::shared_ptr<Call> call) : call_(std::move(call)) {}
+
+  // Reads the next item, returning false once the stream ends or fails, in
+  // which case Finish returns the status of the call.
+  bool Read(T* item) {
+    bool done = false;
+    status_ = call_->ReadItem([&](Decoder& d) { return Decode(d, item); }, &done);
+    return status_.ok() && !done;
+  }
+
+  Status Finish() const { return status_; }
+
+ private:
+  std::shared_ptr<Call> call_;
+  Status status_;
+};
+
+// Channel starts calls made by generated clients.
+class Channel {
+ public:
+  virtual ~Channel() = default;
+
+  // Starts a call to method of the service identified by service. Calls to
+  // methods taking or returning a stream are started with streaming set.
+  virtual std::shared_ptr<Call> StartCall(ClientContext& ctx, std::string_view service,
+                                          std::string_view method, bool streaming) = 0;
+};
+
+// ServerReader reads items of the input stream of a call received by a
+// service. Read returns false once the stream ends.
+template <typename T>
+class ServerReader {
+ public:
+  virtual ~ServerReader() = default;
+  virtual bool Read(T* item) = 0;
+};
+
+// ServerWriter responds to a call received by a service returning a stream.
+// Values are sent through Respond, before any item is written.
+template <typename T, typename... Outputs>
+class ServerWriter {
+ public:
+  virtual ~ServerWriter() = default;
+  virtual Status Respond(const Outputs&... outputs) = 0;
+  virtual bool Write(const T& item) = 0;
+};
+
+}  // namespace arf
+`
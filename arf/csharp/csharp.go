@@ -0,0 +1,570 @@
+package csharp
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"html"
+	"regexp"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.MultiFileGenerator {
+	return &Generator{
+		t: tree,
+		w: &common.Writer{},
+	}
+}
+
+type Generator struct {
+	t *ast.PackageTree
+	w *common.Writer
+
+	namespaceMapping map[string]string
+}
+
+var namespaceValidator = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+var keywords = map[string]struct{}{
+	"abstract": {}, "as": {}, "base": {}, "bool": {}, "break": {}, "byte": {},
+	"case": {}, "catch": {}, "char": {}, "checked": {}, "class": {}, "const": {},
+	"continue": {}, "decimal": {}, "default": {}, "delegate": {}, "do": {},
+	"double": {}, "else": {}, "enum": {}, "event": {}, "explicit": {},
+	"extern": {}, "false": {}, "finally": {}, "fixed": {}, "float": {}, "for": {},
+	"foreach": {}, "goto": {}, "if": {}, "implicit": {}, "in": {}, "int": {},
+	"interface": {}, "internal": {}, "is": {}, "lock": {}, "long": {},
+	"namespace": {}, "new": {}, "null": {}, "object": {}, "operator": {},
+	"out": {}, "override": {}, "params": {}, "private": {}, "protected": {},
+	"public": {}, "readonly": {}, "ref": {}, "return": {}, "sbyte": {},
+	"sealed": {}, "short": {}, "sizeof": {}, "stackalloc": {}, "static": {},
+	"string": {}, "struct": {}, "switch": {}, "this": {}, "throw": {}, "true": {},
+	"try": {}, "typeof": {}, "uint": {}, "ulong": {}, "unchecked": {},
+	"unsafe": {}, "ushort": {}, "using": {}, "virtual": {}, "void": {},
+	"volatile": {}, "while": {},
+}
+
+func escape(name string) string {
+	if _, ok := keywords[name]; ok {
+		return "@" + name
+	}
+	return name
+}
+
+func (g *Generator) loadNamespaceMapping(ctx *cli.Context) {
+	g.namespaceMapping = map[string]string{}
+	for _, ns := range ctx.StringSlice("csharp-namespace") {
+		comps := strings.SplitN(ns, "=", 2)
+		if len(comps) != 2 {
+			output.Errorf("Invalid value for csharp-namespace: %s", ns)
+		}
+		pkg, name := strings.TrimSpace(comps[0]), strings.TrimSpace(comps[1])
+		if !namespaceValidator.MatchString(name) {
+			output.Errorf("Invalid namespace %s for csharp-namespace: %s", pkg, name)
+		}
+		g.namespaceMapping[pkg] = name
+	}
+}
+
+func (g *Generator) namespaceFor(pkg string) string {
+	if ns, ok := g.namespaceMapping[pkg]; ok {
+		return ns
+	}
+	comps := strings.Split(pkg, ".")
+	for i, c := range comps {
+		comps[i] = strcase.ToCamel(c)
+	}
+	return strings.Join(comps, ".")
+}
+
+// runtimeFile is the name of the file declaring the Arf.Rpc namespace. It is
+// the same for all packages, and written alongside each of them.
+const runtimeFile = "Arf.Rpc.arf.cs"
+
+// runtime declares the attributes generated types are annotated with, and
+// the interfaces generated clients make calls through, which applications
+// implement over the network stack of their choice.
+const runtime = `// Code generated by arfc. DO NOT EDIT.
+
+#nullable enable
+
+using System;
+using System.Collections.Generic;
+using System.Threading;
+using System.Threading.Tasks;
+
+namespace Arf.Rpc;
+
+/// <summary>
+/// Identifies the arf struct a record was generated from.
+/// </summary>
+[AttributeUsage(AttributeTargets.Class, Inherited = false)]
+public sealed class ArfStructAttribute : Attribute
+{
+    public ArfStructAttribute(string id)
+    {
+        Id = id;
+    }
+
+    public string Id { get; }
+}
+
+/// <summary>
+/// Identifies the arf field a property was generated from.
+/// </summary>
+[AttributeUsage(AttributeTargets.Property, Inherited = false)]
+public sealed class ArfFieldAttribute : Attribute
+{
+    public ArfFieldAttribute(int id)
+    {
+        Id = id;
+    }
+
+    public int Id { get; }
+}
+
+/// <summary>
+/// Identifies the arf service an interface was generated from.
+/// </summary>
+[AttributeUsage(AttributeTargets.Interface, Inherited = false)]
+public sealed class ArfServiceAttribute : Attribute
+{
+    public ArfServiceAttribute(string id)
+    {
+        Id = id;
+    }
+
+    public string Id { get; }
+}
+
+/// <summary>
+/// Carries calls made by generated clients to arf services.
+/// </summary>
+public interface IArfClient
+{
+    /// <summary>
+    /// Starts a call to a method of the service identified by serviceId,
+    /// with the provided parameters. Calls to methods taking an input stream
+    /// are started with streaming set.
+    /// </summary>
+    Task<IArfCall> CallAsync(string serviceId, string method, object?[] parameters, bool streaming, CancellationToken cancellationToken);
+}
+
+/// <summary>
+/// A call started through an IArfClient.
+/// </summary>
+public interface IArfCall
+{
+    /// <summary>
+    /// Sends the items of stream to the service, for methods taking an input
+    /// stream.
+    /// </summary>
+    void Send<T>(IAsyncEnumerable<T> stream, CancellationToken cancellationToken);
+
+    /// <summary>
+    /// Waits for the service to respond, returning the values it responded
+    /// with.
+    /// </summary>
+    Task<IArfResult> ResultAsync(CancellationToken cancellationToken);
+
+    /// <summary>
+    /// Returns the items streamed by the service, for methods returning a
+    /// stream.
+    /// </summary>
+    IAsyncEnumerable<T> ReadAllAsync<T>(CancellationToken cancellationToken);
+}
+
+/// <summary>
+/// Holds the values a service responded with.
+/// </summary>
+public interface IArfResult
+{
+    /// <summary>
+    /// Returns the value at index as T.
+    /// </summary>
+    T Get<T>(int index);
+}
+`
+
+// GenFiles generates the source file of the package, along with the file
+// declaring Arf.Rpc.
+func (g *Generator) GenFiles(ctx *cli.Context) []common.File {
+	data, targetDir, targetFile := g.GenFile(ctx)
+	return []common.File{
+		{Data: data, TargetDir: targetDir, TargetFile: targetFile},
+		{Data: []byte(runtime), TargetDir: targetDir, TargetFile: runtimeFile},
+	}
+}
+
+func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string) {
+	g.loadNamespaceMapping(ctx)
+	ns := g.namespaceFor(g.t.Package)
+
+	targetDir = ctx.String("output")
+	targetFile = ns + ".arf.cs"
+
+	g.w.Writelnf("// Code generated by arfc. DO NOT EDIT.")
+	g.w.Break()
+	g.w.Writelnf("#nullable enable")
+	g.w.Break()
+	g.w.Writelnf("using System;")
+	g.w.Writelnf("using System.Collections.Generic;")
+	g.w.Writelnf("using System.Runtime.CompilerServices;")
+	g.w.Writelnf("using System.Threading;")
+	g.w.Writelnf("using System.Threading.Tasks;")
+	g.w.Writelnf("using Arf.Rpc;")
+	g.w.Break()
+	g.w.Writelnf("namespace %s;", ns)
+
+	for _, e := range g.t.Enums {
+		g.makeEnum(&e)
+	}
+
+	for _, s := range g.t.Structures {
+		g.makeStruct(&s)
+	}
+
+	for _, s := range g.t.Services {
+		g.makeService(&s)
+	}
+
+	for _, s := range g.t.Services {
+		g.makeClient(&s)
+	}
+
+	data = []byte(g.w.String())
+	return
+}
+
+func (g *Generator) writeComments(c []string) {
+	if len(c) == 0 {
+		return
+	}
+	g.w.Writelnf("/// <summary>")
+	for _, c := range c {
+		g.w.Writelnf("///%s", html.EscapeString(c))
+	}
+	g.w.Writelnf("/// </summary>")
+}
+
+func (g *Generator) writeDeprecation(set ast.AnnotationSet) {
+	ann := set.ByName("deprecated")
+	if ann == nil {
+		return
+	}
+	if len(ann.Arguments) == 0 {
+		g.w.Writelnf("[Obsolete]")
+		return
+	}
+	g.w.Writelnf("[Obsolete(%q)]", fmt.Sprint(ann.Arguments[0]))
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	g.w.Break()
+	g.writeComments(e.Comment)
+	g.writeDeprecation(e.Annotations)
+	g.w.Writelnf("public enum %s", common.PathName(e))
+	g.w.Writelnf("{")
+	g.w.IncreaseIndent()
+	for _, v := range e.Members {
+		g.writeComments(v.Comment)
+		g.writeDeprecation(v.Annotations)
+		g.w.Writelnf("%s = %d,", strcase.ToCamel(v.Name), v.Value)
+	}
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func propertyName(s *ast.Struct, f *ast.StructField) string {
+	name := strcase.ToCamel(f.Name)
+	// C# does not allow members to share the name of their enclosing type.
+	if name == common.PathName(s) {
+		name += "Value"
+	}
+	return name
+}
+
+func (g *Generator) makeStruct(s *ast.Struct) {
+	for _, st := range s.Structs {
+		g.makeStruct(&st)
+	}
+
+	for _, e := range s.Enums {
+		g.makeEnum(&e)
+	}
+
+	g.w.Break()
+	g.writeComments(s.Comment)
+	g.writeDeprecation(s.Annotations)
+	g.w.Writelnf("[ArfStruct(%q)]", common.CanonicalStructName(g.t.Package, s))
+	g.w.Writelnf("public sealed record %s", common.PathName(s))
+	g.w.Writelnf("{")
+	g.w.IncreaseIndent()
+	for i, f := range s.Fields {
+		if i != 0 {
+			g.w.Break()
+		}
+		g.writeComments(f.Comment)
+		g.writeDeprecation(f.Annotations)
+		g.w.Writef("[ArfField(%d)] public %s %s { get; init; }", f.ID, g.convertType(f.Type), propertyName(s, &f))
+		if init := g.initializer(f.Type); init != "" {
+			g.w.Writef(" = %s;", init)
+		}
+		g.w.Break()
+	}
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+type method struct {
+	m            *ast.ServiceMethod
+	name         string
+	params       []string
+	paramNames   []string
+	outputs      []string
+	inputStream  string
+	outputStream string
+}
+
+func (m *method) returnType() string {
+	var all []string
+	all = append(all, m.outputs...)
+	if m.outputStream != "" {
+		if len(all) == 0 {
+			return fmt.Sprintf("IAsyncEnumerable<%s>", m.outputStream)
+		}
+		all = append(all, fmt.Sprintf("IAsyncEnumerable<%s>", m.outputStream))
+	}
+	switch len(all) {
+	case 0:
+		return "Task"
+	case 1:
+		return fmt.Sprintf("Task<%s>", all[0])
+	default:
+		return fmt.Sprintf("Task<(%s)>", strings.Join(all, ", "))
+	}
+}
+
+// isIterator indicates whether the method only yields a stream, in which
+// case it is represented as an async iterator instead of a Task.
+func (m *method) isIterator() bool {
+	return m.outputStream != "" && len(m.outputs) == 0
+}
+
+func (m *method) signature(cancellationAttr string) string {
+	params := append([]string{}, m.params...)
+	if m.inputStream != "" {
+		params = append(params, fmt.Sprintf("IAsyncEnumerable<%s> stream", m.inputStream))
+	}
+	params = append(params, cancellationAttr+"CancellationToken cancellationToken = default")
+	return fmt.Sprintf("%s %s(%s)", m.returnType(), m.name, strings.Join(params, ", "))
+}
+
+func (g *Generator) makeMethod(m *ast.ServiceMethod) *method {
+	def := &method{
+		m:    m,
+		name: strcase.ToCamel(m.Name) + "Async",
+	}
+	for _, p := range m.Params {
+		if p.Stream {
+			def.inputStream = g.convertType(p.Type)
+			continue
+		}
+		name := escape(strcase.ToLowerCamel(*p.Name))
+		def.params = append(def.params, fmt.Sprintf("%s %s", g.convertType(p.Type), name))
+		def.paramNames = append(def.paramNames, name)
+	}
+	for _, r := range m.Returns {
+		if r.Stream {
+			def.outputStream = g.convertType(r.Type)
+			continue
+		}
+		def.outputs = append(def.outputs, g.convertType(r.Type))
+	}
+	return def
+}
+
+func (g *Generator) makeService(s *ast.Service) {
+	g.w.Break()
+	g.writeComments(s.Comment)
+	g.writeDeprecation(s.Annotations)
+	g.w.Writelnf("[ArfService(%q)]", fmt.Sprintf("%s/%s", g.t.Package, s.Name))
+	g.w.Writelnf("public interface I%s", s.Name)
+	g.w.Writelnf("{")
+	g.w.IncreaseIndent()
+	for i, m := range s.Methods {
+		if i != 0 {
+			g.w.Break()
+		}
+		def := g.makeMethod(m)
+		g.writeComments(m.Comment)
+		g.writeDeprecation(m.Annotations)
+		g.w.Writelnf("%s;", def.signature(""))
+	}
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func (g *Generator) makeClient(s *ast.Service) {
+	g.w.Break()
+	g.writeComments(s.Comment)
+	g.writeDeprecation(s.Annotations)
+	g.w.Writelnf("public sealed class %sClient : I%s", s.Name, s.Name)
+	g.w.Writelnf("{")
+	g.w.IncreaseIndent()
+	g.w.Writelnf("public const string ServiceId = %q;", fmt.Sprintf("%s/%s", g.t.Package, s.Name))
+	g.w.Break()
+	g.w.Writelnf("private readonly IArfClient _client;")
+	g.w.Break()
+	g.w.Writelnf("public %sClient(IArfClient client)", s.Name)
+	g.w.Writelnf("{")
+	g.w.IncreaseIndent()
+	g.w.Writelnf("_client = client;")
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+
+	for _, m := range s.Methods {
+		def := g.makeMethod(m)
+		g.w.Break()
+		g.writeComments(m.Comment)
+		g.writeDeprecation(m.Annotations)
+		attr := ""
+		if def.isIterator() {
+			attr = "[EnumeratorCancellation] "
+		}
+		g.w.Writelnf("public async %s", def.signature(attr))
+		g.w.Writelnf("{")
+		g.w.IncreaseIndent()
+		params := "Array.Empty<object?>()"
+		if len(def.paramNames) > 0 {
+			params = fmt.Sprintf("new object?[] { %s }", strings.Join(def.paramNames, ", "))
+		}
+		g.w.Writelnf("var call = await _client.CallAsync(ServiceId, %q, %s, streaming: %t, cancellationToken);",
+			m.Name, params, def.inputStream != "")
+		if def.inputStream != "" {
+			g.w.Writelnf("call.Send(stream, cancellationToken);")
+		}
+
+		var results []string
+		if len(def.outputs) > 0 {
+			g.w.Writelnf("var result = await call.ResultAsync(cancellationToken);")
+			for i, o := range def.outputs {
+				results = append(results, fmt.Sprintf("result.Get<%s>(%d)", o, i))
+			}
+		} else if def.outputStream == "" {
+			g.w.Writelnf("await call.ResultAsync(cancellationToken);")
+		}
+
+		switch {
+		case def.isIterator():
+			g.w.Writelnf("await foreach (var item in call.ReadAllAsync<%s>(cancellationToken))", def.outputStream)
+			g.w.Writelnf("{")
+			g.w.IncreaseIndent()
+			g.w.Writelnf("yield return item;")
+			g.w.DecreaseIndent()
+			g.w.Writelnf("}")
+		case def.outputStream != "":
+			results = append(results, fmt.Sprintf("call.ReadAllAsync<%s>(cancellationToken)", def.outputStream))
+			fallthrough
+		default:
+			switch len(results) {
+			case 0:
+			case 1:
+				g.w.Writelnf("return %s;", results[0])
+			default:
+				g.w.Writelnf("return (%s);", strings.Join(results, ", "))
+			}
+		}
+
+		g.w.DecreaseIndent()
+		g.w.Writelnf("}")
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func (g *Generator) userTypeName(obj ast.Object) string {
+	var name, pkg string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name, pkg = common.PathName(v), v.Position.File.Package.Value
+	case *ast.Enum:
+		name, pkg = common.PathName(v), v.Position.File.Package.Value
+	default:
+		return "INVALID"
+	}
+	if pkg != g.t.Package {
+		return "global::" + g.namespaceFor(pkg) + "." + name
+	}
+	return name
+}
+
+func (g *Generator) convertType(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "int8":
+			return "sbyte"
+		case "int16":
+			return "short"
+		case "int32":
+			return "int"
+		case "int64":
+			return "long"
+		case "uint8":
+			return "byte"
+		case "uint16":
+			return "ushort"
+		case "uint32":
+			return "uint"
+		case "uint64":
+			return "ulong"
+		case "float32":
+			return "float"
+		case "float64":
+			return "double"
+		case "bytes":
+			return "byte[]"
+		case "timestamp":
+			return "DateTimeOffset"
+		default:
+			return v.Name
+		}
+	case *ast.OptionalType:
+		return g.convertType(v.Type) + "?"
+	case *ast.ArrayType:
+		return "List<" + g.convertType(v.Type) + ">"
+	case *ast.MapType:
+		return fmt.Sprintf("Dictionary<%s, %s>", g.convertType(v.Key), g.convertType(v.Value))
+	case *ast.SimpleUserType:
+		return g.userTypeName(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return g.userTypeName(v.ResolvedType)
+	default:
+		return "INVALID"
+	}
+}
+
+// initializer returns the default value assigned to non-nullable reference
+// properties, or an empty string when the type's default is already valid.
+func (g *Generator) initializer(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			return `""`
+		case "bytes":
+			return "Array.Empty<byte>()"
+		}
+	case *ast.ArrayType, *ast.MapType:
+		return "new()"
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		if common.IsUserType(t) {
+			return "new()"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,651 @@
+package dart
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"slices"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.MultiFileGenerator {
+	return &Generator{
+		t: tree,
+		w: &common.Writer{},
+	}
+}
+
+type Generator struct {
+	t *ast.PackageTree
+	w *common.Writer
+
+	imports []string
+}
+
+var keywords = map[string]struct{}{
+	"assert": {}, "break": {}, "case": {}, "catch": {}, "class": {},
+	"const": {}, "continue": {}, "default": {}, "do": {}, "else": {},
+	"enum": {}, "extends": {}, "false": {}, "final": {}, "finally": {},
+	"for": {}, "if": {}, "in": {}, "is": {}, "new": {}, "null": {},
+	"rethrow": {}, "return": {}, "super": {}, "switch": {}, "this": {},
+	"throw": {}, "true": {}, "try": {}, "var": {}, "void": {}, "while": {},
+	"with": {}, "hashCode": {}, "runtimeType": {}, "toString": {},
+	"copyWith": {}, "toArf": {}, "values": {}, "value": {}, "index": {},
+}
+
+func identifier(name string) string {
+	name = strcase.ToLowerCamel(name)
+	if _, ok := keywords[name]; ok {
+		return name + "$"
+	}
+	return name
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, `$`, `\$`, "\n", `\n`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// FileName returns the name of the file generated for a given arf package.
+func FileName(pkg string) string {
+	return strings.ReplaceAll(pkg, ".", "_") + ".arf.dart"
+}
+
+func importAlias(pkg string) string {
+	return "$" + strings.ReplaceAll(pkg, ".", "_")
+}
+
+// transportFile is the name of the file declaring the interfaces generated
+// clients make calls through. It is the same for all packages, and written
+// alongside each of them.
+const transportFile = "arf_transport.arf.dart"
+
+// transport declares the interfaces generated clients make calls through.
+// Applications implement them over the network stack of their choice.
+const transport = `// Code generated by arfc. DO NOT EDIT.
+
+/// Carries calls made by generated clients to arf services.
+///
+/// Values are exchanged in the representation produced by ` + "`toArf`" + ` of
+/// generated classes: structs are maps of field IDs to values, enums are
+/// their integer values, and other values are represented as themselves.
+abstract interface class ArfClient {
+  /// Starts a call to [method] of the service identified by [serviceId],
+  /// with the provided [params]. Methods taking an input stream receive its
+  /// items through [stream].
+  Future<ArfCall> call(String serviceId, String method, List<Object?> params, {Stream<Object?>? stream});
+}
+
+/// A call started through an [ArfClient].
+abstract interface class ArfCall {
+  /// Waits for the service to respond, returning the values it responded
+  /// with.
+  Future<List<Object?>> result();
+
+  /// Returns the items streamed by the service, for methods returning a
+  /// stream.
+  Stream<Object?> responses();
+}
+`
+
+// GenFiles generates the source file of the package, along with the file
+// declaring ArfClient.
+func (g *Generator) GenFiles(ctx *cli.Context) []common.File {
+	data, targetDir, targetFile := g.GenFile(ctx)
+	return []common.File{
+		{Data: data, TargetDir: targetDir, TargetFile: targetFile},
+		{Data: []byte(transport), TargetDir: targetDir, TargetFile: transportFile},
+	}
+}
+
+func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string) {
+	targetDir = ctx.String("output")
+	targetFile = FileName(g.t.Package)
+
+	for _, e := range g.t.Enums {
+		g.makeEnum(&e)
+	}
+
+	for _, s := range g.t.Structures {
+		g.makeStruct(&s)
+	}
+
+	for _, s := range g.t.Services {
+		g.makeClient(&s)
+	}
+
+	g.w.Merge(g.makeHeader())
+
+	data = []byte(g.w.String())
+	return
+}
+
+func (g *Generator) makeHeader() *common.Writer {
+	w := &common.Writer{}
+	w.Writelnf("// Code generated by arfc. DO NOT EDIT.")
+	w.Break()
+	w.Writelnf("import 'dart:typed_data';")
+	w.Break()
+	w.Writelnf("import 'package:collection/collection.dart';")
+	w.Break()
+	w.Writelnf("import %s;", quote(transportFile))
+	for _, pkg := range g.imports {
+		w.Writelnf("import %s as %s;", quote(FileName(pkg)), importAlias(pkg))
+	}
+	w.Break()
+	w.Writelnf("const _arfEquality = DeepCollectionEquality();")
+	return w
+}
+
+func (g *Generator) requirePackage(pkg string) {
+	if !slices.Contains(g.imports, pkg) {
+		g.imports = append(g.imports, pkg)
+	}
+}
+
+func (g *Generator) writeComments(c []string) {
+	for _, c := range c {
+		g.w.Writelnf("///%s", c)
+	}
+}
+
+func (g *Generator) writeDeprecation(set ast.AnnotationSet) {
+	ann := set.ByName("deprecated")
+	if ann == nil {
+		return
+	}
+	if len(ann.Arguments) == 0 {
+		g.w.Writelnf("@deprecated")
+		return
+	}
+	g.w.Writelnf("@Deprecated(%s)", quote(fmt.Sprint(ann.Arguments[0])))
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	name := common.PathName(e)
+	g.w.Break()
+	g.writeComments(e.Comment)
+	g.writeDeprecation(e.Annotations)
+	g.w.Writelnf("enum %s {", name)
+	g.w.IncreaseIndent()
+	for i, v := range e.Members {
+		g.writeComments(v.Comment)
+		g.writeDeprecation(v.Annotations)
+		sep := ","
+		if i == len(e.Members)-1 {
+			sep = ";"
+		}
+		g.w.Writelnf("%s(%d)%s", identifier(v.Name), v.Value, sep)
+	}
+	g.w.Break()
+	g.w.Writelnf("const %s(this.value);", name)
+	g.w.Break()
+	g.w.Writelnf("final int value;")
+	g.w.Break()
+	g.w.Writelnf("/// Returns the first member declared with the provided [value].")
+	g.w.Writelnf("static %s fromValue(int value) => values.firstWhere(", name)
+	g.w.IncreaseIndent()
+	g.w.Writelnf("(e) => e.value == value,")
+	g.w.Writelnf("orElse: () => throw ArgumentError.value(value, 'value', 'Unknown %s value'),", name)
+	g.w.DecreaseIndent()
+	g.w.Writelnf(");")
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+type field struct {
+	f    *ast.StructField
+	name string
+	typ  string
+	// defaultValue is a constant expression used as the default value of the
+	// constructor parameter, when one exists.
+	defaultValue string
+	// initializer is a non-constant expression used to initialize the field
+	// when its constructor parameter is omitted.
+	initializer string
+	collection  bool
+}
+
+func (g *Generator) makeField(f *ast.StructField) *field {
+	def := &field{
+		f:    f,
+		name: identifier(f.Name),
+		typ:  g.convertType(f.Type),
+	}
+	if opt, ok := f.Type.(*ast.OptionalType); ok {
+		switch inner := opt.Type.(type) {
+		case *ast.ArrayType, *ast.MapType:
+			def.collection = true
+		case *ast.PrimitiveType:
+			def.collection = inner.Name == "bytes"
+		}
+	}
+	switch v := f.Type.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			def.defaultValue = "''"
+		case "bool":
+			def.defaultValue = "false"
+		case "float32", "float64":
+			def.defaultValue = "0.0"
+		case "bytes":
+			def.initializer = "Uint8List(0)"
+			def.collection = true
+		case "timestamp":
+			def.initializer = "DateTime.fromMillisecondsSinceEpoch(0, isUtc: true)"
+		default:
+			def.defaultValue = "0"
+		}
+	case *ast.ArrayType:
+		def.defaultValue = "const []"
+		def.collection = true
+	case *ast.MapType:
+		def.defaultValue = "const {}"
+		def.collection = true
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		if common.IsUserType(v) {
+			def.initializer = def.typ + "()"
+		} else {
+			e := resolved(v).(*ast.Enum)
+			def.defaultValue = def.typ + "." + identifier(e.Members[0].Name)
+		}
+	}
+	return def
+}
+
+func (g *Generator) makeStruct(s *ast.Struct) {
+	for _, st := range s.Structs {
+		g.makeStruct(&st)
+	}
+
+	for _, e := range s.Enums {
+		g.makeEnum(&e)
+	}
+
+	name := common.PathName(s)
+	fields := make([]*field, len(s.Fields))
+	isConst := true
+	for i := range s.Fields {
+		fields[i] = g.makeField(&s.Fields[i])
+		if fields[i].initializer != "" {
+			isConst = false
+		}
+	}
+
+	g.w.Break()
+	g.writeComments(s.Comment)
+	g.writeDeprecation(s.Annotations)
+	g.w.Writelnf("class %s {", name)
+	g.w.IncreaseIndent()
+	g.w.Writelnf("static const arfStructId = %s;", quote(common.CanonicalStructName(g.t.Package, s)))
+
+	// Constructor
+	g.w.Break()
+	if isConst {
+		g.w.Writef("const ")
+	}
+	if len(fields) == 0 {
+		g.w.Writelnf("%s();", name)
+	} else {
+		g.w.Writelnf("%s({", name)
+		g.w.IncreaseIndent()
+		for _, f := range fields {
+			switch {
+			case f.initializer != "":
+				g.w.Writelnf("%s? %s,", f.typ, f.name)
+			case f.defaultValue != "":
+				g.w.Writelnf("this.%s = %s,", f.name, f.defaultValue)
+			default:
+				g.w.Writelnf("this.%s,", f.name)
+			}
+		}
+		g.w.DecreaseIndent()
+		g.w.Writef("})")
+		var inits []string
+		for _, f := range fields {
+			if f.initializer != "" {
+				inits = append(inits, fmt.Sprintf("%s = %s ?? %s", f.name, f.name, f.initializer))
+			}
+		}
+		if len(inits) > 0 {
+			g.w.Writef(" : %s", strings.Join(inits, ", "))
+		}
+		g.w.Writelnf(";")
+	}
+
+	// Decoding
+	g.w.Break()
+	g.w.Writelnf("factory %s.fromArf(Map<int, Object?> fields) => %s(", name, name)
+	g.w.IncreaseIndent()
+	for _, f := range fields {
+		g.w.Writelnf("%s: %s,", f.name, g.decodeExpr(f.f.Type, fmt.Sprintf("fields[%d]", f.f.ID), 0))
+	}
+	g.w.DecreaseIndent()
+	g.w.Writelnf(");")
+
+	// Fields
+	for _, f := range fields {
+		g.w.Break()
+		g.writeComments(f.f.Comment)
+		g.writeDeprecation(f.f.Annotations)
+		g.w.Writelnf("final %s %s;", f.typ, f.name)
+	}
+
+	// Encoding
+	g.w.Break()
+	g.w.Writelnf("Map<int, Object?> toArf() => {")
+	g.w.IncreaseIndent()
+	for _, f := range fields {
+		g.w.Writelnf("%d: %s,", f.f.ID, g.encodeExpr(f.f.Type, f.name, 0))
+	}
+	g.w.DecreaseIndent()
+	g.w.Writelnf("};")
+
+	// copyWith
+	g.w.Break()
+	if len(fields) == 0 {
+		g.w.Writelnf("%s copyWith() => %s();", name, name)
+	} else {
+		g.w.Writelnf("%s copyWith({", name)
+		g.w.IncreaseIndent()
+		for _, f := range fields {
+			typ := f.typ
+			if !strings.HasSuffix(typ, "?") {
+				typ += "?"
+			}
+			g.w.Writelnf("%s %s,", typ, f.name)
+		}
+		g.w.DecreaseIndent()
+		g.w.Writelnf("}) =>")
+		g.w.IncreaseIndent()
+		g.w.Writelnf("%s(", name)
+		g.w.IncreaseIndent()
+		for _, f := range fields {
+			g.w.Writelnf("%s: %s ?? this.%s,", f.name, f.name, f.name)
+		}
+		g.w.DecreaseIndent()
+		g.w.Writelnf(");")
+		g.w.DecreaseIndent()
+	}
+
+	// Equality
+	g.w.Break()
+	g.w.Writelnf("@override")
+	g.w.Writelnf("bool operator ==(Object other) =>")
+	g.w.IncreaseIndent()
+	g.w.Writef("identical(this, other) || other is %s", name)
+	for _, f := range fields {
+		if f.collection {
+			g.w.Writef(" && _arfEquality.equals(%s, other.%s)", f.name, f.name)
+		} else {
+			g.w.Writef(" && %s == other.%s", f.name, f.name)
+		}
+	}
+	g.w.Writelnf(";")
+	g.w.DecreaseIndent()
+
+	g.w.Break()
+	g.w.Writelnf("@override")
+	g.w.Writef("int get hashCode => Object.hashAll([")
+	for i, f := range fields {
+		if i != 0 {
+			g.w.Writef(", ")
+		}
+		if f.collection {
+			g.w.Writef("_arfEquality.hash(%s)", f.name)
+		} else {
+			g.w.Writef("%s", f.name)
+		}
+	}
+	g.w.Writelnf("]);")
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func (g *Generator) makeClient(s *ast.Service) {
+	g.w.Break()
+	g.writeComments(s.Comment)
+	g.writeDeprecation(s.Annotations)
+	g.w.Writelnf("class %sClient {", strcase.ToCamel(s.Name))
+	g.w.IncreaseIndent()
+	g.w.Writelnf("static const serviceId = %s;", quote(fmt.Sprintf("%s/%s", g.t.Package, s.Name)))
+	g.w.Break()
+	g.w.Writelnf("%sClient(this._client);", strcase.ToCamel(s.Name))
+	g.w.Break()
+	g.w.Writelnf("final ArfClient _client;")
+
+	for _, m := range s.Methods {
+		g.makeClientMethod(m)
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func (g *Generator) makeClientMethod(m *ast.ServiceMethod) {
+	var (
+		params       []string
+		encoded      []string
+		outputs      []ast.Type
+		inputStream  ast.Type
+		outputStream ast.Type
+	)
+	for _, p := range m.Params {
+		if p.Stream {
+			inputStream = p.Type
+			continue
+		}
+		name := identifier(*p.Name)
+		params = append(params, fmt.Sprintf("%s %s", g.convertType(p.Type), name))
+		encoded = append(encoded, g.encodeExpr(p.Type, name, 0))
+	}
+	if inputStream != nil {
+		params = append(params, fmt.Sprintf("Stream<%s> stream", g.convertType(inputStream)))
+	}
+	for _, r := range m.Returns {
+		if r.Stream {
+			outputStream = r.Type
+			continue
+		}
+		outputs = append(outputs, r.Type)
+	}
+
+	var returnTypes []string
+	for _, o := range outputs {
+		returnTypes = append(returnTypes, g.convertType(o))
+	}
+	if outputStream != nil {
+		returnTypes = append(returnTypes, fmt.Sprintf("Stream<%s>", g.convertType(outputStream)))
+	}
+
+	// A method that only yields a stream is represented as an async
+	// generator, so callers are not required to await the call itself.
+	isGenerator := outputStream != nil && len(outputs) == 0
+
+	var returnType string
+	switch {
+	case isGenerator:
+		returnType = returnTypes[0]
+	case len(returnTypes) == 0:
+		returnType = "Future<void>"
+	case len(returnTypes) == 1:
+		returnType = fmt.Sprintf("Future<%s>", returnTypes[0])
+	default:
+		returnType = fmt.Sprintf("Future<(%s)>", strings.Join(returnTypes, ", "))
+	}
+
+	g.w.Break()
+	g.writeComments(m.Comment)
+	g.writeDeprecation(m.Annotations)
+	modifier := "async"
+	if isGenerator {
+		modifier = "async*"
+	}
+	g.w.Writelnf("%s %s(%s) %s {", returnType, identifier(m.Name), strings.Join(params, ", "), modifier)
+	g.w.IncreaseIndent()
+
+	g.w.Writef("final call = await _client.call(serviceId, %s, [%s]", quote(m.Name), strings.Join(encoded, ", "))
+	if inputStream != nil {
+		g.w.Writef(", stream: stream.map((e) => %s)", g.encodeExpr(inputStream, "e", 1))
+	}
+	g.w.Writelnf(");")
+
+	var results []string
+	if len(outputs) > 0 {
+		g.w.Writelnf("final result = await call.result();")
+		for i, o := range outputs {
+			results = append(results, g.decodeExpr(o, fmt.Sprintf("result[%d]", i), 0))
+		}
+	} else if outputStream == nil {
+		g.w.Writelnf("await call.result();")
+	}
+
+	if outputStream != nil {
+		results = append(results, fmt.Sprintf("call.responses().map((e) => %s)", g.decodeExpr(outputStream, "e", 1)))
+	}
+
+	switch {
+	case isGenerator:
+		g.w.Writelnf("yield* %s;", results[0])
+	case len(results) == 1:
+		g.w.Writelnf("return %s;", results[0])
+	case len(results) > 1:
+		g.w.Writelnf("return (%s);", strings.Join(results, ", "))
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func (g *Generator) userTypeName(obj ast.Object) string {
+	var name, pkg string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name, pkg = common.PathName(v), v.Position.File.Package.Value
+	case *ast.Enum:
+		name, pkg = common.PathName(v), v.Position.File.Package.Value
+	default:
+		return "INVALID"
+	}
+	if pkg != g.t.Package {
+		g.requirePackage(pkg)
+		return importAlias(pkg) + "." + name
+	}
+	return name
+}
+
+func resolved(t ast.Type) ast.Object {
+	switch v := t.(type) {
+	case *ast.SimpleUserType:
+		return v.ResolvedType
+	case *ast.FullQualifiedType:
+		return v.ResolvedType
+	}
+	return nil
+}
+
+func (g *Generator) convertType(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			return "String"
+		case "bool":
+			return "bool"
+		case "float32", "float64":
+			return "double"
+		case "bytes":
+			return "Uint8List"
+		case "timestamp":
+			return "DateTime"
+		default:
+			return "int"
+		}
+	case *ast.OptionalType:
+		return g.convertType(v.Type) + "?"
+	case *ast.ArrayType:
+		return "List<" + g.convertType(v.Type) + ">"
+	case *ast.MapType:
+		return fmt.Sprintf("Map<%s, %s>", g.convertType(v.Key), g.convertType(v.Value))
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		return g.userTypeName(resolved(t))
+	default:
+		return "INVALID"
+	}
+}
+
+// decodeExpr returns an expression converting src, a value obtained from
+// the arf runtime, into the Dart representation of t. depth is used to
+// generate unique names for closure parameters.
+func (g *Generator) decodeExpr(t ast.Type, src string, depth int) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "float32", "float64":
+			return fmt.Sprintf("(%s as num).toDouble()", src)
+		default:
+			return fmt.Sprintf("%s as %s", src, g.convertType(t))
+		}
+	case *ast.OptionalType:
+		return fmt.Sprintf("%s == null ? null : %s", src, g.decodeExpr(v.Type, src, depth))
+	case *ast.ArrayType:
+		e := fmt.Sprintf("e%d", depth)
+		return fmt.Sprintf("(%s as List).map((%s) => %s).toList()", src, e, g.decodeExpr(v.Type, e, depth+1))
+	case *ast.MapType:
+		k, val := fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		return fmt.Sprintf("(%s as Map).map((%s, %s) => MapEntry(%s, %s))", src, k, val,
+			g.decodeExpr(v.Key, k, depth+1), g.decodeExpr(v.Value, val, depth+1))
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		name := g.userTypeName(resolved(t))
+		if common.IsUserType(t) {
+			return fmt.Sprintf("%s.fromArf(%s as Map<int, Object?>)", name, src)
+		}
+		return fmt.Sprintf("%s.fromValue(%s as int)", name, src)
+	default:
+		return "INVALID"
+	}
+}
+
+// encodeExpr returns an expression converting src into a value understood by
+// the arf runtime.
+func (g *Generator) encodeExpr(t ast.Type, src string, depth int) string {
+	switch v := t.(type) {
+	case *ast.OptionalType:
+		if resolved(v.Type) != nil {
+			if common.IsUserType(v.Type) {
+				return src + "?.toArf()"
+			}
+			return src + "?.value"
+		}
+		inner := g.encodeExpr(v.Type, src+"!", depth)
+		if inner == src+"!" {
+			return src
+		}
+		return fmt.Sprintf("%s == null ? null : %s", src, inner)
+	case *ast.ArrayType:
+		e := fmt.Sprintf("e%d", depth)
+		inner := g.encodeExpr(v.Type, e, depth+1)
+		if inner == e {
+			return src
+		}
+		return fmt.Sprintf("%s.map((%s) => %s).toList()", src, e, inner)
+	case *ast.MapType:
+		k, val := fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		key, value := g.encodeExpr(v.Key, k, depth+1), g.encodeExpr(v.Value, val, depth+1)
+		if key == k && value == val {
+			return src
+		}
+		return fmt.Sprintf("%s.map((%s, %s) => MapEntry(%s, %s))", src, k, val, key, value)
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		if common.IsUserType(t) {
+			return src + ".toArf()"
+		}
+		return src + ".value"
+	default:
+		return src
+	}
+}
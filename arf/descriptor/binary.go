@@ -0,0 +1,174 @@
+package descriptor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Magic prefixes descriptors encoded in the binary format.
+const Magic = "ARFD"
+
+// MarshalBinary encodes the set in a compact binary format. After Magic and
+// the format version as an unsigned varint, values are written as follows:
+//
+//   - Strings as an unsigned varint length followed by their UTF-8 bytes;
+//   - Integers as zig-zag encoded varints;
+//   - Booleans as a single byte, either 0 or 1;
+//   - Lists as an unsigned varint length followed by each item;
+//   - Optional values (such as elem, key and value of types) as a single
+//     byte indicating whether the value is present, followed by the value;
+//   - Objects as each of their fields, in the order they are listed in the
+//     JSON representation.
+func (s *Set) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(Magic)
+	buf.Write(binary.AppendUvarint(nil, uint64(s.Version)))
+	encodeValue(&buf, reflect.ValueOf(s.Packages))
+	return buf.Bytes(), nil
+}
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		buf.Write(binary.AppendUvarint(nil, uint64(v.Len())))
+		buf.WriteString(v.String())
+	case reflect.Int:
+		buf.Write(binary.AppendVarint(nil, v.Int()))
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case reflect.Slice:
+		buf.Write(binary.AppendUvarint(nil, uint64(v.Len())))
+		for i := 0; i < v.Len(); i++ {
+			encodeValue(buf, v.Index(i))
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			buf.WriteByte(0)
+			return
+		}
+		buf.WriteByte(1)
+		encodeValue(buf, v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			encodeValue(buf, v.Field(i))
+		}
+	default:
+		panic(fmt.Sprintf("unsupported kind %s", v.Kind()))
+	}
+}
+
+var errTruncated = errors.New("truncated descriptor")
+
+// UnmarshalBinary decodes a set encoded by MarshalBinary.
+func (s *Set) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		return errors.New("invalid descriptor: missing magic")
+	}
+	r := bytes.NewReader(data[len(Magic):])
+	version, err := binary.ReadUvarint(r)
+	if err != nil {
+		return errTruncated
+	}
+	if version != Version {
+		return fmt.Errorf("unsupported descriptor version %d", version)
+	}
+	s.Version = int(version)
+	if err = decodeValue(r, reflect.ValueOf(&s.Packages).Elem()); err != nil {
+		return err
+	}
+	if _, err = r.ReadByte(); err != io.EOF {
+		return errors.New("invalid descriptor: trailing data")
+	}
+	return nil
+}
+
+func decodeValue(r *bytes.Reader, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errTruncated
+		}
+		if n > uint64(r.Len()) {
+			return errTruncated
+		}
+		data := make([]byte, n)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return errTruncated
+		}
+		v.SetString(string(data))
+	case reflect.Int:
+		n, err := binary.ReadVarint(r)
+		if err != nil {
+			return errTruncated
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := r.ReadByte()
+		if err != nil {
+			return errTruncated
+		}
+		v.SetBool(b != 0)
+	case reflect.Slice:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return errTruncated
+		}
+		// Every item takes at least one byte.
+		if n > uint64(r.Len()) {
+			return errTruncated
+		}
+		v.Set(reflect.MakeSlice(v.Type(), int(n), int(n)))
+		for i := 0; i < int(n); i++ {
+			if err = decodeValue(r, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		b, err := r.ReadByte()
+		if err != nil {
+			return errTruncated
+		}
+		if b == 0 {
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		return decodeValue(r, v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := decodeValue(r, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		panic(fmt.Sprintf("unsupported kind %s", v.Kind()))
+	}
+	return nil
+}
+
+// Load decodes a descriptor in either the JSON or binary format.
+func Load(data []byte) (*Set, error) {
+	set := &Set{}
+	if bytes.HasPrefix(data, []byte(Magic)) {
+		if err := set.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return set, nil
+	}
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor: %w", err)
+	}
+	if set.Version != Version {
+		return nil, fmt.Errorf("unsupported descriptor version %d", set.Version)
+	}
+	return set, nil
+}
@@ -0,0 +1,347 @@
+package descriptor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/idl/ast"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Version is the version of the descriptor format. It is incremented whenever
+// a change to the format would prevent existing readers from decoding it.
+const Version = 1
+
+// Type kinds used by descriptors.
+const (
+	KindPrimitive = "primitive"
+	KindStruct    = "struct"
+	KindEnum      = "enum"
+	KindOptional  = "optional"
+	KindArray     = "array"
+	KindMap       = "map"
+)
+
+// Set represents the resolved schema of an IDL file and all files it
+// imports. Packages are sorted by name, and declarations follow the order in
+// which they appear in source files.
+type Set struct {
+	Version  int        `json:"version"`
+	Packages []*Package `json:"packages"`
+}
+
+type Package struct {
+	Name     string     `json:"name"`
+	Files    []string   `json:"files"`
+	Structs  []*Struct  `json:"structs"`
+	Enums    []*Enum    `json:"enums"`
+	Services []*Service `json:"services"`
+}
+
+type Annotation struct {
+	Name      string   `json:"name"`
+	Arguments []string `json:"arguments,omitempty"`
+}
+
+type Struct struct {
+	Name        string        `json:"name"`
+	FullName    string        `json:"full_name"`
+	Comment     []string      `json:"comment,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Fields      []*Field      `json:"fields"`
+	Structs     []*Struct     `json:"structs,omitempty"`
+	Enums       []*Enum       `json:"enums,omitempty"`
+}
+
+type Field struct {
+	Name        string        `json:"name"`
+	ID          int           `json:"id"`
+	Type        *Type         `json:"type"`
+	Comment     []string      `json:"comment,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+}
+
+// Type represents the type of a field, parameter or return value. Name holds
+// the name of primitives, or the full name of structs and enums. Elem is set
+// for optional and array types, while Key and Value are set for maps.
+type Type struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name,omitempty"`
+	Elem  *Type  `json:"elem,omitempty"`
+	Key   *Type  `json:"key,omitempty"`
+	Value *Type  `json:"value,omitempty"`
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case KindOptional:
+		return "optional<" + t.Elem.String() + ">"
+	case KindArray:
+		return "array<" + t.Elem.String() + ">"
+	case KindMap:
+		return "map<" + t.Key.String() + ", " + t.Value.String() + ">"
+	}
+	return t.Name
+}
+
+// References reports whether t is, or contains, the user type named name.
+func (t *Type) References(name string) bool {
+	if t == nil {
+		return false
+	}
+	return t.Name == name && (t.Kind == KindStruct || t.Kind == KindEnum) ||
+		t.Elem.References(name) || t.Key.References(name) || t.Value.References(name)
+}
+
+type Enum struct {
+	Name        string        `json:"name"`
+	FullName    string        `json:"full_name"`
+	Comment     []string      `json:"comment,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Members     []*Member     `json:"members"`
+}
+
+type Member struct {
+	Name        string        `json:"name"`
+	Value       int           `json:"value"`
+	Comment     []string      `json:"comment,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+}
+
+type Service struct {
+	Name        string        `json:"name"`
+	FullName    string        `json:"full_name"`
+	Comment     []string      `json:"comment,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Methods     []*Method     `json:"methods"`
+}
+
+type Method struct {
+	Name        string        `json:"name"`
+	Comment     []string      `json:"comment,omitempty"`
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Params      []*Param      `json:"params"`
+	Returns     []*Return     `json:"returns"`
+}
+
+// Param represents a method parameter. Name is empty for streamed
+// parameters, which are anonymous.
+type Param struct {
+	Name   string `json:"name,omitempty"`
+	Type   *Type  `json:"type"`
+	Stream bool   `json:"stream,omitempty"`
+}
+
+type Return struct {
+	Type   *Type `json:"type"`
+	Stream bool  `json:"stream,omitempty"`
+}
+
+// Build creates a descriptor from a parsed tree. File paths are recorded
+// relative to root.
+func Build(tree *ast.Tree, root string) *Set {
+	set := &Set{Version: Version, Packages: []*Package{}}
+	for _, name := range slices.Sorted(maps.Keys(tree.Packages)) {
+		pkg := &Package{
+			Name:     name,
+			Files:    []string{},
+			Structs:  []*Struct{},
+			Enums:    []*Enum{},
+			Services: []*Service{},
+		}
+		files := slices.Clone(tree.Packages[name].Files)
+		slices.SortFunc(files, func(a, b *ast.File) int { return strings.Compare(a.Path, b.Path) })
+		for _, f := range files {
+			path := f.Path
+			if rel, err := filepath.Rel(root, f.Path); err == nil {
+				path = rel
+			}
+			pkg.Files = append(pkg.Files, filepath.ToSlash(path))
+			for i := range f.Structs {
+				pkg.Structs = append(pkg.Structs, buildStruct(&f.Structs[i]))
+			}
+			for i := range f.Enums {
+				pkg.Enums = append(pkg.Enums, buildEnum(&f.Enums[i]))
+			}
+			for i := range f.Services {
+				pkg.Services = append(pkg.Services, buildService(&f.Services[i]))
+			}
+		}
+		set.Packages = append(set.Packages, pkg)
+	}
+	return set
+}
+
+// fullName returns the name of obj prefixed by its package and enclosing
+// structs.
+func fullName(obj ast.Object) string {
+	path := common.ObjectPath(obj)
+	if path == nil {
+		return obj.FQN()
+	}
+	return obj.Pos().File.Package.Value + "." + strings.Join(path, ".")
+}
+
+func buildComment(c []string) []string {
+	if len(c) == 0 {
+		return nil
+	}
+	lines := make([]string, len(c))
+	for i, l := range c {
+		lines[i] = strings.TrimPrefix(l, " ")
+	}
+	return lines
+}
+
+func buildAnnotations(set ast.AnnotationSet) []*Annotation {
+	var annotations []*Annotation
+	for _, a := range set {
+		v := &Annotation{Name: a.Name}
+		for _, arg := range a.Arguments {
+			v.Arguments = append(v.Arguments, fmt.Sprint(arg))
+		}
+		annotations = append(annotations, v)
+	}
+	return annotations
+}
+
+func buildType(t ast.Type) *Type {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		return &Type{Kind: KindPrimitive, Name: v.Name}
+	case *ast.OptionalType:
+		return &Type{Kind: KindOptional, Elem: buildType(v.Type)}
+	case *ast.ArrayType:
+		return &Type{Kind: KindArray, Elem: buildType(v.Type)}
+	case *ast.MapType:
+		return &Type{Kind: KindMap, Key: buildType(v.Key), Value: buildType(v.Value)}
+	case *ast.SimpleUserType:
+		return userType(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return userType(v.ResolvedType)
+	}
+	panic(fmt.Sprintf("unexpected type %T", t))
+}
+
+func userType(obj ast.Object) *Type {
+	if _, ok := obj.(*ast.Enum); ok {
+		return &Type{Kind: KindEnum, Name: fullName(obj)}
+	}
+	return &Type{Kind: KindStruct, Name: fullName(obj)}
+}
+
+func buildStruct(s *ast.Struct) *Struct {
+	v := &Struct{
+		Name:        s.Name,
+		FullName:    fullName(s),
+		Comment:     buildComment(s.Comment),
+		Annotations: buildAnnotations(s.Annotations),
+		Fields:      []*Field{},
+	}
+	for _, f := range s.Fields {
+		v.Fields = append(v.Fields, &Field{
+			Name:        f.Name,
+			ID:          f.ID,
+			Type:        buildType(f.Type),
+			Comment:     buildComment(f.Comment),
+			Annotations: buildAnnotations(f.Annotations),
+		})
+	}
+	for i := range s.Structs {
+		v.Structs = append(v.Structs, buildStruct(&s.Structs[i]))
+	}
+	for i := range s.Enums {
+		v.Enums = append(v.Enums, buildEnum(&s.Enums[i]))
+	}
+	return v
+}
+
+func buildEnum(e *ast.Enum) *Enum {
+	v := &Enum{
+		Name:        e.Name,
+		FullName:    fullName(e),
+		Comment:     buildComment(e.Comment),
+		Annotations: buildAnnotations(e.Annotations),
+		Members:     []*Member{},
+	}
+	for _, m := range e.Members {
+		v.Members = append(v.Members, &Member{
+			Name:        m.Name,
+			Value:       m.Value,
+			Comment:     buildComment(m.Comment),
+			Annotations: buildAnnotations(m.Annotations),
+		})
+	}
+	return v
+}
+
+func buildService(s *ast.Service) *Service {
+	v := &Service{
+		Name:        s.Name,
+		FullName:    s.Position.File.Package.Value + "." + s.Name,
+		Comment:     buildComment(s.Comment),
+		Annotations: buildAnnotations(s.Annotations),
+		Methods:     []*Method{},
+	}
+	for _, m := range s.Methods {
+		method := &Method{
+			Name:        m.Name,
+			Comment:     buildComment(m.Comment),
+			Annotations: buildAnnotations(m.Annotations),
+			Params:      []*Param{},
+			Returns:     []*Return{},
+		}
+		for _, p := range m.Params {
+			param := &Param{Type: buildType(p.Type), Stream: p.Stream}
+			if p.Name != nil {
+				param.Name = *p.Name
+			}
+			method.Params = append(method.Params, param)
+		}
+		for _, r := range m.Returns {
+			method.Returns = append(method.Returns, &Return{Type: buildType(r.Type), Stream: r.Stream})
+		}
+		v.Methods = append(v.Methods, method)
+	}
+	return v
+}
+
+// MarshalIndent encodes the set as indented JSON.
+func (s *Set) MarshalIndent() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// WalkStructs calls fn for each struct in the set, including nested ones.
+func (s *Set) WalkStructs(fn func(pkg *Package, st *Struct)) {
+	var walk func(pkg *Package, structs []*Struct)
+	walk = func(pkg *Package, structs []*Struct) {
+		for _, st := range structs {
+			fn(pkg, st)
+			walk(pkg, st.Structs)
+		}
+	}
+	for _, pkg := range s.Packages {
+		walk(pkg, pkg.Structs)
+	}
+}
+
+// WalkEnums calls fn for each enum in the set, including nested ones.
+func (s *Set) WalkEnums(fn func(pkg *Package, e *Enum)) {
+	for _, pkg := range s.Packages {
+		for _, e := range pkg.Enums {
+			fn(pkg, e)
+		}
+	}
+	s.WalkStructs(func(pkg *Package, st *Struct) {
+		for _, e := range st.Enums {
+			fn(pkg, e)
+		}
+	})
+}
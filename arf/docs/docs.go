@@ -0,0 +1,295 @@
+package docs
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.Generator {
+	return &Generator{
+		t: tree,
+		w: &common.Writer{},
+	}
+}
+
+type Generator struct {
+	t *ast.PackageTree
+	w *common.Writer
+	r renderer
+}
+
+func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string) {
+	switch format := strings.ToLower(ctx.String("docs-format")); format {
+	case "", "markdown", "md":
+		g.r = &markdownRenderer{w: g.w}
+	case "html":
+		g.r = &htmlRenderer{w: g.w}
+	default:
+		output.Errorf("unknown docs format `%s': only 'markdown' and 'html' are supported.", format)
+	}
+	targetDir = ctx.String("output")
+	targetFile = g.t.Package + g.r.extension()
+
+	var structs []*ast.Struct
+	var enums []*ast.Enum
+	for i := range g.t.Enums {
+		enums = append(enums, &g.t.Enums[i])
+	}
+	for i := range g.t.Structures {
+		collectStruct(&g.t.Structures[i], &structs, &enums)
+	}
+
+	g.r.begin(g.t.Package)
+	g.r.heading(1, "", "Package "+g.r.code(g.t.Package))
+	g.makeContents(structs, enums)
+
+	if len(g.t.Services) > 0 {
+		g.r.heading(2, "services", "Services")
+		for i := range g.t.Services {
+			g.makeService(&g.t.Services[i])
+		}
+	}
+
+	if len(structs) > 0 {
+		g.r.heading(2, "structures", "Structures")
+		for _, s := range structs {
+			g.makeStruct(s)
+		}
+	}
+
+	if len(enums) > 0 {
+		g.r.heading(2, "enums", "Enums")
+		for _, e := range enums {
+			g.makeEnum(e)
+		}
+	}
+	g.r.end()
+
+	data = []byte(g.w.String())
+	return
+}
+
+// collectStruct flattens s and its nested types into structs and enums.
+func collectStruct(s *ast.Struct, structs *[]*ast.Struct, enums *[]*ast.Enum) {
+	*structs = append(*structs, s)
+	for i := range s.Structs {
+		collectStruct(&s.Structs[i], structs, enums)
+	}
+	for i := range s.Enums {
+		*enums = append(*enums, &s.Enums[i])
+	}
+}
+
+func serviceAnchor(s *ast.Service) string {
+	return s.Name
+}
+
+func methodAnchor(s *ast.Service, m *ast.ServiceMethod) string {
+	return s.Name + "." + m.Name
+}
+
+// objectAnchor returns the anchor of a struct or enum within the page of its
+// package, made of the names of all structs enclosing it (e.g.
+// Invoice.Line.Kind), along with the package itself.
+func objectAnchor(obj ast.Object) (anchor string, pkg string) {
+	var name string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name, pkg = common.StructName(v), v.Position.File.Package.Value
+	case *ast.Enum:
+		name, pkg = common.EnumName(v), v.Position.File.Package.Value
+	default:
+		return "", ""
+	}
+	if path := common.ObjectPath(obj); path != nil {
+		name = strings.Join(path, ".")
+	}
+	return name, pkg
+}
+
+func (g *Generator) makeContents(structs []*ast.Struct, enums []*ast.Enum) {
+	var items []string
+	for i := range g.t.Services {
+		s := &g.t.Services[i]
+		items = append(items, "Service "+g.r.link(g.r.escape(s.Name), "#"+serviceAnchor(s)))
+	}
+	for _, s := range structs {
+		items = append(items, "Struct "+g.objectLink(s))
+	}
+	for _, e := range enums {
+		items = append(items, "Enum "+g.objectLink(e))
+	}
+	if len(items) == 0 {
+		return
+	}
+	g.r.heading(2, "contents", "Contents")
+	g.r.list(items)
+}
+
+func (g *Generator) comment(c []string) string {
+	lines := make([]string, 0, len(c))
+	for _, l := range c {
+		lines = append(lines, g.r.escape(strings.TrimPrefix(l, " ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (g *Generator) writeDescription(c []string, set ast.AnnotationSet) {
+	if text := g.deprecation(set); text != "" {
+		g.r.notice(text)
+	}
+	if len(c) > 0 {
+		g.r.paragraph(g.comment(c))
+	}
+}
+
+// deprecation returns the deprecation notice for a given annotation set, or
+// an empty string in case it does not include @deprecated.
+func (g *Generator) deprecation(set ast.AnnotationSet) string {
+	ann := set.ByName("deprecated")
+	if ann == nil {
+		return ""
+	}
+	if len(ann.Arguments) > 0 {
+		return g.r.escape(fmt.Sprint(ann.Arguments[0]))
+	}
+	return "This item is deprecated."
+}
+
+// cellDescription combines comments and deprecation notices of fields and
+// members into a single table cell.
+func (g *Generator) cellDescription(c []string, set ast.AnnotationSet) string {
+	desc := g.comment(c)
+	if text := g.deprecation(set); text != "" {
+		if desc != "" {
+			desc += "\n"
+		}
+		desc += "Deprecated: " + text
+	}
+	return desc
+}
+
+func (g *Generator) makeService(s *ast.Service) {
+	g.r.heading(3, serviceAnchor(s), g.r.escape(s.Name))
+	g.writeDescription(s.Comment, s.Annotations)
+	g.r.paragraph(fmt.Sprintf("Service ID: %s", g.r.code(fmt.Sprintf("%s/%s", g.t.Package, s.Name))))
+
+	for _, m := range s.Methods {
+		g.makeMethod(s, m)
+	}
+}
+
+func (g *Generator) makeMethod(s *ast.Service, m *ast.ServiceMethod) {
+	g.r.heading(4, methodAnchor(s, m), g.r.escape(m.Name))
+	g.writeDescription(m.Comment, m.Annotations)
+
+	var inputStream, outputStream bool
+	var params [][]string
+	for _, p := range m.Params {
+		name := "-"
+		if p.Name != nil {
+			name = g.r.code(*p.Name)
+		}
+		kind := "Value"
+		if p.Stream {
+			inputStream = true
+			kind = "Stream"
+		}
+		params = append(params, []string{name, g.typeRef(p.Type), kind})
+	}
+	var returns [][]string
+	for _, r := range m.Returns {
+		kind := "Value"
+		if r.Stream {
+			outputStream = true
+			kind = "Stream"
+		}
+		returns = append(returns, []string{g.typeRef(r.Type), kind})
+	}
+
+	switch {
+	case inputStream && outputStream:
+		g.r.paragraph("Streaming: bidirectional.")
+	case inputStream:
+		g.r.paragraph("Streaming: requests.")
+	case outputStream:
+		g.r.paragraph("Streaming: responses.")
+	}
+
+	if len(params) > 0 {
+		g.r.paragraph("Parameters:")
+		g.r.table([]string{"Name", "Type", "Kind"}, params)
+	}
+	if len(returns) > 0 {
+		g.r.paragraph("Returns:")
+		g.r.table([]string{"Type", "Kind"}, returns)
+	}
+}
+
+func (g *Generator) makeStruct(s *ast.Struct) {
+	anchor, _ := objectAnchor(s)
+	g.r.heading(3, anchor, g.r.escape(anchor))
+	g.writeDescription(s.Comment, s.Annotations)
+	g.r.paragraph(fmt.Sprintf("Struct ID: %s", g.r.code(common.CanonicalStructName(g.t.Package, s))))
+	if len(s.Fields) == 0 {
+		return
+	}
+	rows := make([][]string, 0, len(s.Fields))
+	for _, f := range s.Fields {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", f.ID),
+			g.r.code(f.Name),
+			g.typeRef(f.Type),
+			g.cellDescription(f.Comment, f.Annotations),
+		})
+	}
+	g.r.table([]string{"ID", "Field", "Type", "Description"}, rows)
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	anchor, _ := objectAnchor(e)
+	g.r.heading(3, anchor, g.r.escape(anchor))
+	g.writeDescription(e.Comment, e.Annotations)
+	rows := make([][]string, 0, len(e.Members))
+	for _, m := range e.Members {
+		rows = append(rows, []string{
+			g.r.code(m.Name),
+			fmt.Sprintf("%d", m.Value),
+			g.cellDescription(m.Comment, m.Annotations),
+		})
+	}
+	g.r.table([]string{"Member", "Value", "Description"}, rows)
+}
+
+// objectLink returns a link to the documentation of a struct or enum, which
+// may be placed in the page of another package.
+func (g *Generator) objectLink(obj ast.Object) string {
+	anchor, pkg := objectAnchor(obj)
+	if pkg == g.t.Package {
+		return g.r.link(g.r.escape(anchor), "#"+anchor)
+	}
+	return g.r.link(g.r.escape(pkg+"."+anchor), pkg+g.r.extension()+"#"+anchor)
+}
+
+func (g *Generator) typeRef(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		return g.r.escape(v.Name)
+	case *ast.OptionalType:
+		return g.r.escape("optional<") + g.typeRef(v.Type) + g.r.escape(">")
+	case *ast.ArrayType:
+		return g.r.escape("array<") + g.typeRef(v.Type) + g.r.escape(">")
+	case *ast.MapType:
+		return g.r.escape("map<") + g.typeRef(v.Key) + g.r.escape(", ") + g.typeRef(v.Value) + g.r.escape(">")
+	case *ast.SimpleUserType:
+		return g.objectLink(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return g.objectLink(v.ResolvedType)
+	default:
+		return g.r.escape("INVALID")
+	}
+}
@@ -0,0 +1,184 @@
+package docs
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"html"
+	"strings"
+)
+
+// renderer produces markup for a given documentation format. Methods
+// returning strings produce inline markup, which may be composed and passed
+// to methods writing blocks. Plain text must be escaped through escape before
+// being composed, except when provided to code.
+type renderer interface {
+	extension() string
+	begin(title string)
+	end()
+	heading(level int, anchor, text string)
+	paragraph(text string)
+	notice(text string)
+	list(items []string)
+	table(headers []string, rows [][]string)
+
+	escape(text string) string
+	code(text string) string
+	link(text, href string) string
+}
+
+type markdownRenderer struct {
+	w *common.Writer
+}
+
+func (r *markdownRenderer) extension() string { return ".md" }
+
+func (r *markdownRenderer) begin(string) {}
+
+func (r *markdownRenderer) end() {}
+
+func (r *markdownRenderer) heading(level int, anchor, text string) {
+	r.w.Break()
+	if anchor != "" {
+		r.w.Writelnf(`<a id="%s"></a>`, anchor)
+		r.w.Break()
+	}
+	r.w.Writelnf("%s %s", strings.Repeat("#", level), text)
+}
+
+func (r *markdownRenderer) paragraph(text string) {
+	r.w.Break()
+	r.w.Writelnf("%s", text)
+}
+
+func (r *markdownRenderer) notice(text string) {
+	r.w.Break()
+	r.w.Writelnf("> **Deprecated:** %s", text)
+}
+
+func (r *markdownRenderer) list(items []string) {
+	r.w.Break()
+	for _, i := range items {
+		r.w.Writelnf("- %s", i)
+	}
+}
+
+func (r *markdownRenderer) table(headers []string, rows [][]string) {
+	r.w.Break()
+	r.w.Writelnf("| %s |", strings.Join(headers, " | "))
+	r.w.Writelnf("|%s", strings.Repeat("---|", len(headers)))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = strings.ReplaceAll(c, "\n", "<br>")
+		}
+		r.w.Writelnf("| %s |", strings.Join(cells, " | "))
+	}
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "|", `\|`, "#", `\#`,
+)
+
+func (r *markdownRenderer) escape(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+func (r *markdownRenderer) code(text string) string {
+	// Pipes must be escaped even within code spans when placed in tables.
+	return "`" + strings.ReplaceAll(text, "|", `\|`) + "`"
+}
+
+func (r *markdownRenderer) link(text, href string) string {
+	return fmt.Sprintf("[%s](%s)", text, href)
+}
+
+type htmlRenderer struct {
+	w *common.Writer
+}
+
+func (r *htmlRenderer) extension() string { return ".html" }
+
+func (r *htmlRenderer) begin(title string) {
+	r.w.Writelnf("<!DOCTYPE html>")
+	r.w.Writelnf(`<html lang="en">`)
+	r.w.Writelnf("<head>")
+	r.w.IncreaseIndent()
+	r.w.Writelnf(`<meta charset="utf-8">`)
+	r.w.Writelnf("<title>%s</title>", html.EscapeString(title))
+	r.w.Writelnf("<style>")
+	r.w.IncreaseIndent()
+	r.w.Writelnf("body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }")
+	r.w.Writelnf("table { border-collapse: collapse; }")
+	r.w.Writelnf("th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }")
+	r.w.Writelnf(".deprecated { border-left: 4px solid #d9822b; padding-left: 0.6em; }")
+	r.w.DecreaseIndent()
+	r.w.Writelnf("</style>")
+	r.w.DecreaseIndent()
+	r.w.Writelnf("</head>")
+	r.w.Writelnf("<body>")
+	r.w.IncreaseIndent()
+}
+
+func (r *htmlRenderer) end() {
+	r.w.DecreaseIndent()
+	r.w.Writelnf("</body>")
+	r.w.Writelnf("</html>")
+}
+
+func (r *htmlRenderer) heading(level int, anchor, text string) {
+	if anchor != "" {
+		r.w.Writelnf(`<h%d id="%s">%s</h%d>`, level, html.EscapeString(anchor), text, level)
+		return
+	}
+	r.w.Writelnf("<h%d>%s</h%d>", level, text, level)
+}
+
+func (r *htmlRenderer) paragraph(text string) {
+	r.w.Writelnf("<p>%s</p>", strings.ReplaceAll(text, "\n", "<br>"))
+}
+
+func (r *htmlRenderer) notice(text string) {
+	r.w.Writelnf(`<p class="deprecated"><strong>Deprecated:</strong> %s</p>`, text)
+}
+
+func (r *htmlRenderer) list(items []string) {
+	r.w.Writelnf("<ul>")
+	r.w.IncreaseIndent()
+	for _, i := range items {
+		r.w.Writelnf("<li>%s</li>", i)
+	}
+	r.w.DecreaseIndent()
+	r.w.Writelnf("</ul>")
+}
+
+func (r *htmlRenderer) table(headers []string, rows [][]string) {
+	r.w.Writelnf("<table>")
+	r.w.IncreaseIndent()
+	r.w.Writef("<tr>")
+	for _, h := range headers {
+		r.w.Writef("<th>%s</th>", h)
+	}
+	r.w.Writelnf("</tr>")
+	for _, row := range rows {
+		r.w.Writef("<tr>")
+		for _, c := range row {
+			r.w.Writef("<td>%s</td>", strings.ReplaceAll(c, "\n", "<br>"))
+		}
+		r.w.Writelnf("</tr>")
+	}
+	r.w.DecreaseIndent()
+	r.w.Writelnf("</table>")
+}
+
+func (r *htmlRenderer) escape(text string) string {
+	return html.EscapeString(text)
+}
+
+func (r *htmlRenderer) code(text string) string {
+	return "<code>" + html.EscapeString(text) + "</code>"
+}
+
+func (r *htmlRenderer) link(text, href string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), text)
+}
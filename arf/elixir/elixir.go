@@ -0,0 +1,575 @@
+package elixir
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.MultiFileGenerator {
+	return &Generator{
+		t: tree,
+		w: &common.Writer{},
+	}
+}
+
+type Generator struct {
+	t *ast.PackageTree
+	w *common.Writer
+
+	moduleMapping map[string]string
+}
+
+var moduleValidator = regexp.MustCompile(`^([A-Z][a-zA-Z0-9]*)(\.([A-Z][a-zA-Z0-9]*))*$`)
+
+var reservedWords = map[string]struct{}{
+	"do": {}, "end": {}, "fn": {}, "when": {}, "and": {}, "or": {}, "not": {},
+	"in": {}, "nil": {}, "true": {}, "false": {}, "catch": {}, "rescue": {},
+	"after": {}, "else": {},
+}
+
+func variableName(name string) string {
+	name = strcase.ToSnake(name)
+	if _, ok := reservedWords[name]; ok {
+		return name + "_"
+	}
+	return name
+}
+
+func (g *Generator) loadModuleMapping(ctx *cli.Context) {
+	g.moduleMapping = map[string]string{}
+	for _, mod := range ctx.StringSlice("elixir-module") {
+		comps := strings.SplitN(mod, "=", 2)
+		if len(comps) != 2 {
+			output.Errorf("Invalid value for elixir-module: %s", mod)
+		}
+		pkg, name := strings.TrimSpace(comps[0]), strings.TrimSpace(comps[1])
+		if !moduleValidator.MatchString(name) {
+			output.Errorf("Invalid module %s for elixir-module: %s", pkg, name)
+		}
+		g.moduleMapping[pkg] = name
+	}
+}
+
+func (g *Generator) moduleFor(pkg string) string {
+	if mod, ok := g.moduleMapping[pkg]; ok {
+		return mod
+	}
+	comps := strings.Split(pkg, ".")
+	for i, c := range comps {
+		comps[i] = strcase.ToCamel(c)
+	}
+	return strings.Join(comps, ".")
+}
+
+// transportFile is the path of the file declaring Arf.Transport, relative
+// to the output directory.
+var transportFile = filepath.Join("arf", "transport.arf.ex")
+
+// transport declares the behaviour generated clients make calls through.
+// Applications implement it over the network stack of their choice.
+const transport = `# Code generated by arfc. DO NOT EDIT.
+
+defmodule Arf.Transport do
+  @moduledoc """
+  Arf.Transport carries calls made by generated clients to arf services.
+
+  A transport is a tuple of a module implementing this behaviour and the
+  state passed to it. Values are accompanied by descriptors, which are the
+  same terms returned by ` + "`__arf_fields__/0`" + ` of generated structs.
+  """
+
+  @type t :: {module(), term()}
+
+  @type descriptor ::
+          atom()
+          | {:optional, descriptor()}
+          | {:array, descriptor()}
+          | {:map, descriptor(), descriptor()}
+          | {:enum, module()}
+          | {:struct, module()}
+          | {:stream, descriptor()}
+
+  @doc """
+  Makes a call to method of the service identified by service_id. params
+  holds each parameter along with its descriptor, and returns the
+  descriptors of the values the service responds with, in order. Input and
+  output streams are described by {:stream, descriptor}, and exchanged as
+  Enumerable.t() values.
+  """
+  @callback call(
+              state :: term(),
+              service_id :: String.t(),
+              method :: String.t(),
+              params :: [{descriptor(), term()}],
+              returns :: [descriptor()],
+              opts :: keyword()
+            ) :: {:ok, [term()]} | {:error, term()}
+
+  @doc "Makes a call through transport. See c:call/6."
+  @spec call(t(), String.t(), String.t(), [{descriptor(), term()}], [descriptor()], keyword()) ::
+          {:ok, [term()]} | {:error, term()}
+  def call({module, state}, service_id, method, params, returns, opts) do
+    module.call(state, service_id, method, params, returns, opts)
+  end
+end
+`
+
+// GenFiles generates the source file of the package, along with the file
+// declaring Arf.Transport.
+func (g *Generator) GenFiles(ctx *cli.Context) []common.File {
+	data, targetDir, targetFile := g.GenFile(ctx)
+	return []common.File{
+		{Data: data, TargetDir: targetDir, TargetFile: targetFile},
+		{
+			Data:       []byte(transport),
+			TargetDir:  filepath.Join(ctx.String("output"), filepath.Dir(transportFile)),
+			TargetFile: filepath.Base(transportFile),
+		},
+	}
+}
+
+func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string) {
+	g.loadModuleMapping(ctx)
+	mods := strings.Split(g.moduleFor(g.t.Package), ".")
+
+	dirs := make([]string, 0, len(mods)-1)
+	for _, mod := range mods[:len(mods)-1] {
+		dirs = append(dirs, strcase.ToSnake(mod))
+	}
+	targetDir = filepath.Join(append([]string{ctx.String("output")}, dirs...)...)
+	targetFile = strcase.ToSnake(mods[len(mods)-1]) + ".arf.ex"
+
+	g.w.Writelnf("# Code generated by arfc. DO NOT EDIT.")
+
+	for _, e := range g.t.Enums {
+		g.makeEnum(&e)
+	}
+
+	for _, s := range g.t.Structures {
+		g.makeStruct(&s)
+	}
+
+	for _, s := range g.t.Services {
+		g.makeService(&s)
+	}
+
+	for _, s := range g.t.Services {
+		g.makeClient(&s)
+	}
+
+	data = []byte(g.w.String())
+	return
+}
+
+func (g *Generator) writeDoc(attr string, c []string, set ast.AnnotationSet) {
+	lines := make([]string, 0, len(c))
+	for _, l := range c {
+		lines = append(lines, strings.TrimPrefix(l, " "))
+	}
+	if ann := set.ByName("deprecated"); ann != nil {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		if len(ann.Arguments) > 0 {
+			lines = append(lines, fmt.Sprintf("Deprecated: %s", ann.Arguments[0]))
+		} else {
+			lines = append(lines, "Deprecated.")
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	g.w.Writelnf("@%s \"\"\"", attr)
+	for _, l := range lines {
+		g.w.Writelnf("%s", strings.ReplaceAll(l, `"""`, `\"\"\"`))
+	}
+	g.w.Writelnf(`"""`)
+}
+
+func (g *Generator) objectModule(obj ast.Object) string {
+	var name, pkg string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name, pkg = v.Name, v.Position.File.Package.Value
+	case *ast.Enum:
+		name, pkg = v.Name, v.Position.File.Package.Value
+	default:
+		return "INVALID"
+	}
+	names := common.ObjectPath(obj)
+	if names == nil {
+		names = []string{name}
+	}
+	for i, n := range names {
+		names[i] = strcase.ToCamel(n)
+	}
+	return g.moduleFor(pkg) + "." + strings.Join(names, ".")
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	g.w.Break()
+	g.w.Writelnf("defmodule %s do", g.objectModule(e))
+	g.w.IncreaseIndent()
+	g.writeDoc("moduledoc", e.Comment, e.Annotations)
+
+	atoms := make([]string, 0, len(e.Members))
+	for _, v := range e.Members {
+		atoms = append(atoms, ":"+strcase.ToSnake(v.Name))
+	}
+	g.w.Writelnf("@type t :: %s", strings.Join(atoms, " | "))
+	g.w.Break()
+
+	g.w.Writelnf("@doc \"Returns all members of this enum.\"")
+	g.w.Writelnf("@spec values() :: [t()]")
+	g.w.Writelnf("def values, do: [%s]", strings.Join(atoms, ", "))
+	g.w.Break()
+
+	g.w.Writelnf("@doc \"Converts a member of this enum into its integer representation.\"")
+	g.w.Writelnf("@spec to_integer(t()) :: integer()")
+	for i, v := range e.Members {
+		g.w.Writelnf("def to_integer(%s), do: %d", atoms[i], v.Value)
+	}
+	g.w.Break()
+
+	// Members sharing a value are aliases; decoding yields the first one
+	// declared, as later clauses would never match.
+	g.w.Writelnf("@doc \"Converts an integer into a member of this enum.\"")
+	g.w.Writelnf("@spec from_integer(integer()) :: {:ok, t()} | :error")
+	seen := map[int]struct{}{}
+	for i, v := range e.Members {
+		if _, ok := seen[v.Value]; ok {
+			continue
+		}
+		seen[v.Value] = struct{}{}
+		g.w.Writelnf("def from_integer(%d), do: {:ok, %s}", v.Value, atoms[i])
+	}
+	g.w.Writelnf("def from_integer(_), do: :error")
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("end")
+}
+
+func (g *Generator) makeStruct(s *ast.Struct) {
+	g.w.Break()
+	g.w.Writelnf("defmodule %s do", g.objectModule(s))
+	g.w.IncreaseIndent()
+	g.writeDoc("moduledoc", s.Comment, s.Annotations)
+
+	if len(s.Fields) == 0 {
+		g.w.Writelnf("@type t :: %%__MODULE__{}")
+		g.w.Break()
+		g.w.Writelnf("defstruct []")
+	} else {
+		g.w.Writelnf("@type t :: %%__MODULE__{")
+		g.w.IncreaseIndent()
+		for i, f := range s.Fields {
+			sep := ","
+			if i == len(s.Fields)-1 {
+				sep = ""
+			}
+			g.w.Writelnf("%s: %s%s", strcase.ToSnake(f.Name), g.typeSpec(f.Type, true), sep)
+		}
+		g.w.DecreaseIndent()
+		g.w.Writelnf("}")
+		g.w.Break()
+
+		g.w.Writelnf("defstruct [")
+		g.w.IncreaseIndent()
+		for i, f := range s.Fields {
+			sep := ","
+			if i == len(s.Fields)-1 {
+				sep = ""
+			}
+			g.w.Writelnf("%s: %s%s", strcase.ToSnake(f.Name), g.defaultValue(f.Type), sep)
+		}
+		g.w.DecreaseIndent()
+		g.w.Writelnf("]")
+	}
+	g.w.Break()
+
+	g.w.Writelnf("@doc false")
+	g.w.Writelnf("def __arf_struct_id__, do: %q", common.CanonicalStructName(g.t.Package, s))
+	g.w.Break()
+	g.w.Writelnf("@doc false")
+	g.w.Writelnf("def __arf_fields__ do")
+	g.w.IncreaseIndent()
+	g.w.Writelnf("[")
+	g.w.IncreaseIndent()
+	for i, f := range s.Fields {
+		sep := ","
+		if i == len(s.Fields)-1 {
+			sep = ""
+		}
+		g.w.Writelnf("{%d, :%s, %s}%s", f.ID, strcase.ToSnake(f.Name), g.descriptor(f.Type), sep)
+	}
+	g.w.DecreaseIndent()
+	g.w.Writelnf("]")
+	g.w.DecreaseIndent()
+	g.w.Writelnf("end")
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("end")
+
+	for _, st := range s.Structs {
+		g.makeStruct(&st)
+	}
+
+	for _, e := range s.Enums {
+		g.makeEnum(&e)
+	}
+}
+
+type method struct {
+	name       string
+	params     []string
+	paramNames []string
+	result     string
+	hasStream  bool
+
+	// Descriptors of parameters, and of the values returned, in the order
+	// the client passes and returns them.
+	paramDescriptors  []string
+	returnDescriptors []string
+}
+
+func (g *Generator) makeMethod(m *ast.ServiceMethod) *method {
+	def := &method{name: variableName(m.Name)}
+	var streamDescriptor string
+	for _, p := range m.Params {
+		if p.Stream {
+			def.hasStream = true
+			streamDescriptor = fmt.Sprintf("{:stream, %s}", g.descriptor(p.Type))
+			continue
+		}
+		name := variableName(*p.Name)
+		def.params = append(def.params, fmt.Sprintf("%s :: %s", name, g.typeSpec(p.Type, false)))
+		def.paramNames = append(def.paramNames, name)
+		def.paramDescriptors = append(def.paramDescriptors, g.descriptor(p.Type))
+	}
+	if def.hasStream {
+		def.params = append(def.params, "stream :: Enumerable.t()")
+		def.paramNames = append(def.paramNames, "stream")
+		def.paramDescriptors = append(def.paramDescriptors, streamDescriptor)
+	}
+
+	var results []string
+	var outputStream ast.Type
+	for _, r := range m.Returns {
+		if r.Stream {
+			outputStream = r.Type
+			continue
+		}
+		results = append(results, g.typeSpec(r.Type, false))
+		def.returnDescriptors = append(def.returnDescriptors, g.descriptor(r.Type))
+	}
+	if outputStream != nil {
+		results = append(results, "Enumerable.t()")
+		def.returnDescriptors = append(def.returnDescriptors, fmt.Sprintf("{:stream, %s}", g.descriptor(outputStream)))
+	}
+	if len(results) == 0 {
+		def.result = ":ok | {:error, term()}"
+	} else {
+		def.result = fmt.Sprintf("{:ok, %s} | {:error, term()}", strings.Join(results, ", "))
+	}
+	return def
+}
+
+func (g *Generator) makeService(s *ast.Service) {
+	g.w.Break()
+	g.w.Writelnf("defmodule %s.%s do", g.moduleFor(g.t.Package), strcase.ToCamel(s.Name))
+	g.w.IncreaseIndent()
+	g.writeDoc("moduledoc", s.Comment, s.Annotations)
+	g.w.Writelnf("@doc false")
+	g.w.Writelnf("def __arf_service_id__, do: %q", fmt.Sprintf("%s/%s", g.t.Package, s.Name))
+
+	for _, m := range s.Methods {
+		def := g.makeMethod(m)
+		g.w.Break()
+		g.writeDoc("doc", m.Comment, m.Annotations)
+		g.w.Writelnf("@callback %s(%s) :: %s", def.name, strings.Join(def.params, ", "), def.result)
+	}
+
+	g.w.Break()
+	g.w.Writelnf("@doc false")
+	g.w.Writelnf("def __arf_methods__ do")
+	g.w.IncreaseIndent()
+	g.w.Writelnf("%%{")
+	g.w.IncreaseIndent()
+	for i, m := range s.Methods {
+		sep := ","
+		if i == len(s.Methods)-1 {
+			sep = ""
+		}
+		g.w.Writelnf("%q => :%s%s", m.Name, variableName(m.Name), sep)
+	}
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+	g.w.DecreaseIndent()
+	g.w.Writelnf("end")
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("end")
+}
+
+func (g *Generator) makeClient(s *ast.Service) {
+	g.w.Break()
+	g.w.Writelnf("defmodule %s.%sClient do", g.moduleFor(g.t.Package), strcase.ToCamel(s.Name))
+	g.w.IncreaseIndent()
+	g.writeDoc("moduledoc", s.Comment, s.Annotations)
+	g.w.Writelnf("@service_id %q", fmt.Sprintf("%s/%s", g.t.Package, s.Name))
+
+	for _, m := range s.Methods {
+		def := g.makeMethod(m)
+		g.w.Break()
+		g.writeDoc("doc", m.Comment, m.Annotations)
+
+		specParams := append([]string{"Arf.Transport.t()"}, def.params...)
+		specParams = append(specParams, "keyword()")
+		g.w.Writelnf("@spec %s(%s) :: %s", def.name, strings.Join(specParams, ", "), def.result)
+
+		params := append([]string{"transport"}, def.paramNames...)
+		params = append(params, `opts \\ []`)
+		g.w.Writelnf("def %s(%s) do", def.name, strings.Join(params, ", "))
+		g.w.IncreaseIndent()
+		args := make([]string, 0, len(def.paramNames))
+		for i, name := range def.paramNames {
+			args = append(args, fmt.Sprintf("{%s, %s}", def.paramDescriptors[i], name))
+		}
+		g.w.Writelnf("params = [%s]", strings.Join(args, ", "))
+		g.w.Writelnf("returns = [%s]", strings.Join(def.returnDescriptors, ", "))
+		g.w.Break()
+		g.w.Writelnf("case Arf.Transport.call(transport, @service_id, %q, params, returns, opts) do", m.Name)
+		g.w.IncreaseIndent()
+		if len(def.returnDescriptors) == 0 {
+			g.w.Writelnf("{:ok, []} -> :ok")
+		} else {
+			values := make([]string, 0, len(def.returnDescriptors))
+			for i := range def.returnDescriptors {
+				values = append(values, fmt.Sprintf("value%d", i))
+			}
+			list := strings.Join(values, ", ")
+			g.w.Writelnf("{:ok, [%s]} -> {:ok, %s}", list, list)
+		}
+		g.w.Writelnf("{:error, _} = error -> error")
+		g.w.DecreaseIndent()
+		g.w.Writelnf("end")
+		g.w.DecreaseIndent()
+		g.w.Writelnf("end")
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("end")
+}
+
+func (g *Generator) typeSpec(t ast.Type, field bool) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			return "String.t()"
+		case "bool":
+			return "boolean()"
+		case "float32", "float64":
+			return "float()"
+		case "bytes":
+			return "binary()"
+		case "timestamp":
+			return "DateTime.t()"
+		case "uint8", "uint16", "uint32", "uint64":
+			return "non_neg_integer()"
+		default:
+			return "integer()"
+		}
+	case *ast.OptionalType:
+		return g.typeSpec(v.Type, false) + " | nil"
+	case *ast.ArrayType:
+		return "[" + g.typeSpec(v.Type, false) + "]"
+	case *ast.MapType:
+		return fmt.Sprintf("%%{optional(%s) => %s}", g.typeSpec(v.Key, false), g.typeSpec(v.Value, false))
+	case *ast.SimpleUserType:
+		return g.userTypeSpec(v.ResolvedType, field)
+	case *ast.FullQualifiedType:
+		return g.userTypeSpec(v.ResolvedType, field)
+	default:
+		return "INVALID"
+	}
+}
+
+func (g *Generator) userTypeSpec(obj ast.Object, field bool) string {
+	spec := g.objectModule(obj) + ".t()"
+	// Struct fields default to nil, as defstruct cannot reference structs
+	// that may not have been compiled yet.
+	if _, ok := obj.(*ast.Struct); ok && field {
+		spec += " | nil"
+	}
+	return spec
+}
+
+func (g *Generator) defaultValue(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string", "bytes":
+			return `""`
+		case "bool":
+			return "false"
+		case "float32", "float64":
+			return "0.0"
+		case "timestamp":
+			return "~U[1970-01-01 00:00:00Z]"
+		default:
+			return "0"
+		}
+	case *ast.ArrayType:
+		return "[]"
+	case *ast.MapType:
+		return "%{}"
+	case *ast.SimpleUserType:
+		return g.userDefaultValue(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return g.userDefaultValue(v.ResolvedType)
+	default:
+		return "nil"
+	}
+}
+
+func (g *Generator) userDefaultValue(obj ast.Object) string {
+	if e, ok := obj.(*ast.Enum); ok {
+		return ":" + strcase.ToSnake(e.Members[0].Name)
+	}
+	return "nil"
+}
+
+// descriptor returns the term describing a type to the arf runtime.
+func (g *Generator) descriptor(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		return ":" + v.Name
+	case *ast.OptionalType:
+		return fmt.Sprintf("{:optional, %s}", g.descriptor(v.Type))
+	case *ast.ArrayType:
+		return fmt.Sprintf("{:array, %s}", g.descriptor(v.Type))
+	case *ast.MapType:
+		return fmt.Sprintf("{:map, %s, %s}", g.descriptor(v.Key), g.descriptor(v.Value))
+	case *ast.SimpleUserType:
+		return g.userDescriptor(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return g.userDescriptor(v.ResolvedType)
+	default:
+		return "INVALID"
+	}
+}
+
+func (g *Generator) userDescriptor(obj ast.Object) string {
+	if _, ok := obj.(*ast.Enum); ok {
+		return fmt.Sprintf("{:enum, %s}", g.objectModule(obj))
+	}
+	return fmt.Sprintf("{:struct, %s}", g.objectModule(obj))
+}
@@ -0,0 +1,95 @@
+package arf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/idlfmt"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/urfave/cli/v2"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Fmt formats IDL files provided as arguments. Directories are walked for
+// .arf files.
+func Fmt(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("at least one file or directory must be provided")
+	}
+
+	paths, err := expandPaths(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	write, list, check := c.Bool("write"), c.Bool("list"), c.Bool("check")
+	unformatted := 0
+	var errs []error
+	for _, path := range paths {
+		data, err := idlfmt.FormatFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		changed := !bytes.Equal(src, data)
+		if changed {
+			unformatted++
+		}
+
+		if changed && (list || check) {
+			fmt.Println(path)
+		}
+		if changed && write {
+			if err = os.WriteFile(path, data, 0); err != nil {
+				output.Errorf("Failed writing output file `%s`: %s", path, err)
+			}
+		}
+		if !write && !list && !check {
+			fmt.Print(string(data))
+		}
+	}
+
+	switch {
+	case len(errs) > 0:
+		return cli.Exit(errors.Join(errs...), 1)
+	case check && unformatted > 0:
+		return cli.Exit(fmt.Sprintf("%d file(s) not formatted", unformatted), 1)
+	}
+	return nil
+}
+
+// expandPaths returns the list of files in args, replacing directories by
+// the .arf files they contain.
+func expandPaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		stat, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !stat.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".arf") {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
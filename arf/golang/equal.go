@@ -0,0 +1,169 @@
+package golang
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+)
+
+// makeEqual writes an Equal method for s, comparing fields one by one.
+// Timestamps are compared through time.Time.Equal, and structs through their
+// own Equal methods.
+func (g *Generator) makeEqual(s *ast.Struct, structName string) {
+	g.w.Writelnf("// Equal reports whether x and other hold the same values. Two nil values are equal.")
+	g.w.Writelnf("func (x *%s) Equal(other *%s) bool {", structName, structName)
+	g.w.Writelnf("if x == nil || other == nil { return x == other }")
+	for _, f := range s.Fields {
+		name := strcase.ToCamel(f.Name)
+		g.writeEqual(g.w, "x."+name, "other."+name, f.Type, 0)
+	}
+	g.w.Writelnf("return true")
+	g.w.Writelnf("}")
+	g.w.Break()
+}
+
+// writeEqual writes statements returning false when a and b, addressable
+// expressions of type t, differ.
+func (g *Generator) writeEqual(w *common.Writer, a, b string, t ast.Type, depth int) {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "timestamp":
+			w.Writelnf("if !%s.Equal(%s) { return false }", a, b)
+		case "bytes":
+			g.requirePackage("bytes")
+			w.Writelnf("if !bytes.Equal(%s, %s) { return false }", a, b)
+		default:
+			w.Writelnf("if %s != %s { return false }", a, b)
+		}
+	case *ast.OptionalType:
+		if common.IsUserType(v.Type) {
+			w.Writelnf("if !%s.Equal(%s) { return false }", a, b)
+			return
+		}
+		w.Writelnf("if (%s == nil) != (%s == nil) { return false }", a, b)
+		w.Writelnf("if %s != nil {", a)
+		g.writeEqual(w, "(*"+a+")", "(*"+b+")", v.Type, depth)
+		w.Writelnf("}")
+	case *ast.ArrayType:
+		idx := fmt.Sprintf("i%d", depth)
+		w.Writelnf("if len(%s) != len(%s) { return false }", a, b)
+		w.Writelnf("for %s := range %s {", idx, a)
+		g.writeEqual(w, fmt.Sprintf("%s[%s]", a, idx), fmt.Sprintf("%s[%s]", b, idx), v.Type, depth+1)
+		w.Writelnf("}")
+	case *ast.MapType:
+		key, va, vb := fmt.Sprintf("k%d", depth), fmt.Sprintf("a%d", depth), fmt.Sprintf("b%d", depth)
+		w.Writelnf("if len(%s) != len(%s) { return false }", a, b)
+		w.Writelnf("for %s, %s := range %s {", key, va, a)
+		w.Writelnf("%s, ok := %s[%s]", vb, b, key)
+		w.Writelnf("if !ok { return false }")
+		g.writeEqual(w, va, vb, v.Value, depth+1)
+		w.Writelnf("}")
+	default:
+		if common.IsUserType(t) {
+			w.Writelnf("if !%s.Equal(&%s) { return false }", a, b)
+		} else {
+			w.Writelnf("if %s != %s { return false }", a, b)
+		}
+	}
+}
+
+// makeClone writes a Clone method for s, returning a deep copy of it.
+func (g *Generator) makeClone(s *ast.Struct, structName string) {
+	g.w.Writelnf("// Clone returns a deep copy of x, sharing no memory with it.")
+	g.w.Writelnf("func (x *%s) Clone() *%s {", structName, structName)
+	g.w.Writelnf("if x == nil { return nil }")
+	g.w.Writelnf("c := *x")
+	for _, f := range s.Fields {
+		if !needsDeepCopy(f.Type) {
+			continue
+		}
+		name := strcase.ToCamel(f.Name)
+		g.writeClone(g.w, "c."+name, "x."+name, f.Type, 0)
+	}
+	g.w.Writelnf("return &c")
+	g.w.Writelnf("}")
+	g.w.Break()
+}
+
+// writeClone writes statements assigning a deep copy of src, an addressable
+// expression of type t, to dst.
+func (g *Generator) writeClone(w *common.Writer, dst, src string, t ast.Type, depth int) {
+	if !needsDeepCopy(t) {
+		w.Writelnf("%s = %s", dst, src)
+		return
+	}
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		// Only bytes require a deep copy.
+		g.requirePackage("bytes")
+		w.Writelnf("%s = bytes.Clone(%s)", dst, src)
+	case *ast.OptionalType:
+		if common.IsUserType(v.Type) {
+			w.Writelnf("%s = %s.Clone()", dst, src)
+			return
+		}
+		val := fmt.Sprintf("v%d", depth)
+		w.Writelnf("if %s != nil {", src)
+		if needsDeepCopy(v.Type) {
+			w.Writelnf("var %s %s", val, common.ConvertType(v.Type, g.resolvePackage))
+			g.writeClone(w, val, "(*"+src+")", v.Type, depth+1)
+		} else {
+			w.Writelnf("%s := *%s", val, src)
+		}
+		w.Writelnf("%s = &%s", dst, val)
+		w.Writelnf("}")
+	case *ast.ArrayType:
+		w.Writelnf("if %s != nil {", src)
+		w.Writelnf("%s = make(%s, len(%s))", dst, common.ConvertType(t, g.resolvePackage), src)
+		if needsDeepCopy(v.Type) {
+			idx := fmt.Sprintf("i%d", depth)
+			w.Writelnf("for %s := range %s {", idx, src)
+			g.writeClone(w, fmt.Sprintf("%s[%s]", dst, idx), fmt.Sprintf("%s[%s]", src, idx), v.Type, depth+1)
+			w.Writelnf("}")
+		} else {
+			w.Writelnf("copy(%s, %s)", dst, src)
+		}
+		w.Writelnf("}")
+	case *ast.MapType:
+		key, val := fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		w.Writelnf("if %s != nil {", src)
+		w.Writelnf("%s = make(%s, len(%s))", dst, common.ConvertType(t, g.resolvePackage), src)
+		w.Writelnf("for %s, %s := range %s {", key, val, src)
+		if isNilable(v.Value) {
+			// Nil values are only replaced when present, but their keys must
+			// be kept.
+			w.Writelnf("%s[%s] = %s", dst, key, val)
+		}
+		g.writeClone(w, fmt.Sprintf("%s[%s]", dst, key), val, v.Value, depth+1)
+		w.Writelnf("}")
+		w.Writelnf("}")
+	default:
+		w.Writelnf("%s = *%s.Clone()", dst, src)
+	}
+}
+
+// needsDeepCopy reports whether values of type t may share memory with their
+// copies when assigned.
+func needsDeepCopy(t ast.Type) bool {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		return v.Name == "bytes"
+	case *ast.OptionalType, *ast.ArrayType, *ast.MapType:
+		return true
+	}
+	return common.IsUserType(t)
+}
+
+// isNilable reports whether copies of values of type t are only written by
+// writeClone when the source is not nil.
+func isNilable(t ast.Type) bool {
+	switch v := t.(type) {
+	case *ast.OptionalType:
+		return !common.IsUserType(v.Type)
+	case *ast.ArrayType, *ast.MapType:
+		return true
+	}
+	return false
+}
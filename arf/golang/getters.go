@@ -0,0 +1,47 @@
+package golang
+
+import (
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+)
+
+// getterName returns the name of the getter generated for f.
+func getterName(f ast.StructField) string {
+	return "Get" + strcase.ToCamel(f.Name)
+}
+
+// makeGetters writes a nil-safe getter for each field of s. Getters return
+// the zero value of their type when called on a nil receiver or when an
+// optional field is absent, and return structs as pointers, so calls may be
+// chained.
+func (g *Generator) makeGetters(s *ast.Struct, structName string) {
+	for _, f := range s.Fields {
+		name := strcase.ToCamel(f.Name)
+		inner, optional := unwrapOptional(f.Type)
+		isStruct := common.IsUserType(inner)
+
+		var retType, value, cond string
+		switch {
+		case isStruct && optional:
+			retType, value = common.ConvertType(f.Type, g.resolvePackage), "x."+name
+		case isStruct:
+			retType, value = "*"+common.ConvertType(f.Type, g.resolvePackage), "&x."+name
+		case optional:
+			retType, value, cond = common.ConvertType(inner, g.resolvePackage), "*x."+name, " && x."+name+" != nil"
+		default:
+			retType, value = common.ConvertType(f.Type, g.resolvePackage), "x."+name
+		}
+
+		when := "x is nil"
+		if cond != "" {
+			when += " or " + name + " is unset"
+		}
+		g.w.Writelnf("// %s returns the %s field, or its zero value when %s.", getterName(f), name, when)
+		g.w.Writelnf("func (x *%s) %s() (v %s) {", structName, getterName(f), retType)
+		g.w.Writelnf("if x != nil%s { v = %s }", cond, value)
+		g.w.Writelnf("return")
+		g.w.Writelnf("}")
+		g.w.Break()
+	}
+}
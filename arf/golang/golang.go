@@ -10,11 +10,10 @@ import (
 	"go/format"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
-func NewGenerator(tree *ast.PackageTree) common.Generator {
+func NewGenerator(tree *ast.PackageTree) common.MultiFileGenerator {
 	return &Generator{
 		t: tree,
 		w: &common.Writer{},
@@ -27,63 +26,55 @@ type Generator struct {
 
 	requiredPackages     []string
 	structuresToRegister []string
-	mod                  string
 	packageMapping       map[string]string
 	ctx                  *cli.Context
 }
 
-var goModRegexp = regexp.MustCompile("^module (.*)$")
+// warnedWorkspaces holds go.work files already reported as not listing the
+// module sources are generated into, so each is reported once per run.
+var warnedWorkspaces = map[string]bool{}
 
-func (g *Generator) findGoMod() {
-	if mod := g.ctx.String("golang-module"); mod != "" {
-		g.mod = mod
-		return
+// packageDir returns the directory, relative to the output directory, in
+// which a given arf package is generated.
+func (g *Generator) packageDir(pkg string) string {
+	if p, ok := g.pathForPackage(pkg); ok {
+		return filepath.FromSlash(p)
 	}
-	outputPath := g.ctx.String("output")
-	abs, err := filepath.Abs(outputPath)
-	if err != nil {
-		output.Errorf("Cannot determine absolute path to %s: %s", outputPath, err.Error())
-		os.Exit(1)
-		return
+	comps := strings.Split(pkg, ".")
+	return comps[len(comps)-1]
+}
+
+// packageImportPath returns the import path of the Go package generated for
+// a given arf package. Unless --go-module is provided, the path is computed
+// relative to the root of the module containing the package directory, which
+// may be nested within the output directory.
+func (g *Generator) packageImportPath(pkg string) string {
+	dir := g.packageDir(pkg)
+	if mod := g.ctx.String("go-module"); mod != "" {
+		return strings.TrimSuffix(mod, "/") + "/" + filepath.ToSlash(dir)
 	}
-	components := strings.Split(abs, "/")
-	for len(components) > 1 {
-		modPath := "/" + filepath.Join(append(components, "go.mod")...)
-		data, err := os.ReadFile(modPath)
-		if err != nil {
-			components = components[:len(components)-1]
-			continue
-		}
-		for _, line := range strings.Split(string(data), "\n") {
-			mod := goModRegexp.FindSubmatch([]byte(line))
-			if mod == nil {
-				continue
-			}
-			g.mod = string(mod[1])
-			return
-		}
-		output.Warnf("Invalid go.mod at %s: No module line found.", modPath)
-		components = components[:len(components)-1]
+	path, mod, err := importPath(filepath.Join(g.ctx.String("output"), dir))
+	if err != nil {
+		output.Errorf("%s. Aborting...", err)
 	}
 
-	output.Errorf("Could not find a go.mod in the current tree, nor one was provided through the --go-module flag. Aborting...")
-	os.Exit(1)
-	return
+	workspace, used, err := findWorkspace(mod.Dir)
+	if err != nil {
+		output.Warnf("%s", err)
+	} else if workspace != "" && !used && !warnedWorkspaces[workspace] {
+		warnedWorkspaces[workspace] = true
+		output.Warnf("Module %s at %s is not listed in %s; generated packages will not be found in workspace "+
+			"mode. Add it with `go work use %s`.", mod.Path, mod.Dir, workspace, mod.Dir)
+	}
+	return path
 }
 
 func (g *Generator) resolvePackage(name string, packageName string) string {
-	// Ok, just need to figure out where packageName will be created based on
-	// modPath, and add it to the import list.
-	pathForPackage, ok := g.pathForPackage(packageName)
-	list := strings.Split(pathForPackage, "/")
-	if !ok {
-		list = strings.Split(packageName, ".")
-		g.requirePackage(g.mod + "/" + strings.Join(list, "/"))
-	} else {
-		g.requirePackage(g.mod + "/" + pathForPackage)
+	if packageName == g.t.Package {
+		return ""
 	}
-
-	return list[len(list)-1]
+	g.requirePackage(g.packageImportPath(packageName))
+	return filepath.Base(g.packageDir(packageName))
 }
 
 func (g *Generator) pathForPackage(pkg string) (string, bool) {
@@ -100,15 +91,15 @@ func (g *Generator) pathForPackage(pkg string) (string, bool) {
 
 func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string) {
 	g.ctx = ctx
-	g.findGoMod()
-	pkgComps := strings.Split(g.t.Package, ".")
-	pkg := pkgComps[len(pkgComps)-1]
-	if p, ok := g.pathForPackage(g.t.Package); ok {
-		pkg = p
-	}
+	// Resolving the import path of the generated package validates the
+	// module it belongs to before any code is generated.
+	g.packageImportPath(g.t.Package)
+	dir := g.packageDir(g.t.Package)
+	pkg := filepath.Base(dir)
 
-	targetDir = filepath.Join(ctx.String("output"), pkg)
+	targetDir = filepath.Join(ctx.String("output"), dir)
 	targetFile = pkg + ".arf.go"
+	g.checkCollisions()
 
 	g.requirePackage(
 		"github.com/arf-rpc/arf-go/proto",
@@ -123,16 +114,48 @@ func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, ta
 		g.makeStruct(&s)
 	}
 
+	if len(g.t.Services) > 0 {
+		g.requirePackage("errors")
+		g.w.Writelnf("// ErrUnimplemented is returned by methods of Unimplemented services. Services")
+		g.w.Writelnf("// respond with an Unimplemented status to calls returning it.")
+		g.w.Writelnf("var ErrUnimplemented = errors.New(\"method not implemented\")")
+		g.w.Writelnf("")
+		if ctx.Bool("go-local-clients") {
+			g.makeLocalHelpers()
+		}
+	}
+
 	for _, s := range g.t.Services {
 		g.makeService(&s)
 	}
 
 	for _, s := range g.t.Services {
 		g.makeClient(&s)
+		if ctx.Bool("go-mocks") {
+			g.makeClientInterface(&s)
+		}
 	}
 
 	g.w.Merge(g.makeRegister())
 
+	data = g.formatSource(pkg)
+	return
+}
+
+// GenFiles generates the source file of the package, along with a mock
+// package for its services when --go-mocks is set.
+func (g *Generator) GenFiles(ctx *cli.Context) []common.File {
+	data, targetDir, targetFile := g.GenFile(ctx)
+	files := []common.File{{Data: data, TargetDir: targetDir, TargetFile: targetFile}}
+	if ctx.Bool("go-mocks") && len(g.t.Services) > 0 {
+		files = append(files, g.genMocks(strings.TrimSuffix(targetFile, ".arf.go"), targetDir))
+	}
+	return files
+}
+
+// formatSource prepends the header of package pkg to the source written so
+// far, and returns it formatted.
+func (g *Generator) formatSource(pkg string) []byte {
 	g.w.Merge(g.makeHeader(pkg))
 
 	formatted, err := format.Source([]byte(g.w.String()))
@@ -150,9 +173,7 @@ func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, ta
 		_ = tmpfile.Close()
 		output.Errorf("Failed to format generated file: %s. Generated source has been kept for inspection: %s", err, tmpfile.Name())
 	}
-
-	data = formatted
-	return
+	return formatted
 }
 
 func (g *Generator) requirePackage(name ...string) {
@@ -175,7 +196,12 @@ func (g *Generator) makeHeader(pkg string) *common.Writer {
 			continue
 		}
 		imported[v] = struct{}{}
-		w.Writelnf("%q", v)
+		if strings.Contains(v, " ") {
+			// Aliased imports are required already quoted.
+			w.Writelnf("%s", v)
+		} else {
+			w.Writelnf("%q", v)
+		}
 	}
 
 	w.Writelnf(")")
@@ -208,6 +234,107 @@ func (g *Generator) makeRegister() *common.Writer {
 	return w
 }
 
+// enumConstName returns the name of the constant generated for an enum
+// member. Constants are prefixed by the name of their enum, unless
+// --go-legacy-enum-constants is set.
+func (g *Generator) enumConstName(eName string, m ast.EnumMember) string {
+	if g.ctx.Bool("go-legacy-enum-constants") {
+		return m.Name
+	}
+	return eName + strcase.ToCamel(m.Name)
+}
+
+// checkCollisions emits an error listing package-level identifiers that would
+// be declared more than once by the generated package, such as constants of
+// distinct enums sharing member names.
+func (g *Generator) checkCollisions() {
+	declared := map[string]string{}
+	var collisions []string
+	declare := func(name, what string) {
+		if prev, ok := declared[name]; ok {
+			collisions = append(collisions, fmt.Sprintf("%s: declared for both %s and %s", name, prev, what))
+			return
+		}
+		declared[name] = what
+	}
+	declareEnum := func(e *ast.Enum) {
+		eName := common.EnumName(e)
+		declare(eName, "enum "+e.Name)
+		declare("Parse"+eName, "enum "+e.Name)
+		declare(eName+"Values", "enum "+e.Name)
+		for _, m := range e.Members {
+			// Distinct members of an enum may map to the same constant, such
+			// as FOO_BAR and FooBar, which is reported as any other collision.
+			declare(g.enumConstName(eName, m), fmt.Sprintf("member %s of enum %s", m.Name, e.Name))
+		}
+	}
+	var declareStruct func(s *ast.Struct)
+	declareStruct = func(s *ast.Struct) {
+		for _, st := range s.Structs {
+			declareStruct(&st)
+		}
+		for _, e := range s.Enums {
+			declareEnum(&e)
+		}
+		declare(common.StructName(s), "struct "+s.Name)
+		methods := g.structMethods(s)
+		for _, f := range s.Fields {
+			if name := strcase.ToCamel(f.Name); methods[name] {
+				collisions = append(collisions, fmt.Sprintf("%s: declared for both field %s of struct %s and "+
+					"a method generated for it", name, f.Name, s.Name))
+			}
+		}
+	}
+
+	for _, e := range g.t.Enums {
+		declareEnum(&e)
+	}
+	for _, s := range g.t.Structures {
+		declareStruct(&s)
+	}
+	if len(g.t.Services) > 0 {
+		declare("ErrUnimplemented", "services of package "+g.t.Package)
+		if g.ctx.Bool("go-local-clients") {
+			declare("LocalStatusError", "local clients of package "+g.t.Package)
+		}
+	}
+	for _, s := range g.t.Services {
+		declare(s.Name, "service "+s.Name)
+		declare(s.Name+"Client", "client of service "+s.Name)
+		declare("New"+s.Name+"Client", "client of service "+s.Name)
+		declare("Register"+s.Name, "service "+s.Name)
+		declare("MustRegister"+s.Name, "service "+s.Name)
+		declare("Unimplemented"+s.Name, "service "+s.Name)
+		if g.ctx.Bool("go-mocks") {
+			for _, v := range s.Methods {
+				if m := g.makeDefinition(v); m.HasResponder() {
+					declare("New"+m.ResponderName(), "responder of method "+s.Name+"."+v.Name)
+				}
+			}
+		}
+		if g.ctx.Bool("go-mocks") {
+			declare(s.Name+"ClientInterface", "client of service "+s.Name)
+		}
+		if g.ctx.Bool("go-local-clients") {
+			declare(s.Name+"LocalClient", "client of service "+s.Name)
+			declare("New"+s.Name+"LocalClient", "client of service "+s.Name)
+		}
+	}
+
+	if len(collisions) == 0 {
+		return
+	}
+	hint := "Rename the conflicting declarations"
+	if g.ctx.Bool("go-legacy-enum-constants") {
+		hint += ", or remove --go-legacy-enum-constants to prefix enum constants with the name of their enum"
+	}
+	if g.ctx.Bool("go-getters") {
+		hint += ", or remove --go-getters to omit getters"
+	}
+	output.Errorf("Package %s would declare conflicting Go identifiers:\n  %s\n%s.",
+		g.t.Package, strings.Join(collisions, "\n  "), hint)
+}
+
 func (g *Generator) makeEnum(e *ast.Enum) {
 	g.writeComments(e.Comment)
 	if ann := e.Annotations.ByName("deprecated"); ann != nil {
@@ -223,9 +350,123 @@ func (g *Generator) makeEnum(e *ast.Enum) {
 		if ann := v.Annotations.ByName("deprecated"); ann != nil {
 			g.w.Writelnf("// Deprecated: %s", ann.Arguments[0])
 		}
-		g.w.Writelnf("%s %s = %d", v.Name, eName, v.Value)
+		g.w.Writelnf("%s %s = %d", g.enumConstName(eName, v), eName, v.Value)
 	}
 	g.w.Writelnf(")")
+	g.w.Break()
+	g.makeEnumHelpers(e, eName)
+}
+
+// makeEnumHelpers writes methods converting an enum from and to its textual
+// representation. Members sharing a value with a previous one are accepted
+// when parsing, but the first member declared with a value is used when
+// formatting it. Unknown values are formatted as Name(value), which is also
+// accepted when parsing, so they are preserved across round-trips.
+func (g *Generator) makeEnumHelpers(e *ast.Enum, eName string) {
+	g.requirePackage("fmt", "strconv", "strings")
+	var distinct []ast.EnumMember
+	seen := map[int]bool{}
+	for _, v := range e.Members {
+		if !seen[v.Value] {
+			seen[v.Value] = true
+			distinct = append(distinct, v)
+		}
+	}
+
+	g.w.Writelnf("// String returns the name of x, or %s(n) for unknown values.", eName)
+	g.w.Writelnf("func (x %s) String() string {", eName)
+	g.w.Writelnf("switch x {")
+	for _, v := range distinct {
+		g.w.Writelnf("case %s:", g.enumConstName(eName, v))
+		g.w.Writelnf("return %q", v.Name)
+	}
+	g.w.Writelnf("}")
+	g.w.Writelnf("return \"%s(\" + strconv.Itoa(int(x)) + \")\"", eName)
+	g.w.Writelnf("}")
+	g.w.Break()
+
+	g.w.Writelnf("// Parse%s returns the member of %s named s. Numeric values, either bare", eName, eName)
+	g.w.Writelnf("// or in the format returned by String for unknown values, are also accepted.")
+	g.w.Writelnf("func Parse%s(s string) (%s, error) {", eName, eName)
+	g.w.Writelnf("switch s {")
+	for _, v := range e.Members {
+		g.w.Writelnf("case %q:", v.Name)
+		g.w.Writelnf("return %s, nil", g.enumConstName(eName, v))
+	}
+	g.w.Writelnf("}")
+	g.w.Writelnf("n := s")
+	g.w.Writelnf("if strings.HasPrefix(s, \"%s(\") && strings.HasSuffix(s, \")\") {", eName)
+	g.w.Writelnf("n = s[%d : len(s)-1]", len(eName)+1)
+	g.w.Writelnf("}")
+	g.w.Writelnf("if v, err := strconv.Atoi(n); err == nil {")
+	g.w.Writelnf("return %s(v), nil", eName)
+	g.w.Writelnf("}")
+	g.w.Writelnf("return 0, fmt.Errorf(\"invalid %s value %%q\", s)", eName)
+	g.w.Writelnf("}")
+	g.w.Break()
+
+	g.w.Writelnf("// %sValues returns all known values of %s, in declaration order.", eName, eName)
+	g.w.Writelnf("func %sValues() []%s {", eName, eName)
+	g.w.Writef("return []%s{", eName)
+	for _, v := range distinct {
+		g.w.Writef("%s, ", g.enumConstName(eName, v))
+	}
+	g.w.Writelnf("}")
+	g.w.Writelnf("}")
+	g.w.Break()
+
+	g.w.Writelnf("// IsValid reports whether x is a known value of %s.", eName)
+	g.w.Writelnf("func (x %s) IsValid() bool {", eName)
+	if len(distinct) == 0 {
+		g.w.Writelnf("return false")
+	} else {
+		g.w.Writelnf("switch x {")
+		g.w.Writef("case ")
+		for i, v := range distinct {
+			if i > 0 {
+				g.w.Writef(", ")
+			}
+			g.w.Writef("%s", g.enumConstName(eName, v))
+		}
+		g.w.Writelnf(":")
+		g.w.Writelnf("return true")
+		g.w.Writelnf("}")
+		g.w.Writelnf("return false")
+	}
+	g.w.Writelnf("}")
+	g.w.Break()
+
+	g.w.Writelnf("// MarshalText implements encoding.TextMarshaler.")
+	g.w.Writelnf("func (x %s) MarshalText() ([]byte, error) {", eName)
+	g.w.Writelnf("return []byte(x.String()), nil")
+	g.w.Writelnf("}")
+	g.w.Break()
+
+	g.w.Writelnf("// UnmarshalText implements encoding.TextUnmarshaler.")
+	g.w.Writelnf("func (x *%s) UnmarshalText(text []byte) error {", eName)
+	g.w.Writelnf("v, err := Parse%s(string(text))", eName)
+	g.w.Writelnf("if err != nil {")
+	g.w.Writelnf("return err")
+	g.w.Writelnf("}")
+	g.w.Writelnf("*x = v")
+	g.w.Writelnf("return nil")
+	g.w.Writelnf("}")
+	g.w.Break()
+}
+
+// structMethods returns the names of methods generated for s, which fields
+// may not be named after.
+func (g *Generator) structMethods(s *ast.Struct) map[string]bool {
+	methods := map[string]bool{"ArfStructID": true, "Equal": true, "Clone": true}
+	if needsValidate(s) {
+		methods["Validate"] = true
+	}
+	if g.ctx.Bool("go-getters") {
+		for _, f := range s.Fields {
+			methods[getterName(f)] = true
+		}
+	}
+	return methods
 }
 
 func (g *Generator) makeStruct(s *ast.Struct) {
@@ -250,10 +491,11 @@ func (g *Generator) makeStruct(s *ast.Struct) {
 		if ann := f.Annotations.ByName("deprecated"); ann != nil {
 			g.w.Writelnf("// Deprecated: %s", ann.Arguments[0])
 		}
-		g.w.Writelnf("%s %s `arf:\"%d\"`",
-			strcase.ToCamel(f.Name),
-			common.ConvertType(f.Type, g.resolvePackage),
-			f.ID)
+		fieldType := common.ConvertType(f.Type, g.resolvePackage)
+		if strings.Contains(fieldType, "time.Time") {
+			g.requirePackage("time")
+		}
+		g.w.Writelnf("%s %s `arf:\"%d\"`", strcase.ToCamel(f.Name), fieldType, f.ID)
 	}
 	g.w.Writelnf("}")
 
@@ -262,6 +504,15 @@ func (g *Generator) makeStruct(s *ast.Struct) {
 	g.w.Writelnf("func (%s) ArfStructID() string { return \"%s\" }",
 		structName, common.CanonicalStructName(g.t.Package, s))
 	g.w.Break()
+
+	if needsValidate(s) {
+		g.makeValidate(s, structName)
+	}
+	g.makeEqual(s, structName)
+	g.makeClone(s, structName)
+	if g.ctx.Bool("go-getters") {
+		g.makeGetters(s, structName)
+	}
 }
 
 func (g *Generator) generateMethodResponder(m *common.MethodDefinition) {
@@ -269,16 +520,59 @@ func (g *Generator) generateMethodResponder(m *common.MethodDefinition) {
 	g.requirePackage("github.com/arf-rpc/arf-go")
 	g.requirePackage("github.com/arf-rpc/arf-go/status")
 	g.requirePackage("context")
-	g.w.Writelnf("func make%s(ctx context.Context, c arf.Context) *%s {", name, name)
-	g.w.Writelnf("return &%s{make(chan error), ctx, c}", name)
+	call, send, makeStream := "arf.Context", "x.arfc.SendResponse", "arf.Make"
+	if g.ctx.Bool("go-local-clients") {
+		call, send, makeStream = "*__arfCall", "x.arfc.send", "__arf"
+	}
+	var outputs, args []string
+	for i, t := range m.Output {
+		outputs = append(outputs, fmt.Sprintf("r%d %s", i, common.MaybePointer(t, g.resolvePackage)))
+		args = append(args, fmt.Sprintf("r%d", i))
+	}
+	respond := fmt.Sprintf("func(%s) (%s, error)", strings.Join(outputs, ", "),
+		common.OutStreamer(m.OutputStreamType, g.resolvePackage))
+	// Recv returns pointers to items of any type, so in must as well.
+	var inStream string
+	if m.HasInputStream {
+		inStream = fmt.Sprintf("arf.InStreamer[*%s]", common.ConvertType(m.InputStreamType, g.resolvePackage))
+	}
+	// With --go-mocks, responders may also be created by tests, through
+	// a constructor taking the behaviour of Respond, and the input stream.
+	mocks := g.ctx.Bool("go-mocks")
+
+	g.w.Writelnf("func make%s(ctx context.Context, c %s) *%s {", name, call, name)
+	g.w.Writelnf("return &%s{error: make(chan error), ctx: ctx, arfc: c}", name)
 	g.w.Writelnf("}")
+	if mocks {
+		g.w.Writelnf("// New%s returns a %s for tests of implementations, or", name, name)
+		g.w.Writelnf("// of fakes. Respond calls respond with the values it is called with, returning")
+		if m.HasInputStream {
+			g.w.Writelnf("// its results, and Recv receives items from in.")
+			g.w.Writelnf("func New%s(ctx context.Context, respond %s, in %s) *%s {", name, respond, inStream, name)
+			g.w.Writelnf("return &%s{error: make(chan error), ctx: ctx, respond: respond, in: in}", name)
+		} else {
+			g.w.Writelnf("// its results.")
+			g.w.Writelnf("func New%s(ctx context.Context, respond %s) *%s {", name, respond, name)
+			g.w.Writelnf("return &%s{error: make(chan error), ctx: ctx, respond: respond}", name)
+		}
+		g.w.Writelnf("}")
+	}
 	g.w.Writelnf("type %s struct {", name)
 	g.w.Writelnf("error chan error")
 	g.w.Writelnf("ctx context.Context")
-	g.w.Writelnf("arfc arf.Context")
+	g.w.Writelnf("arfc %s", call)
+	if mocks {
+		g.w.Writelnf("respond %s", respond)
+		if m.HasInputStream {
+			g.w.Writelnf("in %s", inStream)
+		}
+	}
 	g.w.Writelnf("}")
 	if m.HasInputStream {
 		g.w.Writelnf("func (x *%s) Recv() (v *%s, err error) {", name, common.ConvertType(m.InputStreamType, g.resolvePackage))
+		if mocks {
+			g.w.Writelnf("if x.in != nil { return x.in.Recv() }")
+		}
 		g.w.Writelnf("return")
 		g.w.Writelnf("}")
 	}
@@ -296,12 +590,15 @@ func (g *Generator) generateMethodResponder(m *common.MethodDefinition) {
 			g.w.Writef("out %s,", common.OutStreamer(m.OutputStreamType, g.resolvePackage))
 		}
 		g.w.Writelnf("err error) {")
-		g.w.Writef("if err = x.arfc.SendResponse(status.OK, []any{")
+		if mocks {
+			g.w.Writelnf("if x.respond != nil { return x.respond(%s) }", strings.Join(args, ", "))
+		}
+		g.w.Writef("if err = %s(status.OK, []any{", send)
 		for i := range m.Output {
 			g.w.Writef("r%d,", i)
 		}
 		g.w.Writelnf("}, true, nil); err != nil { return }")
-		g.w.Writef("out = arf.MakeOutStream[")
+		g.w.Writef("out = %sOutStream[", makeStream)
 		if common.IsUserType(m.OutputStreamType) {
 			g.w.Writef("*")
 		}
@@ -312,11 +609,20 @@ func (g *Generator) generateMethodResponder(m *common.MethodDefinition) {
 	}
 }
 
+// makeExecutor writes the executor serving calls to m. With
+// --go-local-clients, executors are also used by local clients, so they are
+// served calls through __arfCall rather than arf.Context.
 func (g *Generator) makeExecutor(m *common.MethodDefinition) {
 	g.requirePackage("github.com/arf-rpc/arf-go/status")
-	g.w.Writelnf("func(ctx context.Context, c arf.Context) error {")
+	call, params, send, makeStream := "arf.Context", "_req.Params", "c.SendResponse", "arf.Make"
+	if g.ctx.Bool("go-local-clients") {
+		call, params, send, makeStream = "*__arfCall", "c.params", "c.send", "__arf"
+	}
+	g.w.Writelnf("func(ctx context.Context, c %s) error {", call)
 	if m.HasInput {
-		g.w.Writelnf("_req := c.Request()")
+		if !g.ctx.Bool("go-local-clients") {
+			g.w.Writelnf("_req := c.Request()")
+		}
 		for i := range m.Inputs {
 			if i != 0 {
 				g.w.Writef(",")
@@ -329,12 +635,26 @@ func (g *Generator) makeExecutor(m *common.MethodDefinition) {
 			if i != 0 {
 				g.w.Writef(",")
 			}
-			g.w.Writef("_req.Params[%d].(", i)
+			g.w.Writef("%s[%d].(", params, i)
 			if common.IsUserType(m.Inputs[i].Type) {
 				g.w.Writef("*")
 			}
 			g.w.Writef("%s) ", common.ConvertType(m.Inputs[i].Type, g.resolvePackage))
 		}
+		g.w.Writelnf("")
+		if g.ctx.Bool("go-validate-params") {
+			for i := range m.Inputs {
+				if s := resolvedStruct(m.Inputs[i].Type); s == nil || !needsValidate(s) {
+					continue
+				}
+				// The violation is sent as metadata, so clients may report
+				// which field is invalid, and why.
+				g.w.Writelnf("if err := p%d.Validate(); err != nil {", i)
+				g.w.Writelnf("return %s(status.InvalidArgument, nil, false, "+
+					"map[string][]byte{%q: []byte(err.Error())})", send, validationErrorKey)
+				g.w.Writelnf("}")
+			}
+		}
 	}
 	g.w.Writelnf("")
 
@@ -354,20 +674,20 @@ func (g *Generator) makeExecutor(m *common.MethodDefinition) {
 	switch {
 	//case !m.HasInput && !m.HasOutput && !m.HasInputStream && !m.HasOutputStream:
 	case !m.HasInput && !m.HasOutput && !m.HasInputStream && m.HasOutputStream:
-		g.w.Writef("arf.MakeOutStream[")
+		g.w.Writef("%sOutStream[", makeStream)
 		if common.IsUserType(m.OutputStreamType) {
 			g.w.Writef("*")
 		}
 		g.w.Writef("%s](c),", common.ConvertType(m.OutputStreamType, g.resolvePackage))
 	case !m.HasInput && !m.HasOutput && m.HasInputStream && !m.HasOutputStream:
-		g.w.Writef("arf.MakeInStream[")
+		g.w.Writef("%sInStream[", makeStream)
 		if common.IsUserType(m.InputStreamType) {
 			g.w.Writef("*")
 		}
 		g.w.Writef("%s](c),", common.ConvertType(m.InputStreamType, g.resolvePackage))
 	case !m.HasInput && !m.HasOutput && m.HasInputStream && m.HasOutputStream:
 
-		g.w.Writef("arf.MakeInOutStream[")
+		g.w.Writef("%sInOutStream[", makeStream)
 		if common.IsUserType(m.InputStreamType) {
 			g.w.Writef("*")
 		}
@@ -380,7 +700,7 @@ func (g *Generator) makeExecutor(m *common.MethodDefinition) {
 	case !m.HasInput && m.HasOutput && !m.HasInputStream && m.HasOutputStream:
 		g.w.Writef("_responder")
 	case !m.HasInput && m.HasOutput && m.HasInputStream && !m.HasOutputStream:
-		g.w.Writef("arf.MakeInStream[")
+		g.w.Writef("%sInStream[", makeStream)
 		if common.IsUserType(m.InputStreamType) {
 			g.w.Writef("*")
 		}
@@ -389,19 +709,19 @@ func (g *Generator) makeExecutor(m *common.MethodDefinition) {
 		g.w.Writef("_responder,")
 	//case m.HasInput && !m.HasOutput && !m.HasInputStream && !m.HasOutputStream:
 	case m.HasInput && !m.HasOutput && !m.HasInputStream && m.HasOutputStream:
-		g.w.Writef("arf.MakeOutStream[")
+		g.w.Writef("%sOutStream[", makeStream)
 		if common.IsUserType(m.OutputStreamType) {
 			g.w.Writef("*")
 		}
 		g.w.Writef("%s](c),", common.ConvertType(m.OutputStreamType, g.resolvePackage))
 	case m.HasInput && !m.HasOutput && m.HasInputStream && !m.HasOutputStream:
-		g.w.Writef("arf.MakeInStream[")
+		g.w.Writef("%sInStream[", makeStream)
 		if common.IsUserType(m.InputStreamType) {
 			g.w.Writef("*")
 		}
 		g.w.Writef("%s](c),", common.ConvertType(m.InputStreamType, g.resolvePackage))
 	case m.HasInput && !m.HasOutput && m.HasInputStream && m.HasOutputStream:
-		g.w.Writef("arf.MakeInOutStream[")
+		g.w.Writef("%sInOutStream[", makeStream)
 		if common.IsUserType(m.InputStreamType) {
 			g.w.Writef("*")
 		}
@@ -420,6 +740,10 @@ func (g *Generator) makeExecutor(m *common.MethodDefinition) {
 	}
 
 	g.w.Writelnf(")")
+	g.requirePackage("errors")
+	g.w.Writelnf("if errors.Is(err, ErrUnimplemented) {")
+	g.w.Writelnf("return %s(status.Unimplemented, nil, false, nil)", send)
+	g.w.Writelnf("}")
 	g.w.Writelnf("if err != nil { return err }")
 
 	// At this point, if we have a responder, we need to handle the responder
@@ -427,7 +751,7 @@ func (g *Generator) makeExecutor(m *common.MethodDefinition) {
 	if m.HasResponder() {
 		g.w.Writelnf("return <-_responder.error")
 	} else if len(m.Output) > 0 {
-		g.w.Writef("return c.SendResponse(status.OK, []any{")
+		g.w.Writef("return %s(status.OK, []any{", send)
 		for i := range m.Output {
 			g.w.Writef("r%d,", i)
 		}
@@ -486,19 +810,35 @@ func (g *Generator) makeService(s *ast.Service) {
 	g.requirePackage("github.com/arf-rpc/arf-go")
 	g.requirePackage("context")
 
-	g.w.Writelnf("func Register%s(s arf.Server, i %s) error {", s.Name, s.Name)
-	g.w.Writelnf("__arfRegister%sStructures()", composedPackage(g.t.Package))
-	g.w.Writelnf("return s.RegisterService(arf.ServiceAdapter{")
-	g.w.Writelnf("Methods: map[string]arf.ServiceExecutor{")
 	defs := make([]*common.MethodDefinition, len(s.Methods))
 	for i, v := range s.Methods {
 		defs[i] = g.makeDefinition(v)
 	}
-	for _, m := range defs {
-		g.w.Writef("%q:", m.Name)
-		g.makeExecutor(m)
+	if g.ctx.Bool("go-local-clients") {
+		g.makeExecutors(s, defs)
+	}
+
+	g.w.Writelnf("func Register%s(s arf.Server, i %s) error {", s.Name, s.Name)
+	g.w.Writelnf("__arfRegister%sStructures()", composedPackage(g.t.Package))
+	if g.ctx.Bool("go-local-clients") {
+		g.w.Writelnf("methods := map[string]arf.ServiceExecutor{}")
+		g.w.Writelnf("for name, e := range __arf%sExecutors(i) {", s.Name)
+		g.w.Writelnf("e := e")
+		g.w.Writelnf("methods[name] = func(ctx context.Context, c arf.Context) error {")
+		g.w.Writelnf("return e(ctx, __arfRemoteCall(c))")
+		g.w.Writelnf("}")
+		g.w.Writelnf("}")
+		g.w.Writelnf("return s.RegisterService(arf.ServiceAdapter{")
+		g.w.Writelnf("Methods: methods,")
+	} else {
+		g.w.Writelnf("return s.RegisterService(arf.ServiceAdapter{")
+		g.w.Writelnf("Methods: map[string]arf.ServiceExecutor{")
+		for _, m := range defs {
+			g.w.Writef("%q:", m.Name)
+			g.makeExecutor(m)
+		}
+		g.w.Writelnf("},")
 	}
-	g.w.Writelnf("},")
 	g.w.Writelnf("ServiceID: %q,", fmt.Sprintf("%s/%s", g.t.Package, s.Name))
 	g.w.Writelnf("})")
 	g.w.Writelnf("}")
@@ -522,7 +862,47 @@ func (g *Generator) makeService(s *ast.Service) {
 		}
 		m.BuildInterfaceSignature(g.w, g.resolvePackage)
 	}
+	if g.ctx.Bool("go-require-unimplemented") {
+		g.w.Writelnf("mustEmbedUnimplemented%s()", s.Name)
+	}
 	g.w.Writelnf("}")
+	g.w.Writelnf("")
+	g.makeUnimplemented(s, defs)
+}
+
+// makeUnimplemented writes a struct implementing s, with every method
+// returning ErrUnimplemented. Implementations embedding it keep compiling
+// when methods are added to s.
+func (g *Generator) makeUnimplemented(s *ast.Service, defs []*common.MethodDefinition) {
+	name := "Unimplemented" + s.Name
+	g.requirePackage("fmt")
+	g.w.Writelnf("// %s may be embedded by implementations of %s, so that methods", name, s.Name)
+	g.w.Writelnf("// added to it respond with an Unimplemented status until implemented.")
+	if g.ctx.Bool("go-require-unimplemented") {
+		g.w.Writelnf("// Implementations must embed it to be registered.")
+	}
+	g.w.Writelnf("type %s struct{}", name)
+	g.w.Writelnf("")
+	for _, m := range defs {
+		method := strcase.ToCamel(m.Name)
+		params, results := g.serverSignature(m, g.resolvePackage, "")
+		var types, values []string
+		for _, p := range params {
+			types = append(types, p.typ)
+		}
+		for _, r := range results[:len(results)-1] {
+			values = append(values, r.zero)
+		}
+		values = append(values, fmt.Sprintf("fmt.Errorf(\"%%w: %s.%s\", ErrUnimplemented)", s.Name, method))
+		g.w.Writelnf("func (%s) %s(%s) %s {", name, method, strings.Join(types, ", "), formatResults(results))
+		g.w.Writelnf("return %s", strings.Join(values, ", "))
+		g.w.Writelnf("}")
+		g.w.Writelnf("")
+	}
+	if g.ctx.Bool("go-require-unimplemented") {
+		g.w.Writelnf("func (%s) mustEmbedUnimplemented%s() {}", name, s.Name)
+		g.w.Writelnf("")
+	}
 }
 
 func (g *Generator) makeClient(s *ast.Service) {
@@ -534,6 +914,10 @@ func (g *Generator) makeClient(s *ast.Service) {
 	g.w.Writelnf("}")
 	g.w.Writelnf("")
 
+	if g.ctx.Bool("go-local-clients") {
+		g.makeLocalClient(s)
+	}
+
 	g.w.Writelnf("type %sClient struct {", s.Name)
 	g.w.Writelnf("c arf.Client")
 	g.w.Writelnf("}")
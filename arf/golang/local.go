@@ -0,0 +1,347 @@
+package golang
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+	"strings"
+)
+
+// localHelpers holds declarations shared by executors and local clients of
+// all services in a package. Executors are served calls through __arfCall,
+// which is either backed by the arf.Context of a remote call, or by a
+// __arfLocalCall exchanging parameters, responses and stream items in memory.
+const localHelpers = `// __arfCall is a call served by an executor, made either by a remote client
+// through an arf.Context, or by a local client.
+type __arfCall struct {
+	params []any
+	send   func(code any, params []any, stream bool, metadata map[string][]byte) error
+	remote arf.Context
+	local  *__arfLocalCall
+}
+
+// __arfRemoteCall returns a call served through c.
+func __arfRemoteCall(c arf.Context) *__arfCall {
+	return &__arfCall{params: c.Request().Params, send: __arfSender(c.SendResponse), remote: c}
+}
+
+// __arfSender adapts send to take status codes as any. Codes are only ever
+// provided by executors, so they always hold the type send expects.
+func __arfSender[C any](send func(C, []any, bool, map[string][]byte) error) func(any, []any, bool, map[string][]byte) error {
+	return func(code any, params []any, stream bool, metadata map[string][]byte) error {
+		return send(code.(C), params, stream, metadata)
+	}
+}
+
+func __arfInStream[T any](c *__arfCall) arf.InStreamer[T] {
+	if c.local != nil {
+		return __arfServerStream[T, any](c.local)
+	}
+	return arf.MakeInStream[T](c.remote)
+}
+
+func __arfOutStream[T any](c *__arfCall) arf.OutStreamer[T] {
+	if c.local != nil {
+		return __arfServerStream[any, T](c.local)
+	}
+	return arf.MakeOutStream[T](c.remote)
+}
+
+func __arfInOutStream[I, O any](c *__arfCall) arf.InOutStreamer[I, O] {
+	if c.local != nil {
+		return __arfServerStream[I, O](c.local)
+	}
+	return arf.MakeInOutStream[I, O](c.remote)
+}
+
+// LocalStatusError is returned by local clients when a service responds with
+// a status other than OK. Metadata holds the metadata sent along with it.
+type LocalStatusError struct {
+	Code     any
+	Metadata map[string][]byte
+}
+
+func (e *LocalStatusError) Error() string {
+	return fmt.Sprintf("call failed with status %v", e.Code)
+}
+
+// __arfLocalCall is a call made by a local client, served by an executor
+// running in the background.
+type __arfLocalCall struct {
+	ctx context.Context
+
+	// in and out carry items sent by the client and the service, until
+	// inDone and outDone are closed by either side closing its stream.
+	in, out           chan any
+	inDone, outDone   chan struct{}
+	closeIn, closeOut sync.Once
+
+	// responded is closed once the service responds, or its executor
+	// returns. done is closed once the executor returns.
+	responded chan struct{}
+	respond   sync.Once
+	done      chan struct{}
+
+	mu       sync.Mutex
+	code     any
+	response []any
+	metadata map[string][]byte
+	err      error
+}
+
+// __arfStartLocalCall calls e in the background with params, as a local
+// client would.
+func __arfStartLocalCall(ctx context.Context, e func(context.Context, *__arfCall) error, params ...any) *__arfLocalCall {
+	l := &__arfLocalCall{
+		ctx:       ctx,
+		in:        make(chan any),
+		out:       make(chan any),
+		inDone:    make(chan struct{}),
+		outDone:   make(chan struct{}),
+		responded: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	c := &__arfCall{params: params, send: l.send, local: l}
+	go func() { l.finish(e(ctx, c)) }()
+	return l
+}
+
+func (l *__arfLocalCall) send(code any, params []any, _ bool, metadata map[string][]byte) error {
+	l.respond.Do(func() {
+		l.mu.Lock()
+		l.code, l.response, l.metadata = code, params, metadata
+		l.mu.Unlock()
+		close(l.responded)
+	})
+	return nil
+}
+
+// finish records the error returned by the executor. Executors returning
+// without responding respond with an OK status.
+func (l *__arfLocalCall) finish(err error) {
+	l.mu.Lock()
+	l.err = err
+	l.mu.Unlock()
+	l.send(status.OK, nil, false, nil)
+	l.closeOutput()
+	close(l.done)
+}
+
+func (l *__arfLocalCall) closeInput()  { l.closeIn.Do(func() { close(l.inDone) }) }
+func (l *__arfLocalCall) closeOutput() { l.closeOut.Do(func() { close(l.outDone) }) }
+
+// status returns the error the call failed with, if any. It must only be
+// called once the service responded.
+func (l *__arfLocalCall) status() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.err != nil:
+		return l.err
+	case l.code != status.OK:
+		return &LocalStatusError{Code: l.code, Metadata: l.metadata}
+	}
+	return nil
+}
+
+// result waits for the service to respond, and returns the parameters it
+// responded with.
+func (l *__arfLocalCall) result() ([]any, error) {
+	select {
+	case <-l.responded:
+	case <-l.ctx.Done():
+		return nil, l.ctx.Err()
+	}
+	if err := l.status(); err != nil {
+		return nil, err
+	}
+	return l.response, nil
+}
+
+// streamErr returns the error ending streams received by clients: the error
+// the call failed with, if any, or io.EOF.
+func (l *__arfLocalCall) streamErr() error {
+	select {
+	case <-l.responded:
+		if err := l.status(); err != nil {
+			return err
+		}
+	default:
+	}
+	return io.EOF
+}
+
+// __arfLocalStream is either side of the streams of a local call, receiving
+// items of type R and sending items of type S.
+type __arfLocalStream[R, S any] struct {
+	call               *__arfLocalCall
+	recv, send         chan any
+	recvDone, sendDone chan struct{}
+	peerDone           chan struct{}
+	closeSend          func()
+	recvErr            func() error
+}
+
+// __arfServerStream returns the streams of l as seen by its executor.
+func __arfServerStream[R, S any](l *__arfLocalCall) *__arfLocalStream[R, S] {
+	return &__arfLocalStream[R, S]{
+		call:      l,
+		recv:      l.in,
+		send:      l.out,
+		recvDone:  l.inDone,
+		sendDone:  l.outDone,
+		closeSend: l.closeOutput,
+		recvErr:   func() error { return io.EOF },
+	}
+}
+
+// __arfClientStream returns the streams of l as seen by its client.
+func __arfClientStream[R, S any](l *__arfLocalCall) *__arfLocalStream[R, S] {
+	return &__arfLocalStream[R, S]{
+		call:      l,
+		recv:      l.out,
+		send:      l.in,
+		recvDone:  l.outDone,
+		sendDone:  l.inDone,
+		peerDone:  l.done,
+		closeSend: l.closeInput,
+		recvErr:   l.streamErr,
+	}
+}
+
+func (s *__arfLocalStream[R, S]) Recv() (v R, err error) {
+	select {
+	case item := <-s.recv:
+		v, _ = item.(R)
+		return v, nil
+	case <-s.recvDone:
+		return v, s.recvErr()
+	case <-s.call.ctx.Done():
+		return v, s.call.ctx.Err()
+	}
+}
+
+func (s *__arfLocalStream[R, S]) Send(v S) error {
+	select {
+	case s.send <- v:
+		return nil
+	case <-s.sendDone:
+		return errors.New("send on closed stream")
+	case <-s.peerDone:
+		return errors.New("send on finished call")
+	case <-s.call.ctx.Done():
+		return s.call.ctx.Err()
+	}
+}
+
+func (s *__arfLocalStream[R, S]) Close() error {
+	s.closeSend()
+	return nil
+}
+`
+
+// makeLocalHelpers writes localHelpers, once per package with services.
+func (g *Generator) makeLocalHelpers() {
+	g.requirePackage("context", "errors", "fmt", "io", "sync",
+		"github.com/arf-rpc/arf-go", "github.com/arf-rpc/arf-go/status")
+	g.w.Writef("%s", localHelpers)
+	g.w.Break()
+}
+
+// makeExecutors writes a function returning the executors of s, shared by
+// the service Register function and local clients.
+func (g *Generator) makeExecutors(s *ast.Service, defs []*common.MethodDefinition) {
+	g.w.Writelnf("// __arf%sExecutors returns the executors serving calls to i, for both", s.Name)
+	g.w.Writelnf("// Register%s and %sLocalClient.", s.Name, s.Name)
+	g.w.Writelnf("func __arf%sExecutors(i %s) map[string]func(context.Context, *__arfCall) error {", s.Name, s.Name)
+	g.w.Writelnf("return map[string]func(context.Context, *__arfCall) error{")
+	for _, m := range defs {
+		g.w.Writef("%q:", m.Name)
+		g.makeExecutor(m)
+	}
+	g.w.Writelnf("}")
+	g.w.Writelnf("}")
+	g.w.Writelnf("")
+}
+
+// makeLocalClient writes a client calling an implementation of s in-process.
+// Calls are served by the executors also used by the service Register
+// function, so parameters, streams and statuses are handled as they are for
+// remote calls.
+func (g *Generator) makeLocalClient(s *ast.Service) {
+	name := s.Name + "LocalClient"
+	g.w.Writelnf("// %s calls an implementation of %s in-process, without a network", name, s.Name)
+	g.w.Writelnf("// listener. Calls go through the same executors as services registered through")
+	g.w.Writelnf("// Register%s, and fail with a *LocalStatusError when responded with a status", s.Name)
+	g.w.Writelnf("// other than OK. Call options are ignored.")
+	g.w.Writelnf("type %s struct {", name)
+	g.w.Writelnf("executors map[string]func(context.Context, *__arfCall) error")
+	g.w.Writelnf("}")
+	g.w.Writelnf("")
+	g.w.Writelnf("// New%s returns a client calling impl in-process.", name)
+	g.w.Writelnf("func New%s(impl %s) *%s {", name, s.Name, name)
+	g.w.Writelnf("__arfRegister%sStructures()", composedPackage(g.t.Package))
+	g.w.Writelnf("return &%s{executors: __arf%sExecutors(impl)}", name, s.Name)
+	g.w.Writelnf("}")
+	g.w.Writelnf("")
+
+	for _, v := range s.Methods {
+		m := g.makeDefinition(v)
+		params, results := g.clientSignature(m, g.resolvePackage)
+		var args []string
+		for idx, i := range m.Inputs {
+			args = append(args, inputName(i, idx))
+		}
+		g.w.Writelnf("func (x *%s) %s(%s) %s {", name, strcase.ToCamel(m.Name), formatParams(params), formatResults(results))
+		g.w.Writelnf("_call := __arfStartLocalCall(%s)",
+			strings.Join(append([]string{"ctx", fmt.Sprintf("x.executors[%q]", m.Name)}, args...), ", "))
+
+		var stream string
+		switch {
+		case m.HasInputStream && m.HasOutputStream:
+			stream = fmt.Sprintf("__arfClientStream[%s, %s](_call)",
+				common.MaybePointer(m.OutputStreamType, g.resolvePackage), common.MaybePointer(m.InputStreamType, g.resolvePackage))
+		case m.HasOutputStream:
+			stream = fmt.Sprintf("__arfClientStream[%s, any](_call)", common.MaybePointer(m.OutputStreamType, g.resolvePackage))
+		case m.HasInputStream && m.HasOutput:
+			// Clients of these methods receive a stream instead of sending
+			// one, so the service is served an empty input stream.
+			g.w.Writelnf("_call.closeInput()")
+			stream = fmt.Sprintf("__arfClientStream[%s, any](_call)", common.MaybePointer(m.InputStreamType, g.resolvePackage))
+		case m.HasInputStream:
+			stream = fmt.Sprintf("__arfClientStream[any, %s](_call)", common.MaybePointer(m.InputStreamType, g.resolvePackage))
+		}
+
+		if !m.HasOutput && stream != "" {
+			// Failures of streaming calls are reported by their streams.
+			g.w.Writelnf("return %s, nil", stream)
+			g.w.Writelnf("}")
+			g.w.Writelnf("")
+			continue
+		}
+
+		var zeros, values []string
+		for _, r := range results[:len(results)-1] {
+			zeros = append(zeros, r.zero)
+		}
+		for idx, o := range m.Output {
+			values = append(values, fmt.Sprintf("_params[%d].(%s)", idx, common.MaybePointer(o, g.resolvePackage)))
+		}
+		if stream != "" {
+			values = append(values, stream)
+		}
+		if len(values) == 0 {
+			g.w.Writelnf("_, err := _call.result()")
+			g.w.Writelnf("return err")
+		} else {
+			g.w.Writelnf("_params, err := _call.result()")
+			g.w.Writelnf("if err != nil {")
+			g.w.Writelnf("return %s", strings.Join(append(zeros, "err"), ", "))
+			g.w.Writelnf("}")
+			g.w.Writelnf("return %s", strings.Join(append(values, "nil"), ", "))
+		}
+		g.w.Writelnf("}")
+		g.w.Writelnf("")
+	}
+}
@@ -0,0 +1,418 @@
+package golang
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+	"path/filepath"
+	"strings"
+)
+
+// mockHelpers holds declarations shared by fakes of all services in a mock
+// package.
+const mockHelpers = `// ErrNotConfigured is returned by fakes when a method is called without a
+// function set for it.
+var ErrNotConfigured = errors.New("method not configured")
+
+// Call represents a call made to a fake, along with its arguments. Contexts,
+// streams, responders and call options are not recorded.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Recorder records calls made to fakes embedding it. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Record appends a call to method with the provided arguments.
+func (r *Recorder) Record(method string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns all calls recorded so far, in order.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// CallsTo returns calls recorded for method, in order.
+func (r *Recorder) CallsTo(method string) []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var calls []Call
+	for _, c := range r.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset discards all recorded calls.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
+
+// InStream is an arf.InStreamer yielding Items in order. Once all items are
+// consumed, Recv returns Err, or io.EOF when Err is nil.
+type InStream[T any] struct {
+	Items []T
+	Err   error
+
+	mu  sync.Mutex
+	pos int
+}
+
+// NewInStream returns an InStream yielding the provided items.
+func NewInStream[T any](items ...T) *InStream[T] {
+	return &InStream[T]{Items: items}
+}
+
+func (s *InStream[T]) Recv() (v T, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pos >= len(s.Items) {
+		if s.Err != nil {
+			return v, s.Err
+		}
+		return v, io.EOF
+	}
+	v = s.Items[s.pos]
+	s.pos++
+	return v, nil
+}
+
+// OutStream is an arf.OutStreamer collecting items sent through it. When
+// set, Err is returned by Send instead of collecting items.
+type OutStream[T any] struct {
+	Err error
+
+	mu     sync.Mutex
+	sent   []T
+	closed bool
+}
+
+// NewOutStream returns an empty OutStream.
+func NewOutStream[T any]() *OutStream[T] {
+	return &OutStream[T]{}
+}
+
+func (s *OutStream[T]) Send(v T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.Err != nil:
+		return s.Err
+	case s.closed:
+		return errors.New("send on closed stream")
+	}
+	s.sent = append(s.sent, v)
+	return nil
+}
+
+func (s *OutStream[T]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Sent returns items sent so far, in order.
+func (s *OutStream[T]) Sent() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]T(nil), s.sent...)
+}
+
+// Closed reports whether Close was called.
+func (s *OutStream[T]) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// InOutStream is an arf.InOutStreamer yielding items from its InStream, and
+// collecting items sent into its OutStream.
+type InOutStream[I, O any] struct {
+	*InStream[I]
+	*OutStream[O]
+}
+
+// NewInOutStream returns an InOutStream yielding the provided items.
+func NewInOutStream[I, O any](items ...I) *InOutStream[I, O] {
+	return &InOutStream[I, O]{NewInStream(items...), NewOutStream[O]()}
+}
+
+var (
+	_ arf.InStreamer[any]         = (*InStream[any])(nil)
+	_ arf.OutStreamer[any]        = (*OutStream[any])(nil)
+	_ arf.InOutStreamer[any, any] = (*InOutStream[any, any])(nil)
+)
+`
+
+type goParam struct {
+	name, typ string
+	variadic  bool
+}
+
+type goResult struct {
+	typ, zero string
+}
+
+func inputName(i common.MethodInput, idx int) string {
+	if i.Name == "" {
+		return fmt.Sprintf("p%d", idx)
+	}
+	return i.Name
+}
+
+// zeroValue returns the zero value of t, or nil for pointers, slices and
+// maps.
+func (g *Generator) zeroValue(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			return `""`
+		case "bool":
+			return "false"
+		case "bytes":
+			return "nil"
+		case "timestamp":
+			g.requirePackage("time")
+			return "time.Time{}"
+		}
+		return "0"
+	case *ast.OptionalType, *ast.ArrayType, *ast.MapType:
+		return "nil"
+	}
+	if common.IsUserType(t) {
+		return "nil"
+	}
+	return "0"
+}
+
+// serverSignature returns parameters and results of the method implementing
+// m in a service interface, mirroring common.BuildInterfaceSignature.
+// qualifier prefixes types declared by the package being generated.
+func (g *Generator) serverSignature(m *common.MethodDefinition, resolve common.PackageResolver, qualifier string) ([]goParam, []goResult) {
+	params := []goParam{{name: "ctx", typ: "context.Context"}}
+	for idx, i := range m.Inputs {
+		params = append(params, goParam{name: inputName(i, idx), typ: common.MaybePointer(i.Type, resolve)})
+	}
+	switch {
+	case m.HasResponder():
+		params = append(params, goParam{name: "responder", typ: "*" + qualifier + m.ResponderName()})
+	case m.HasInputStream && m.HasOutputStream:
+		params = append(params, goParam{name: "inOutStream",
+			typ: common.InOutStreamer(m.InputStreamType, m.OutputStreamType, resolve)})
+	case m.HasInputStream:
+		params = append(params, goParam{name: "inStream", typ: common.InStreamer(m.InputStreamType, resolve)})
+	case m.HasOutputStream:
+		params = append(params, goParam{name: "outStream", typ: common.OutStreamer(m.OutputStreamType, resolve)})
+	}
+
+	var results []goResult
+	if !m.HasResponder() {
+		for _, o := range m.Output {
+			results = append(results, goResult{common.MaybePointer(o, resolve), g.zeroValue(o)})
+		}
+	}
+	return params, append(results, goResult{"error", ""})
+}
+
+// clientSignature returns parameters and results of the method generated for
+// m in service clients, mirroring makeClient.
+func (g *Generator) clientSignature(m *common.MethodDefinition, resolve common.PackageResolver) ([]goParam, []goResult) {
+	params := []goParam{{name: "ctx", typ: "context.Context"}}
+	for idx, i := range m.Inputs {
+		params = append(params, goParam{name: inputName(i, idx), typ: common.MaybePointer(i.Type, resolve)})
+	}
+	params = append(params, goParam{name: "opts", typ: "arf.CallOption", variadic: true})
+
+	var results []goResult
+	for _, o := range m.Output {
+		results = append(results, goResult{common.MaybePointer(o, resolve), g.zeroValue(o)})
+	}
+	var stream string
+	switch {
+	case m.HasInputStream && m.HasOutputStream:
+		stream = common.InOutStreamer(m.OutputStreamType, m.InputStreamType, resolve)
+	case m.HasOutputStream:
+		stream = common.InStreamer(m.OutputStreamType, resolve)
+	case m.HasInputStream && m.HasOutput:
+		// Clients of methods taking an input stream and returning values
+		// receive their input stream type.
+		stream = common.InStreamer(m.InputStreamType, resolve)
+	case m.HasInputStream:
+		stream = common.OutStreamer(m.InputStreamType, resolve)
+	}
+	if stream != "" {
+		results = append(results, goResult{stream, "nil"})
+	}
+	return params, append(results, goResult{"error", ""})
+}
+
+func formatParams(params []goParam) string {
+	var s []string
+	for _, p := range params {
+		if p.variadic {
+			s = append(s, p.name+" ..."+p.typ)
+		} else {
+			s = append(s, p.name+" "+p.typ)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+func formatResults(results []goResult) string {
+	if len(results) == 1 {
+		return results[0].typ
+	}
+	var s []string
+	for _, r := range results {
+		s = append(s, r.typ)
+	}
+	return "(" + strings.Join(s, ", ") + ")"
+}
+
+// makeClientInterface writes an interface implemented by the client of s,
+// allowing fakes to be used in its place.
+func (g *Generator) makeClientInterface(s *ast.Service) {
+	name := s.Name + "ClientInterface"
+	if g.ctx.Bool("go-local-clients") {
+		g.w.Writelnf("// %s is implemented by %sClient, %sLocalClient, and by Fake%sClient", name, s.Name, s.Name, s.Name)
+		g.w.Writelnf("// in the mock package.")
+	} else {
+		g.w.Writelnf("// %s is implemented by %sClient, and by Fake%sClient in the mock package.", name, s.Name, s.Name)
+	}
+	g.w.Writelnf("type %s interface {", name)
+	for _, v := range s.Methods {
+		m := g.makeDefinition(v)
+		params, results := g.clientSignature(m, g.resolvePackage)
+		g.w.Writelnf("%s(%s) %s", strcase.ToCamel(m.Name), formatParams(params), formatResults(results))
+	}
+	g.w.Writelnf("}")
+	g.w.Break()
+	g.w.Writelnf("var _ %s = (*%sClient)(nil)", name, s.Name)
+	if g.ctx.Bool("go-local-clients") {
+		g.w.Writelnf("var _ %s = (*%sLocalClient)(nil)", name, s.Name)
+	}
+	g.w.Break()
+}
+
+// genMocks returns a file for the mock package of pkg, placed in a directory
+// named after it within targetDir, the directory of pkg. The mock package
+// holds fakes for each service and for its client.
+func (g *Generator) genMocks(pkg, targetDir string) common.File {
+	g.w = &common.Writer{}
+	g.requiredPackages = nil
+	g.requirePackage("context", "errors", "fmt", "io", "sync", "github.com/arf-rpc/arf-go")
+
+	// The generated package is imported under an alias when its name clashes
+	// with the runtime, or with other imports.
+	alias := pkg
+	switch pkg {
+	case "arf", "context", "errors", "fmt", "io", "sync", "time":
+		alias = pkg + "pkg"
+	}
+	g.requirePackage(fmt.Sprintf("%s %q", alias, g.packageImportPath(g.t.Package)))
+	resolve := func(name string, packageName string) string {
+		if packageName == g.t.Package {
+			return alias
+		}
+		return g.resolvePackage(name, packageName)
+	}
+
+	g.w.Writef("%s", mockHelpers)
+	g.w.Break()
+	for _, s := range g.t.Services {
+		defs := make([]*common.MethodDefinition, len(s.Methods))
+		for i, v := range s.Methods {
+			defs[i] = g.makeDefinition(v)
+		}
+		// Services only accept implementations embedding their Unimplemented
+		// struct when --go-require-unimplemented is set.
+		var embed string
+		if g.ctx.Bool("go-require-unimplemented") {
+			embed = fmt.Sprintf("%s.Unimplemented%s", alias, s.Name)
+		}
+		g.makeFake(s.Name, fmt.Sprintf("%s.%s", alias, s.Name), embed, defs, func(m *common.MethodDefinition) ([]goParam, []goResult) {
+			return g.serverSignature(m, resolve, alias+".")
+		})
+		g.makeFake(s.Name+"Client", fmt.Sprintf("%s.%sClientInterface", alias, s.Name), "", defs, func(m *common.MethodDefinition) ([]goParam, []goResult) {
+			return g.clientSignature(m, resolve)
+		})
+	}
+
+	mockPkg := pkg + "mock"
+	return common.File{
+		Data:       g.formatSource(mockPkg),
+		TargetDir:  filepath.Join(targetDir, mockPkg),
+		TargetFile: mockPkg + ".arf.go",
+	}
+}
+
+// makeFake writes a fake named after name, implementing iface and embedding
+// embed, unless empty. Each method records its call, and calls the function
+// set for it, if any.
+func (g *Generator) makeFake(name, iface, embed string, defs []*common.MethodDefinition,
+	signature func(m *common.MethodDefinition) ([]goParam, []goResult)) {
+	fake := "Fake" + name
+	g.w.Writelnf("// %s is a configurable implementation of %s.", fake, iface)
+	g.w.Writelnf("// Each method calls the function set to the field named after it, or returns")
+	g.w.Writelnf("// ErrNotConfigured when none is set. Calls are recorded regardless.")
+	g.w.Writelnf("type %s struct {", fake)
+	g.w.Writelnf("Recorder")
+	if embed != "" {
+		g.w.Writelnf("%s", embed)
+	}
+	for _, m := range defs {
+		params, results := signature(m)
+		g.w.Writelnf("%sFunc func(%s) %s", strcase.ToCamel(m.Name), formatParams(params), formatResults(results))
+	}
+	g.w.Writelnf("}")
+	g.w.Break()
+	g.w.Writelnf("var _ %s = (*%s)(nil)", iface, fake)
+	g.w.Break()
+
+	for _, m := range defs {
+		method := strcase.ToCamel(m.Name)
+		params, results := signature(m)
+		var args, recorded []string
+		for _, p := range params {
+			arg := p.name
+			if p.variadic {
+				arg += "..."
+			}
+			args = append(args, arg)
+		}
+		for idx, i := range m.Inputs {
+			recorded = append(recorded, inputName(i, idx))
+		}
+		var zeros []string
+		for _, r := range results[:len(results)-1] {
+			zeros = append(zeros, r.zero)
+		}
+		zeros = append(zeros, fmt.Sprintf("fmt.Errorf(\"%%w: %s.%s\", ErrNotConfigured)", name, method))
+
+		g.w.Writelnf("func (x *%s) %s(%s) %s {", fake, method, formatParams(params), formatResults(results))
+		g.w.Writelnf("x.Record(%s)", strings.Join(append([]string{fmt.Sprintf("%q", method)}, recorded...), ", "))
+		g.w.Writelnf("if x.%sFunc != nil {", method)
+		g.w.Writelnf("return x.%sFunc(%s)", method, strings.Join(args, ", "))
+		g.w.Writelnf("}")
+		g.w.Writelnf("return %s", strings.Join(zeros, ", "))
+		g.w.Writelnf("}")
+		g.w.Break()
+	}
+}
@@ -0,0 +1,225 @@
+package golang
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// goModule represents a Go module found on disk.
+type goModule struct {
+	// Path is the module path, as declared by the module directive.
+	Path string
+	// Dir is the absolute path of the directory containing go.mod.
+	Dir string
+}
+
+// goModLines splits the contents of a go.mod or go.work file into lines of
+// tokens, with comments removed. Lines within blocks such as "use ( ... )"
+// are prefixed by the block's verb, so that they read as single directives.
+func goModLines(data []byte) ([][]string, error) {
+	var result [][]string
+	block := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		tokens, err := goModTokens(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch {
+		case len(tokens) == 0:
+			continue
+		case block != "" && len(tokens) == 1 && tokens[0] == ")":
+			block = ""
+		case block != "":
+			result = append(result, append([]string{block}, tokens...))
+		case len(tokens) == 2 && tokens[1] == "(":
+			block = tokens[0]
+		default:
+			result = append(result, tokens)
+		}
+	}
+	if block != "" {
+		return nil, fmt.Errorf("unterminated %s block", block)
+	}
+	return result, nil
+}
+
+// goModTokens splits a single line into tokens, unquoting interpreted and raw
+// strings, and stopping at a // comment.
+func goModTokens(line string) ([]string, error) {
+	var tokens []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		switch {
+		case line == "" || strings.HasPrefix(line, "//"):
+			return tokens, nil
+		case line[0] == '(' || line[0] == ')':
+			tokens = append(tokens, line[:1])
+			line = line[1:]
+		case line[0] == '"' || line[0] == '`':
+			end := 1
+			for end < len(line) && line[end] != line[0] {
+				if line[0] == '"' && line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			value, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", line[:end+1])
+			}
+			tokens = append(tokens, value)
+			line = line[end+1:]
+		default:
+			end := strings.IndexAny(line, " \t\r()\"`")
+			if i := strings.Index(line, "//"); i >= 0 && (end < 0 || i < end) {
+				end = i
+			}
+			if end < 0 {
+				end = len(line)
+			}
+			tokens = append(tokens, line[:end])
+			line = line[end:]
+		}
+	}
+}
+
+// parseGoMod returns the module path declared by a go.mod file.
+func parseGoMod(data []byte) (string, error) {
+	lines, err := goModLines(data)
+	if err != nil {
+		return "", err
+	}
+	for _, tokens := range lines {
+		if tokens[0] != "module" {
+			continue
+		}
+		if len(tokens) != 2 || tokens[1] == "" {
+			return "", fmt.Errorf("invalid module directive")
+		}
+		return tokens[1], nil
+	}
+	return "", fmt.Errorf("no module directive found")
+}
+
+// parseGoWork returns the absolute paths of modules used by a go.work file
+// located in dir.
+func parseGoWork(data []byte, dir string) ([]string, error) {
+	lines, err := goModLines(data)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, tokens := range lines {
+		if tokens[0] != "use" {
+			continue
+		}
+		for _, p := range tokens[1:] {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, filepath.FromSlash(p))
+			}
+			dirs = append(dirs, filepath.Clean(p))
+		}
+	}
+	return dirs, nil
+}
+
+// findModule locates the module containing dir, which does not need to exist,
+// by looking for the closest go.mod file in dir or any of its parents. When no
+// module is found, workspace holds the path of a go.work file found along the
+// way, if any.
+func findModule(dir string) (mod *goModule, workspace string, err error) {
+	for {
+		modPath := filepath.Join(dir, "go.mod")
+		data, readErr := os.ReadFile(modPath)
+		if readErr == nil {
+			path, err := parseGoMod(data)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid go.mod at %s: %w", modPath, err)
+			}
+			return &goModule{Path: path, Dir: dir}, "", nil
+		}
+		if workspace == "" {
+			if _, statErr := os.Stat(filepath.Join(dir, "go.work")); statErr == nil {
+				workspace = filepath.Join(dir, "go.work")
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, workspace, nil
+		}
+		dir = parent
+	}
+}
+
+// findWorkspace locates the go.work file applying to a module in dir, and
+// reports whether the module is listed by it. Returns an empty path when no
+// workspace is found.
+func findWorkspace(dir string) (path string, used bool, err error) {
+	if env := os.Getenv("GOWORK"); env == "off" {
+		return "", false, nil
+	} else if env != "" {
+		path = env
+	} else {
+		for d := dir; ; {
+			if _, err = os.Stat(filepath.Join(d, "go.work")); err == nil {
+				path = filepath.Join(d, "go.work")
+				break
+			}
+			parent := filepath.Dir(d)
+			if parent == d {
+				return "", false, nil
+			}
+			d = parent
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	dirs, err := parseGoWork(data, filepath.Dir(path))
+	if err != nil {
+		return "", false, fmt.Errorf("invalid go.work at %s: %w", path, err)
+	}
+	for _, d := range dirs {
+		if d == dir {
+			return path, true, nil
+		}
+	}
+	return path, false, nil
+}
+
+// importPath returns the Go import path of the package to be generated in
+// dir, taking the module path from the closest go.mod file.
+func importPath(dir string) (string, *goModule, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot determine absolute path to %s: %w", dir, err)
+	}
+	mod, workspace, err := findModule(abs)
+	if err != nil {
+		return "", nil, err
+	}
+	if mod == nil {
+		if workspace != "" {
+			return "", nil, fmt.Errorf("%s is within the workspace defined by %s, but not within any of its modules. "+
+				"Create a module for it, or provide its import path through the --go-module flag", dir, workspace)
+		}
+		return "", nil, fmt.Errorf("could not find a go.mod in %s or any of its parents, nor one was provided "+
+			"through the --go-module flag", dir)
+	}
+	rel, err := filepath.Rel(mod.Dir, abs)
+	if err != nil {
+		return "", nil, err
+	}
+	if rel == "." {
+		return mod.Path, mod, nil
+	}
+	return mod.Path + "/" + filepath.ToSlash(rel), mod, nil
+}
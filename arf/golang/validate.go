@@ -0,0 +1,388 @@
+package golang
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// validationErrorKey is the metadata key under which services validating
+// their parameters send violations to clients.
+const validationErrorKey = "arf-validation-error"
+
+// constraintAnnotations lists annotations checked by generated Validate
+// methods.
+var constraintAnnotations = []string{"min", "max", "len", "pattern", "required", "one_of"}
+
+func annotationArg(a *ast.Annotation) (string, bool) {
+	if len(a.Arguments) == 0 {
+		return "", false
+	}
+	return strings.TrimSpace(fmt.Sprint(a.Arguments[0])), true
+}
+
+// constraintError reports an invalid constraint annotation and exits.
+func constraintError(s *ast.Struct, f ast.StructField, a *ast.Annotation, format string, args ...any) {
+	output.Errorf("%s:%d:%d: @%s on field %s of %s: %s", a.Position.Filename, a.Position.Line, a.Position.Column,
+		a.Name, f.Name, s.Name, fmt.Sprintf(format, args...))
+}
+
+// unwrapOptional returns the type wrapped by t when it is optional.
+func unwrapOptional(t ast.Type) (ast.Type, bool) {
+	if o, ok := t.(*ast.OptionalType); ok {
+		return o.Type, true
+	}
+	return t, false
+}
+
+func primitiveName(t ast.Type) string {
+	if p, ok := t.(*ast.PrimitiveType); ok {
+		return p.Name
+	}
+	return ""
+}
+
+func resolvedEnum(t ast.Type) *ast.Enum {
+	switch v := t.(type) {
+	case *ast.SimpleUserType:
+		e, _ := v.ResolvedType.(*ast.Enum)
+		return e
+	case *ast.FullQualifiedType:
+		e, _ := v.ResolvedType.(*ast.Enum)
+		return e
+	}
+	return nil
+}
+
+func resolvedStruct(t ast.Type) *ast.Struct {
+	switch v := t.(type) {
+	case *ast.SimpleUserType:
+		s, _ := v.ResolvedType.(*ast.Struct)
+		return s
+	case *ast.FullQualifiedType:
+		s, _ := v.ResolvedType.(*ast.Struct)
+		return s
+	}
+	return nil
+}
+
+// parseLenRange parses the argument of @len, which is either an exact length
+// (e.g. "8"), or a range with optional bounds (e.g. "1..64", "..64", "1..").
+// Absent bounds are returned as -1.
+func parseLenRange(arg string) (min, max int, err error) {
+	lo, hi, isRange := strings.Cut(arg, "..")
+	if !isRange {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("invalid length `%s'", arg)
+		}
+		return n, n, nil
+	}
+	min, max = -1, -1
+	if lo = strings.TrimSpace(lo); lo != "" {
+		if min, err = strconv.Atoi(lo); err != nil || min < 0 {
+			return 0, 0, fmt.Errorf("invalid lower bound `%s'", lo)
+		}
+	}
+	if hi = strings.TrimSpace(hi); hi != "" {
+		if max, err = strconv.Atoi(hi); err != nil || max < 0 {
+			return 0, 0, fmt.Errorf("invalid upper bound `%s'", hi)
+		}
+	}
+	if min == -1 && max == -1 || max != -1 && max < min {
+		return 0, 0, fmt.Errorf("invalid range `%s'", arg)
+	}
+	return min, max, nil
+}
+
+// needsValidate reports whether a Validate method is generated for s, which
+// is the case when constraints are declared for any of its fields, or for
+// fields of structs it contains.
+func needsValidate(s *ast.Struct) bool {
+	return hasConstraints(s, map[string]bool{})
+}
+
+// hasConstraints implements needsValidate, skipping structs in visited so
+// recursive structs are only checked once.
+func hasConstraints(s *ast.Struct, visited map[string]bool) bool {
+	if visited[s.FQN()] {
+		return false
+	}
+	visited[s.FQN()] = true
+	for _, f := range s.Fields {
+		for _, name := range constraintAnnotations {
+			if f.Annotations.ByName(name) != nil {
+				return true
+			}
+		}
+		if n := heldStruct(f.Type); n != nil && hasConstraints(n, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// heldStruct returns the struct held by values of type t, either directly,
+// or through optionals, arrays or map values.
+func heldStruct(t ast.Type) *ast.Struct {
+	switch v := t.(type) {
+	case *ast.OptionalType:
+		return heldStruct(v.Type)
+	case *ast.ArrayType:
+		return heldStruct(v.Type)
+	case *ast.MapType:
+		return heldStruct(v.Value)
+	}
+	return resolvedStruct(t)
+}
+
+// makeValidate writes a Validate method for s, checking constraints declared
+// through annotations on its fields, and recursing into fields holding
+// structs. The first violation found is returned, prefixed by the path of
+// the field containing it.
+func (g *Generator) makeValidate(s *ast.Struct, structName string) {
+	body := &common.Writer{}
+	for _, f := range s.Fields {
+		g.validateField(body, s, structName, f)
+	}
+
+	g.w.Writelnf("// Validate checks constraints declared for fields of %s, including those of", structName)
+	g.w.Writelnf("// structs it contains. Returns the first violation found, if any.")
+	g.w.Writelnf("func (x *%s) Validate() error {", structName)
+	g.w.Writelnf("if x == nil { return nil }")
+	g.w.Writef("%s", body.String())
+	g.w.Writelnf("return nil")
+	g.w.Writelnf("}")
+	g.w.Break()
+}
+
+func (g *Generator) validateField(w *common.Writer, s *ast.Struct, structName string, f ast.StructField) {
+	expr := "x." + strcase.ToCamel(f.Name)
+	inner, optional := unwrapOptional(f.Type)
+	prim := primitiveName(inner)
+
+	checks := &common.Writer{}
+	for _, name := range constraintAnnotations {
+		a := f.Annotations.ByName(name)
+		if a == nil {
+			continue
+		}
+		arg, hasArg := annotationArg(a)
+		value := expr
+		if optional {
+			value = "(*" + expr + ")"
+		}
+
+		switch name {
+		case "required":
+			if !optional {
+				constraintError(s, f, a, "only optional fields may be required")
+			}
+			w.Writelnf("if %s == nil {", expr)
+			w.Writelnf("return errors.New(%q)", f.Name+": is required")
+			w.Writelnf("}")
+			g.requirePackage("errors")
+			continue
+
+		case "min", "max":
+			if !hasArg {
+				constraintError(s, f, a, "a bound must be provided")
+			}
+			if !isNumeric(prim) {
+				constraintError(s, f, a, "only numeric fields may be bounded")
+			}
+			if err := checkBound(prim, arg); err != nil {
+				constraintError(s, f, a, "%s", err)
+			}
+			op, desc := "<", "at least"
+			if name == "max" {
+				op, desc = ">", "at most"
+			}
+			checks.Writelnf("if %s %s %s {", value, op, arg)
+			checks.Writelnf("return fmt.Errorf(\"%s: must be %s %s, got %%v\", %s)", f.Name, desc, arg, value)
+			checks.Writelnf("}")
+
+		case "len":
+			if !hasArg {
+				constraintError(s, f, a, "a length or range must be provided")
+			}
+			min, max, err := parseLenRange(arg)
+			if err != nil {
+				constraintError(s, f, a, "%s", err)
+			}
+			var length string
+			switch {
+			case prim == "string":
+				g.requirePackage("unicode/utf8")
+				length = fmt.Sprintf("utf8.RuneCountInString(%s)", value)
+			case prim == "bytes":
+				length = fmt.Sprintf("len(%s)", value)
+			case !optional && isCollection(inner):
+				length = fmt.Sprintf("len(%s)", expr)
+			default:
+				constraintError(s, f, a, "only strings, bytes, arrays and maps have a length")
+			}
+			var cond []string
+			if min > 0 {
+				cond = append(cond, fmt.Sprintf("n < %d", min))
+			}
+			if max >= 0 {
+				cond = append(cond, fmt.Sprintf("n > %d", max))
+			}
+			if len(cond) == 0 {
+				continue
+			}
+			var desc string
+			switch {
+			case min == max:
+				desc = fmt.Sprintf("length must be %d", min)
+			case max < 0:
+				desc = fmt.Sprintf("length must be at least %d", min)
+			case min <= 0:
+				desc = fmt.Sprintf("length must be at most %d", max)
+			default:
+				desc = fmt.Sprintf("length must be between %d and %d", min, max)
+			}
+			checks.Writelnf("if n := %s; %s {", length, strings.Join(cond, " || "))
+			checks.Writelnf("return fmt.Errorf(\"%s: %s, got %%d\", n)", f.Name, desc)
+			checks.Writelnf("}")
+
+		case "pattern":
+			if !hasArg {
+				constraintError(s, f, a, "a regular expression must be provided")
+			}
+			if prim != "string" {
+				constraintError(s, f, a, "only string fields may be matched against a pattern")
+			}
+			if _, err := regexp.Compile(arg); err != nil {
+				constraintError(s, f, a, "invalid regular expression: %s", err)
+			}
+			g.requirePackage("regexp")
+			varName := fmt.Sprintf("__arfPattern%s%s", structName, strcase.ToCamel(f.Name))
+			g.w.Writelnf("var %s = regexp.MustCompile(%q)", varName, arg)
+			checks.Writelnf("if !%s.MatchString(%s) {", varName, value)
+			checks.Writelnf("return fmt.Errorf(\"%s: must match %%s\", %s)", f.Name, varName)
+			checks.Writelnf("}")
+
+		case "one_of":
+			e := resolvedEnum(inner)
+			if e == nil {
+				constraintError(s, f, a, "only enum fields may be restricted to a set of members")
+			}
+			if !hasArg {
+				checks.Writelnf("if !%s.IsValid() {", value)
+				checks.Writelnf("return fmt.Errorf(\"%s: unknown value %%v\", %s)", f.Name, value)
+				checks.Writelnf("}")
+				continue
+			}
+			typeName := common.ConvertType(inner, g.resolvePackage)
+			qualifier := ""
+			if i := strings.LastIndex(typeName, "."); i >= 0 {
+				qualifier = typeName[:i+1]
+			}
+			var members, names []string
+			for _, m := range strings.FieldsFunc(arg, func(r rune) bool { return r == ',' || r == '|' || r == ' ' }) {
+				var member *ast.EnumMember
+				for i := range e.Members {
+					if e.Members[i].Name == m {
+						member = &e.Members[i]
+					}
+				}
+				if member == nil {
+					constraintError(s, f, a, "enum %s has no member %s", e.Name, m)
+				}
+				members = append(members, qualifier+g.enumConstName(common.EnumName(e), *member))
+				names = append(names, m)
+			}
+			if len(members) == 0 {
+				constraintError(s, f, a, "at least one member must be provided")
+			}
+			checks.Writelnf("switch %s {", value)
+			checks.Writelnf("case %s:", strings.Join(members, ", "))
+			checks.Writelnf("default:")
+			checks.Writelnf("return fmt.Errorf(\"%s: must be one of %s, got %%v\", %s)", f.Name, strings.Join(names, ", "), value)
+			checks.Writelnf("}")
+		}
+	}
+
+	if c := checks.String(); c != "" {
+		g.requirePackage("fmt")
+		if optional {
+			w.Writelnf("if %s != nil {", expr)
+			w.Writef("%s", c)
+			w.Writelnf("}")
+		} else {
+			w.Writef("%s", c)
+		}
+	}
+
+	g.validateNested(w, expr, f.Name, nil, f.Type, 0)
+}
+
+// validateNested writes calls to Validate for structs held by expr, which is
+// of type t, unless they have no Validate method. path is the format used to
+// prefix errors with the path of expr, taking args as its arguments.
+func (g *Generator) validateNested(w *common.Writer, expr, path string, args []string, t ast.Type, depth int) {
+	if n := heldStruct(t); n == nil || !needsValidate(n) {
+		return
+	}
+	g.requirePackage("fmt")
+	switch v := t.(type) {
+	case *ast.OptionalType:
+		g.validateNested(w, expr, path, args, v.Type, depth)
+	case *ast.ArrayType:
+		idx := fmt.Sprintf("i%d", depth)
+		w.Writelnf("for %s := range %s {", idx, expr)
+		g.validateNested(w, fmt.Sprintf("%s[%s]", expr, idx), path+"[%d]", append(args, idx), v.Type, depth+1)
+		w.Writelnf("}")
+	case *ast.MapType:
+		key, val := fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		w.Writelnf("for %s, %s := range %s {", key, val, expr)
+		g.validateNested(w, val, path+"[%v]", append(args, key), v.Value, depth+1)
+		w.Writelnf("}")
+	default:
+		w.Writelnf("if err := %s.Validate(); err != nil {", expr)
+		w.Writelnf("return fmt.Errorf(\"%s.%%w\", %s)", path, strings.Join(append(args, "err"), ", "))
+		w.Writelnf("}")
+	}
+}
+
+func isNumeric(prim string) bool {
+	switch prim {
+	case "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+		return true
+	}
+	return false
+}
+
+func isCollection(t ast.Type) bool {
+	switch t.(type) {
+	case *ast.ArrayType, *ast.MapType:
+		return true
+	}
+	return false
+}
+
+// checkBound ensures a bound is representable by a given primitive type.
+func checkBound(prim, arg string) error {
+	var err error
+	switch {
+	case strings.HasPrefix(prim, "float"):
+		_, err = strconv.ParseFloat(arg, 64)
+	case strings.HasPrefix(prim, "uint"):
+		bits, _ := strconv.Atoi(strings.TrimPrefix(prim, "uint"))
+		_, err = strconv.ParseUint(arg, 10, bits)
+	default:
+		bits, _ := strconv.Atoi(strings.TrimPrefix(prim, "int"))
+		_, err = strconv.ParseInt(arg, 10, bits)
+	}
+	if err != nil {
+		return fmt.Errorf("`%s' is not a valid %s", arg, prim)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package idlfmt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/arf-rpc/idl"
+	"github.com/arf-rpc/idl/ast"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FormatFile parses the IDL file at path, along with its imports, and returns
+// its canonical representation. Comments placed before the package
+// declaration are kept as a header, while others are kept next to the
+// declaration closest to them, as described by attachComments.
+func FormatFile(path string) ([]byte, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	tree, err := idl.ParseFile(abs, func(err error) {
+		errs = append(errs, err)
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file := findFile(tree, abs)
+	if file == nil {
+		return nil, fmt.Errorf("%s: file not found in parsed tree", path)
+	}
+	return FormatSource(src, file), nil
+}
+
+// FormatSource returns the canonical representation of file, parsed from src.
+func FormatSource(src []byte, file *ast.File) []byte {
+	lines := scanLines(string(src))
+	loose := attachComments(file, lines)
+
+	var header []string
+	for _, l := range lines {
+		if l.number >= file.Package.Position.Line {
+			break
+		}
+		if l.comment != "" {
+			header = append(header, strings.TrimRight(l.comment, " \t\r"))
+		} else if len(header) > 0 {
+			header = append(header, "")
+		}
+	}
+	for len(header) > 0 && header[len(header)-1] == "" {
+		header = header[:len(header)-1]
+	}
+	if len(header) == 0 {
+		return format(file, loose)
+	}
+	return []byte(strings.Join(header, "\n") + "\n\n" + string(format(file, loose)))
+}
+
+func findFile(tree *ast.Tree, path string) *ast.File {
+	for _, pkg := range tree.Packages {
+		for _, f := range pkg.Files {
+			if f.Path == path {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// sourceLine represents a line of an IDL file, split into code and comment.
+// depth and end hold the number of braces open at its beginning and end.
+type sourceLine struct {
+	number  int
+	code    string
+	comment string
+	depth   int
+	end     int
+}
+
+// scanLines splits src into lines, separating comments from code while
+// ignoring comment markers and braces placed within strings.
+func scanLines(src string) []sourceLine {
+	var res []sourceLine
+	depth := 0
+	for i, text := range strings.Split(src, "\n") {
+		l := sourceLine{number: i + 1, code: text, depth: depth}
+		var quote rune
+		escaping := false
+		for j, r := range text {
+			switch {
+			case escaping:
+				escaping = false
+			case quote != 0 && r == '\\':
+				escaping = true
+			case quote != 0 && r == quote:
+				quote = 0
+			case quote != 0:
+			case r == '"' || r == '\'':
+				quote = r
+			case r == '{':
+				depth++
+			case r == '}':
+				depth--
+			case r == '#':
+				l.code, l.comment = text[:j], text[j:]
+			}
+			if l.comment != "" {
+				break
+			}
+		}
+		l.code = strings.TrimSpace(l.code)
+		l.end = depth
+		res = append(res, l)
+	}
+	return res
+}
+
+// declaration represents an item of a file comments may be attached to.
+type declaration struct {
+	ptr any
+	// line is the line holding the name of the declaration, and start the
+	// first one holding its annotations.
+	line, start int
+	// depth is the number of declarations enclosing this one.
+	depth int
+	// comment holds the comments preceding the declaration, and is nil for
+	// items unable to hold them.
+	comment *[]string
+}
+
+// declarations returns the items of file comments may be attached to, with
+// enclosing declarations preceding the ones they hold.
+func declarations(file *ast.File) []declaration {
+	decls := []declaration{{ptr: &file.Package, line: file.Package.Position.Line, start: file.Package.Position.Line}}
+	for i := range file.Imports {
+		imp := &file.Imports[i]
+		decls = append(decls, declaration{ptr: imp, line: imp.Position.Line, start: imp.Position.Line})
+	}
+	add := func(ptr any, pos ast.Position, set ast.AnnotationSet, comment *[]string, depth int) {
+		decls = append(decls, declaration{
+			ptr:     ptr,
+			line:    pos.Line,
+			start:   startLine(pos.Line, nil, set),
+			depth:   depth,
+			comment: comment,
+		})
+	}
+	addEnum := func(e *ast.Enum, depth int) {
+		add(e, e.Position, e.Annotations, &e.Comment, depth)
+		for i := range e.Members {
+			m := &e.Members[i]
+			add(m, m.Position, m.Annotations, &m.Comment, depth+1)
+		}
+	}
+	var addStruct func(s *ast.Struct, depth int)
+	addStruct = func(s *ast.Struct, depth int) {
+		add(s, s.Position, s.Annotations, &s.Comment, depth)
+		for i := range s.Fields {
+			f := &s.Fields[i]
+			add(f, f.Position, f.Annotations, &f.Comment, depth+1)
+		}
+		for i := range s.Structs {
+			addStruct(&s.Structs[i], depth+1)
+		}
+		for i := range s.Enums {
+			addEnum(&s.Enums[i], depth+1)
+		}
+	}
+
+	for i := range file.Structs {
+		addStruct(&file.Structs[i], 0)
+	}
+	for i := range file.Enums {
+		addEnum(&file.Enums[i], 0)
+	}
+	for i := range file.Services {
+		s := &file.Services[i]
+		add(s, s.Position, s.Annotations, &s.Comment, 0)
+		for _, m := range s.Methods {
+			add(m, m.Position, m.Annotations, &m.Comment, 1)
+		}
+	}
+	return decls
+}
+
+// commentBlock represents consecutive lines of comments not attached to any
+// declaration.
+type commentBlock struct {
+	first, last int
+	depth       int
+	text        []string
+}
+
+// attachComments replaces the comments of each declaration of file by the
+// lines of comments immediately preceding it, as the parser may also attach
+// comments placed after the previous declaration. Remaining comments after
+// the package declaration are attached to the closest declaration: comments
+// following code are kept after the declaration placed in their line, while
+// other blocks are placed before the next declaration within the same pair
+// of braces, after the previous one when they close it, or within the
+// enclosing declaration when it holds nothing else.
+func attachComments(file *ast.File, lines []sourceLine) map[any]*looseComments {
+	decls := declarations(file)
+	pkgLine := file.Package.Position.Line
+	loose := map[any]*looseComments{}
+	get := func(ptr any) *looseComments {
+		if loose[ptr] == nil {
+			loose[ptr] = &looseComments{}
+		}
+		return loose[ptr]
+	}
+
+	// attached is indexed by line numbers minus one. Comments preceding the
+	// package declaration are kept as a header.
+	attached := make([]bool, len(lines))
+	for i := 0; i < pkgLine-1 && i < len(lines); i++ {
+		attached[i] = true
+	}
+	for _, d := range decls {
+		if d.comment == nil {
+			continue
+		}
+		var c []string
+		for i := d.start - 2; i >= pkgLine && lines[i].code == "" && lines[i].comment != "" && !attached[i]; i-- {
+			c = append([]string{strings.TrimPrefix(lines[i].comment, "#")}, c...)
+			attached[i] = true
+		}
+		*d.comment = c
+	}
+
+	byLine := map[int]declaration{}
+	for i := len(decls) - 1; i >= 0; i-- {
+		byLine[decls[i].line] = decls[i]
+	}
+	var blocks []commentBlock
+	for i, l := range lines {
+		if l.comment == "" || attached[i] {
+			continue
+		}
+		text := strings.TrimPrefix(l.comment, "#")
+		switch n := len(blocks); {
+		case l.code != "":
+			if d, ok := byLine[l.number]; ok {
+				get(d.ptr).trailing = text
+				continue
+			}
+			// Comments following a closing brace belong to the block
+			// enclosing it.
+			blocks = append(blocks, commentBlock{first: l.number, last: l.number, depth: l.end, text: []string{text}})
+		case n > 0 && blocks[n-1].last == l.number-1 && lines[i-1].code == "":
+			blocks[n-1].last = l.number
+			blocks[n-1].text = append(blocks[n-1].text, text)
+		default:
+			blocks = append(blocks, commentBlock{first: l.number, last: l.number, depth: l.depth, text: []string{text}})
+		}
+	}
+
+	for _, b := range blocks {
+		// open and end are the lines opening and following the pair of
+		// braces holding b.
+		open, end := 0, len(lines)+1
+		for i := b.first - 2; i >= 0; i-- {
+			if lines[i].depth < b.depth {
+				open = i + 1
+				break
+			}
+		}
+		for i := b.last; i < len(lines); i++ {
+			if lines[i].depth < b.depth {
+				end = i + 1
+				break
+			}
+		}
+
+		var next, prev, owner *declaration
+		for i := range decls {
+			d := &decls[i]
+			switch {
+			case d.depth == b.depth && d.line > b.last && d.line < end:
+				if next == nil || d.line < next.line {
+					next = d
+				}
+			case d.depth == b.depth && d.line < b.first && d.line > open:
+				if prev == nil || d.line > prev.line {
+					prev = d
+				}
+			case d.depth == b.depth-1 && d.line <= open:
+				if owner == nil || d.line > owner.line {
+					owner = d
+				}
+			}
+		}
+		switch {
+		case next != nil:
+			get(next.ptr).before = append(get(next.ptr).before, b.text)
+		case prev != nil:
+			get(prev.ptr).after = append(get(prev.ptr).after, b.text)
+		case owner != nil:
+			get(owner.ptr).inner = append(get(owner.ptr).inner, b.text)
+		default:
+			get(&file.Package).after = append(get(&file.Package).after, b.text)
+		}
+	}
+	return loose
+}
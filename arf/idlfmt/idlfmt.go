@@ -0,0 +1,385 @@
+package idlfmt
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/idl/ast"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const indentation = "    "
+
+// Format returns the canonical representation of a given IDL file. Items are
+// emitted in the order they were declared, as reported by their positions.
+func Format(file *ast.File) []byte {
+	return format(file, nil)
+}
+
+// looseComments holds comments the parser does not attach to a declaration,
+// along with where they must be emitted relative to the declaration they
+// were attached to by attachComments.
+type looseComments struct {
+	// before holds blocks of comments preceding the declaration, separated
+	// from it by a blank line.
+	before [][]string
+	// trailing holds a comment placed after the declaration in its line.
+	trailing string
+	// after holds blocks of comments following the declaration, which
+	// close the block it belongs to.
+	after [][]string
+	// inner holds blocks of comments placed in the body of a declaration
+	// without members.
+	inner [][]string
+}
+
+// printer writes declarations along with the loose comments attached to
+// them, keyed by a pointer to each declaration.
+type printer struct {
+	w     *common.Writer
+	loose map[any]*looseComments
+}
+
+func format(file *ast.File, loose map[any]*looseComments) []byte {
+	p := &printer{w: &common.Writer{Indentation: indentation}, loose: loose}
+	p.line(&file.Package, "package %s;", file.Package.Value)
+	p.writeAfterBody(&file.Package)
+
+	if len(file.Imports) > 0 {
+		p.w.Break()
+	}
+	for i := range file.Imports {
+		imp := &file.Imports[i]
+		if i > 0 && len(p.comments(imp).before) > 0 {
+			p.w.Break()
+		}
+		p.writeBefore(imp)
+		p.line(imp, "import %s;", quote(imp.Value))
+		p.writeAfterBody(imp)
+	}
+
+	var items []item
+	for i := range file.Structs {
+		items = append(items, item{pos: file.Structs[i].Position, st: &file.Structs[i]})
+	}
+	for i := range file.Enums {
+		items = append(items, item{pos: file.Enums[i].Position, enum: &file.Enums[i]})
+	}
+	for i := range file.Services {
+		items = append(items, item{pos: file.Services[i].Position, service: &file.Services[i]})
+	}
+	sortItems(items)
+
+	for _, it := range items {
+		p.w.Break()
+		switch {
+		case it.st != nil:
+			p.writeStruct(it.st)
+		case it.enum != nil:
+			p.writeEnum(it.enum)
+		case it.service != nil:
+			p.writeService(it.service)
+		}
+	}
+
+	return []byte(p.w.String())
+}
+
+func (p *printer) comments(decl any) *looseComments {
+	if c, ok := p.loose[decl]; ok {
+		return c
+	}
+	return &looseComments{}
+}
+
+// line writes a line representing decl, followed by its trailing comment.
+func (p *printer) line(decl any, format string, args ...any) {
+	p.w.Writef(format, args...)
+	if c := p.comments(decl).trailing; c != "" {
+		p.w.Writef(" %s", commentLine(c))
+	}
+	p.w.Writelnf("")
+}
+
+func (p *printer) writeBlocks(blocks [][]string) {
+	for i, b := range blocks {
+		if i > 0 {
+			p.w.Break()
+		}
+		writeComments(p.w, b)
+	}
+}
+
+func (p *printer) writeBefore(decl any) {
+	if before := p.comments(decl).before; len(before) > 0 {
+		p.writeBlocks(before)
+		p.w.Break()
+	}
+}
+
+func (p *printer) writeAfter(decl any) {
+	p.writeBlocks(p.comments(decl).after)
+}
+
+// writeAfterBody writes comments following a top-level declaration or one
+// ending with a closing brace, which are set apart from it.
+func (p *printer) writeAfterBody(decl any) {
+	if len(p.comments(decl).after) > 0 {
+		p.w.Break()
+		p.writeAfter(decl)
+	}
+}
+
+// open writes the first line of a declaration holding members, collapsing
+// its body when it has neither members nor comments within it.
+func (p *printer) open(decl any, kind, name string, members int) bool {
+	if members == 0 && len(p.comments(decl).inner) == 0 {
+		p.line(decl, "%s %s {}", kind, name)
+		return false
+	}
+	p.line(decl, "%s %s {", kind, name)
+	p.w.IncreaseIndent()
+	p.writeBlocks(p.comments(decl).inner)
+	return true
+}
+
+func (p *printer) close(decl any) {
+	p.w.DecreaseIndent()
+	p.w.Writelnf("}")
+	p.writeAfterBody(decl)
+}
+
+// item represents a declaration that may be placed among others of different
+// kinds.
+type item struct {
+	pos     ast.Position
+	field   *ast.StructField
+	st      *ast.Struct
+	enum    *ast.Enum
+	service *ast.Service
+}
+
+func sortItems(items []item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].pos, items[j].pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+}
+
+// startLine returns the line in which a declaration placed at line begins,
+// including its comments and annotations.
+func startLine(line int, comments []string, set ast.AnnotationSet) int {
+	for _, a := range set {
+		if a.Position.Line > 0 && a.Position.Line < line {
+			line = a.Position.Line
+		}
+	}
+	return line - len(comments)
+}
+
+// separated reports whether a declaration starting at start was separated
+// from the previous one, ending at prev, by blank lines. Those are preserved
+// as a single blank line.
+func separated(prev, start int) bool {
+	return prev > 0 && start > prev+1
+}
+
+var numberPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// commentLine returns the canonical representation of a line of comment,
+// given its text without the leading #.
+func commentLine(l string) string {
+	l = strings.TrimRight(l, " \t\r")
+	switch {
+	case l == "", strings.HasPrefix(l, " "), strings.HasPrefix(l, "#"):
+		return "#" + l
+	default:
+		return "# " + l
+	}
+}
+
+func writeComments(w *common.Writer, c []string) {
+	for _, l := range c {
+		w.Writelnf("%s", commentLine(l))
+	}
+}
+
+func writeAnnotations(w *common.Writer, set ast.AnnotationSet) {
+	for _, a := range set {
+		if len(a.Arguments) == 0 {
+			w.Writelnf("@%s", a.Name)
+			continue
+		}
+		args := make([]string, len(a.Arguments))
+		for i, arg := range a.Arguments {
+			str := fmt.Sprint(arg)
+			if _, isString := arg.(string); !isString || !numberPattern.MatchString(str) {
+				str = quote(str)
+			}
+			args[i] = str
+		}
+		w.Writelnf("@%s(%s)", a.Name, strings.Join(args, ", "))
+	}
+}
+
+// TypeName returns the IDL representation of a given type.
+func TypeName(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		return v.Name
+	case *ast.OptionalType:
+		return fmt.Sprintf("optional<%s>", TypeName(v.Type))
+	case *ast.ArrayType:
+		return fmt.Sprintf("array<%s>", TypeName(v.Type))
+	case *ast.MapType:
+		return fmt.Sprintf("map<%s, %s>", TypeName(v.Key), TypeName(v.Value))
+	case *ast.SimpleUserType:
+		return v.Name
+	case *ast.FullQualifiedType:
+		return v.FullName
+	default:
+		return "INVALID"
+	}
+}
+
+func (p *printer) writeStruct(s *ast.Struct) {
+	p.writeBefore(s)
+	writeComments(p.w, s.Comment)
+	writeAnnotations(p.w, s.Annotations)
+	if !p.open(s, "struct", s.Name, len(s.Fields)+len(s.Structs)+len(s.Enums)) {
+		p.writeAfterBody(s)
+		return
+	}
+
+	var items []item
+	nameWidth, typeWidth := 0, 0
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		items = append(items, item{pos: f.Position, field: f})
+		nameWidth = max(nameWidth, len(f.Name))
+		typeWidth = max(typeWidth, len(TypeName(f.Type)))
+	}
+	for i := range s.Structs {
+		items = append(items, item{pos: s.Structs[i].Position, st: &s.Structs[i]})
+	}
+	for i := range s.Enums {
+		items = append(items, item{pos: s.Enums[i].Position, enum: &s.Enums[i]})
+	}
+	sortItems(items)
+
+	// Fields are grouped together, while nested declarations are separated
+	// from anything else by a blank line.
+	for i, it := range items {
+		switch {
+		case i > 0 && (it.field == nil || items[i-1].field == nil):
+			p.w.Break()
+		case i > 0 && (len(p.comments(it.field).before) > 0 ||
+			separated(items[i-1].pos.Line, startLine(it.pos.Line, it.field.Comment, it.field.Annotations))):
+			p.w.Break()
+		}
+		switch {
+		case it.field != nil:
+			p.writeBefore(it.field)
+			writeComments(p.w, it.field.Comment)
+			writeAnnotations(p.w, it.field.Annotations)
+			p.line(it.field, "%-*s %-*s = %d;", nameWidth, it.field.Name, typeWidth, TypeName(it.field.Type), it.field.ID)
+			p.writeAfter(it.field)
+		case it.st != nil:
+			p.writeStruct(it.st)
+		case it.enum != nil:
+			p.writeEnum(it.enum)
+		}
+	}
+
+	p.close(s)
+}
+
+func (p *printer) writeEnum(e *ast.Enum) {
+	p.writeBefore(e)
+	writeComments(p.w, e.Comment)
+	writeAnnotations(p.w, e.Annotations)
+	if !p.open(e, "enum", e.Name, len(e.Members)) {
+		p.writeAfterBody(e)
+		return
+	}
+	nameWidth := 0
+	for _, m := range e.Members {
+		nameWidth = max(nameWidth, len(m.Name))
+	}
+	for i := range e.Members {
+		m := &e.Members[i]
+		if i > 0 && (len(p.comments(m).before) > 0 ||
+			separated(e.Members[i-1].Position.Line, startLine(m.Position.Line, m.Comment, m.Annotations))) {
+			p.w.Break()
+		}
+		p.writeBefore(m)
+		writeComments(p.w, m.Comment)
+		writeAnnotations(p.w, m.Annotations)
+		p.line(m, "%-*s = %d;", nameWidth, m.Name, m.Value)
+		p.writeAfter(m)
+	}
+	p.close(e)
+}
+
+func (p *printer) writeService(s *ast.Service) {
+	p.writeBefore(s)
+	writeComments(p.w, s.Comment)
+	writeAnnotations(p.w, s.Annotations)
+	if !p.open(s, "service", s.Name, len(s.Methods)) {
+		p.writeAfterBody(s)
+		return
+	}
+	for i, m := range s.Methods {
+		// Methods carrying comments or annotations are set apart from the
+		// previous one.
+		if i > 0 && (len(m.Comment) > 0 || len(m.Annotations) > 0 || len(p.comments(m).before) > 0 ||
+			separated(s.Methods[i-1].Position.Line, m.Position.Line)) {
+			p.w.Break()
+		}
+		p.writeBefore(m)
+		writeComments(p.w, m.Comment)
+		writeAnnotations(p.w, m.Annotations)
+		p.line(m, "%s;", MethodSignature(m))
+		p.writeAfter(m)
+	}
+	p.close(s)
+}
+
+// MethodSignature returns the IDL representation of a given method, without
+// its trailing semicolon.
+func MethodSignature(m *ast.ServiceMethod) string {
+	params := make([]string, len(m.Params))
+	for i, p := range m.Params {
+		var parts []string
+		if p.Stream {
+			parts = append(parts, "stream")
+		}
+		if p.Name != nil {
+			parts = append(parts, *p.Name)
+		}
+		parts = append(parts, TypeName(p.Type))
+		params[i] = strings.Join(parts, " ")
+	}
+	sig := fmt.Sprintf("%s(%s)", m.Name, strings.Join(params, ", "))
+
+	returns := make([]string, len(m.Returns))
+	for i, r := range m.Returns {
+		returns[i] = TypeName(r.Type)
+		if r.Stream {
+			returns[i] = "stream " + returns[i]
+		}
+	}
+	switch len(returns) {
+	case 0:
+		return sig
+	case 1:
+		return sig + " -> " + returns[0]
+	default:
+		return sig + " -> (" + strings.Join(returns, ", ") + ")"
+	}
+}
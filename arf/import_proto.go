@@ -0,0 +1,39 @@
+package arf
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/proto"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+)
+
+// ImportProto converts .proto files provided as arguments into arf IDL files.
+func ImportProto(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("at least one .proto file must be provided")
+	}
+
+	im := &proto.Importer{IncludePaths: c.StringSlice("proto-path")}
+	outputDir := c.String("output")
+	for _, path := range c.Args().Slice() {
+		target, data, err := im.Import(path)
+		if err != nil {
+			return err
+		}
+		if outputDir == "-" {
+			fmt.Print(string(data))
+			continue
+		}
+
+		target = filepath.Join(outputDir, target)
+		if err = os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			output.Errorf("Failed creating output directory `%s`: %s", filepath.Dir(target), err)
+		}
+		if err = os.WriteFile(target, data, os.ModePerm); err != nil {
+			output.Errorf("Failed writing output file `%s`: %s", target, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package arf
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/descriptor"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// writeDescriptor writes the descriptor of tree to the path provided through
+// --descriptor-out. The format is taken from --descriptor-format, or inferred
+// from the file extension when unset.
+func writeDescriptor(c *cli.Context, tree *ast.Tree, input string) error {
+	path := c.String("descriptor-out")
+	format := strings.ToLower(c.String("descriptor-format"))
+	if format == "" {
+		format = "binary"
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			format = "json"
+		}
+	}
+
+	root, err := filepath.Abs(filepath.Dir(input))
+	if err != nil {
+		return err
+	}
+	set := descriptor.Build(tree, root)
+
+	var data []byte
+	switch format {
+	case "json":
+		data, err = set.MarshalIndent()
+	case "binary":
+		data, err = set.MarshalBinary()
+	default:
+		return fmt.Errorf("unknown descriptor format `%s': must be either 'json' or 'binary'", format)
+	}
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadDescriptor reads a descriptor from path, or builds one when path points
+// to an IDL file.
+func loadDescriptor(path string) (*descriptor.Set, error) {
+	if filepath.Ext(path) == ".arf" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := parseTree(abs)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s: %w", path, err)
+		}
+		return descriptor.Build(tree, filepath.Dir(abs)), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set, err := descriptor.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return set, nil
+}
+
+// Inspect prints the contents of a descriptor written through
+// --descriptor-out, or of an IDL file, optionally filtered by a query. Any
+// error, such as a truncated descriptor, exits with status 1.
+func Inspect(c *cli.Context) error {
+	if err := inspect(c); err != nil {
+		return cli.Exit(err, 1)
+	}
+	return nil
+}
+
+func inspect(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("exactly one descriptor or idl file must be provided")
+	}
+	set, err := loadDescriptor(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	queries := 0
+	for _, f := range []string{"type", "uses", "streams", "methods"} {
+		if c.IsSet(f) {
+			queries++
+		}
+	}
+	if queries > 1 {
+		return fmt.Errorf("only one of --type, --uses, --streams, and --methods may be provided")
+	}
+
+	switch {
+	case c.IsSet("type"):
+		name, err := resolveName(set, c.String("type"), true)
+		if err != nil {
+			return err
+		}
+		return printDeclaration(set, name, c.Bool("json"))
+	case c.IsSet("uses"):
+		name, err := resolveName(set, c.String("uses"), false)
+		if err != nil {
+			return err
+		}
+		for _, u := range findUsages(set, name, false) {
+			fmt.Println(u)
+		}
+	case c.IsSet("streams"):
+		name, err := resolveName(set, c.String("streams"), false)
+		if err != nil {
+			return err
+		}
+		for _, u := range findUsages(set, name, true) {
+			fmt.Println(u)
+		}
+	case c.Bool("methods"):
+		for _, pkg := range set.Packages {
+			for _, svc := range pkg.Services {
+				for _, m := range svc.Methods {
+					fmt.Printf("%s.%s\n", svc.FullName, methodSignature(pkg, m))
+				}
+			}
+		}
+	case c.Bool("json"):
+		data, err := set.MarshalIndent()
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+	default:
+		w := &common.Writer{}
+		for i, pkg := range set.Packages {
+			if i > 0 {
+				w.Break()
+			}
+			printPackage(w, pkg)
+		}
+		fmt.Print(w.String())
+	}
+	return nil
+}
+
+// declarationNames returns the full names of all structs and enums in set,
+// along with services when withServices is set.
+func declarationNames(set *descriptor.Set, withServices bool) []string {
+	var names []string
+	set.WalkStructs(func(_ *descriptor.Package, s *descriptor.Struct) { names = append(names, s.FullName) })
+	set.WalkEnums(func(_ *descriptor.Package, e *descriptor.Enum) { names = append(names, e.FullName) })
+	if withServices {
+		for _, pkg := range set.Packages {
+			for _, s := range pkg.Services {
+				names = append(names, s.FullName)
+			}
+		}
+	}
+	return names
+}
+
+// resolveName finds the full name of a declaration given either its full
+// name, or a suffix of it such as Contact or Contact.Telephone.
+func resolveName(set *descriptor.Set, name string, withServices bool) (string, error) {
+	var candidates []string
+	for _, n := range declarationNames(set, withServices) {
+		if n == name {
+			return n, nil
+		}
+		if strings.HasSuffix(n, "."+name) {
+			candidates = append(candidates, n)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("no type named `%s' was found", name)
+	case 1:
+		return candidates[0], nil
+	}
+	slices.Sort(candidates)
+	return "", fmt.Errorf("`%s' is ambiguous, and may refer to any of: %s", name, strings.Join(candidates, ", "))
+}
+
+// findUsages lists fields and methods referencing the type named name. When
+// streamsOnly is set, only methods streaming it are listed.
+func findUsages(set *descriptor.Set, name string, streamsOnly bool) []string {
+	var usages []string
+	if !streamsOnly {
+		set.WalkStructs(func(_ *descriptor.Package, s *descriptor.Struct) {
+			for _, f := range s.Fields {
+				if f.Type.References(name) {
+					usages = append(usages, fmt.Sprintf("%s.%s (field %d, %s)", s.FullName, f.Name, f.ID, f.Type))
+				}
+			}
+		})
+	}
+	for _, pkg := range set.Packages {
+		for _, svc := range pkg.Services {
+			for _, m := range svc.Methods {
+				var where []string
+				for _, p := range m.Params {
+					if p.Type.References(name) && (p.Stream || !streamsOnly) {
+						if p.Stream {
+							where = append(where, "input stream")
+						} else {
+							where = append(where, "param "+p.Name)
+						}
+					}
+				}
+				for _, r := range m.Returns {
+					if r.Type.References(name) && (r.Stream || !streamsOnly) {
+						if r.Stream {
+							where = append(where, "output stream")
+						} else {
+							where = append(where, "return")
+						}
+					}
+				}
+				if len(where) > 0 {
+					usages = append(usages, fmt.Sprintf("%s.%s (%s)", svc.FullName, m.Name, strings.Join(where, ", ")))
+				}
+			}
+		}
+	}
+	return usages
+}
+
+// localName strips the package of pkg from user types declared in it.
+func localName(pkg *descriptor.Package, t *descriptor.Type) string {
+	return strings.ReplaceAll(t.String(), pkg.Name+".", "")
+}
+
+func methodSignature(pkg *descriptor.Package, m *descriptor.Method) string {
+	var params, returns []string
+	for _, p := range m.Params {
+		if p.Stream {
+			params = append(params, "stream "+localName(pkg, p.Type))
+		} else {
+			params = append(params, p.Name+" "+localName(pkg, p.Type))
+		}
+	}
+	for _, r := range m.Returns {
+		if r.Stream {
+			returns = append(returns, "stream "+localName(pkg, r.Type))
+		} else {
+			returns = append(returns, localName(pkg, r.Type))
+		}
+	}
+	sig := m.Name + "(" + strings.Join(params, ", ") + ")"
+	switch len(returns) {
+	case 0:
+	case 1:
+		sig += " -> " + returns[0]
+	default:
+		sig += " -> (" + strings.Join(returns, ", ") + ")"
+	}
+	return sig
+}
+
+func printAnnotations(w *common.Writer, annotations []*descriptor.Annotation) {
+	for _, a := range annotations {
+		if len(a.Arguments) == 0 {
+			w.Writelnf("@%s", a.Name)
+			continue
+		}
+		w.Writelnf("@%s(%q)", a.Name, strings.Join(a.Arguments, ", "))
+	}
+}
+
+func printStruct(w *common.Writer, pkg *descriptor.Package, s *descriptor.Struct) {
+	printAnnotations(w, s.Annotations)
+	w.Writelnf("struct %s", strings.TrimPrefix(s.FullName, pkg.Name+"."))
+	w.IncreaseIndent()
+	for _, f := range s.Fields {
+		printAnnotations(w, f.Annotations)
+		w.Writelnf("%d: %s %s", f.ID, f.Name, localName(pkg, f.Type))
+	}
+	w.DecreaseIndent()
+	for _, n := range s.Structs {
+		printStruct(w, pkg, n)
+	}
+	for _, e := range s.Enums {
+		printEnum(w, pkg, e)
+	}
+}
+
+func printEnum(w *common.Writer, pkg *descriptor.Package, e *descriptor.Enum) {
+	printAnnotations(w, e.Annotations)
+	w.Writelnf("enum %s", strings.TrimPrefix(e.FullName, pkg.Name+"."))
+	w.IncreaseIndent()
+	for _, m := range e.Members {
+		printAnnotations(w, m.Annotations)
+		w.Writelnf("%s = %d", m.Name, m.Value)
+	}
+	w.DecreaseIndent()
+}
+
+func printService(w *common.Writer, pkg *descriptor.Package, s *descriptor.Service) {
+	printAnnotations(w, s.Annotations)
+	w.Writelnf("service %s", s.Name)
+	w.IncreaseIndent()
+	for _, m := range s.Methods {
+		printAnnotations(w, m.Annotations)
+		w.Writelnf("%s", methodSignature(pkg, m))
+	}
+	w.DecreaseIndent()
+}
+
+func printPackage(w *common.Writer, pkg *descriptor.Package) {
+	w.Writelnf("package %s (%s)", pkg.Name, strings.Join(pkg.Files, ", "))
+	w.IncreaseIndent()
+	for _, s := range pkg.Structs {
+		printStruct(w, pkg, s)
+	}
+	for _, e := range pkg.Enums {
+		printEnum(w, pkg, e)
+	}
+	for _, s := range pkg.Services {
+		printService(w, pkg, s)
+	}
+	w.DecreaseIndent()
+}
+
+// printDeclaration prints the struct, enum or service named name, either in
+// the same layout used when printing whole descriptors, or as JSON.
+func printDeclaration(set *descriptor.Set, name string, asJSON bool) error {
+	var decl any
+	w := &common.Writer{}
+	set.WalkStructs(func(pkg *descriptor.Package, s *descriptor.Struct) {
+		if s.FullName == name {
+			decl = s
+			printStruct(w, pkg, s)
+		}
+	})
+	set.WalkEnums(func(pkg *descriptor.Package, e *descriptor.Enum) {
+		if e.FullName == name {
+			decl = e
+			printEnum(w, pkg, e)
+		}
+	})
+	for _, pkg := range set.Packages {
+		for _, s := range pkg.Services {
+			if s.FullName == name {
+				decl = s
+				printService(w, pkg, s)
+			}
+		}
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(decl, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Print(w.String())
+	return nil
+}
@@ -0,0 +1,108 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.MultiFileGenerator {
+	return &Generator{
+		t: tree,
+	}
+}
+
+type Generator struct {
+	t *ast.PackageTree
+
+	output string
+	files  []common.File
+}
+
+// SchemaID returns the $id of the schema generated for a given struct or
+// enum. IDs are relative to the output directory, and each schema is written
+// to the path matching its ID.
+func SchemaID(obj ast.Object) string {
+	var name string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name = v.Name
+	case *ast.Enum:
+		name = v.Name
+	default:
+		return ""
+	}
+	// Paths are taken from the declaring file, as parents of nested objects
+	// may not be complete.
+	names := common.ObjectPath(obj)
+	if names == nil {
+		names = []string{name}
+	}
+	for i, v := range names {
+		names[i] = strcase.ToSnake(v)
+	}
+	return obj.Pos().File.Package.Value + "/" + strings.Join(names, "/") + ".schema.json"
+}
+
+func (g *Generator) GenFiles(ctx *cli.Context) []common.File {
+	g.output = ctx.String("output")
+
+	for i := range g.t.Enums {
+		g.makeEnum(&g.t.Enums[i])
+	}
+
+	for i := range g.t.Structures {
+		g.makeStruct(&g.t.Structures[i])
+	}
+
+	return g.files
+}
+
+// refFrom returns a resolver producing references relative to the schema
+// identified by id.
+func refFrom(id string) RefResolver {
+	return func(obj ast.Object) string {
+		rel, err := filepath.Rel(path.Dir(id), SchemaID(obj))
+		if err != nil {
+			return SchemaID(obj)
+		}
+		return filepath.ToSlash(rel)
+	}
+}
+
+func (g *Generator) addFile(id string, s *Schema) {
+	s.Schema = Draft
+	s.ID = id
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		output.Errorf("Failed encoding schema %s: %s", id, err)
+	}
+	g.files = append(g.files, common.File{
+		Data:       append(data, '\n'),
+		TargetDir:  filepath.Join(g.output, filepath.FromSlash(path.Dir(id))),
+		TargetFile: path.Base(id),
+	})
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	g.addFile(SchemaID(e), EnumSchema(e))
+}
+
+func (g *Generator) makeStruct(s *ast.Struct) {
+	for i := range s.Structs {
+		g.makeStruct(&s.Structs[i])
+	}
+
+	for i := range s.Enums {
+		g.makeEnum(&s.Enums[i])
+	}
+
+	id := SchemaID(s)
+	g.addFile(id, StructSchema(s, refFrom(id)))
+}
@@ -0,0 +1,190 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/idl/ast"
+	"strings"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema represents the subset of JSON Schema used to describe arf types.
+// Fields are declared in the order they are expected to be emitted.
+type Schema struct {
+	Schema               string      `json:"$schema,omitempty"`
+	ID                   string      `json:"$id,omitempty"`
+	Ref                  string      `json:"$ref,omitempty"`
+	Title                string      `json:"title,omitempty"`
+	Description          string      `json:"description,omitempty"`
+	Deprecated           bool        `json:"deprecated,omitempty"`
+	Type                 string      `json:"type,omitempty"`
+	Format               string      `json:"format,omitempty"`
+	ContentEncoding      string      `json:"contentEncoding,omitempty"`
+	Pattern              string      `json:"pattern,omitempty"`
+	Minimum              json.Number `json:"minimum,omitempty"`
+	Maximum              json.Number `json:"maximum,omitempty"`
+	Enum                 []any       `json:"enum,omitempty"`
+	PrefixItems          []*Schema   `json:"prefixItems,omitempty"`
+	Items                *Schema     `json:"items,omitempty"`
+	MinItems             *int        `json:"minItems,omitempty"`
+	MaxItems             *int        `json:"maxItems,omitempty"`
+	Properties           Properties  `json:"properties,omitempty"`
+	PropertyNames        *Schema     `json:"propertyNames,omitempty"`
+	AdditionalProperties any         `json:"additionalProperties,omitempty"`
+	Required             []string    `json:"required,omitempty"`
+}
+
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties retains the declaration order of properties when marshalled.
+type Properties []Property
+
+func (p Properties) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(prop.Name)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := json.Marshal(prop.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(schema)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// RefResolver returns the value of $ref used to reference the schema of a
+// given struct or enum.
+type RefResolver func(obj ast.Object) string
+
+var integerBounds = map[string][2]string{
+	"int8":   {"-128", "127"},
+	"int16":  {"-32768", "32767"},
+	"int32":  {"-2147483648", "2147483647"},
+	"int64":  {"-9223372036854775808", "9223372036854775807"},
+	"uint8":  {"0", "255"},
+	"uint16": {"0", "65535"},
+	"uint32": {"0", "4294967295"},
+	"uint64": {"0", "18446744073709551615"},
+}
+
+// TypeSchema returns the schema describing values of a given type.
+func TypeSchema(t ast.Type, ref RefResolver) *Schema {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			return &Schema{Type: "string"}
+		case "bool":
+			return &Schema{Type: "boolean"}
+		case "float32", "float64":
+			return &Schema{Type: "number"}
+		case "bytes":
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		case "timestamp":
+			return &Schema{Type: "string", Format: "date-time"}
+		default:
+			bounds := integerBounds[v.Name]
+			return &Schema{Type: "integer", Minimum: json.Number(bounds[0]), Maximum: json.Number(bounds[1])}
+		}
+	case *ast.OptionalType:
+		return TypeSchema(v.Type, ref)
+	case *ast.ArrayType:
+		return &Schema{Type: "array", Items: TypeSchema(v.Type, ref)}
+	case *ast.MapType:
+		// Object keys are always strings, so non-string keys are constrained
+		// to their textual representation.
+		s := &Schema{Type: "object", AdditionalProperties: TypeSchema(v.Value, ref)}
+		switch key := v.Key.(type) {
+		case *ast.PrimitiveType:
+			switch {
+			case strings.HasPrefix(key.Name, "int"):
+				s.PropertyNames = &Schema{Pattern: "^-?[0-9]+$"}
+			case strings.HasPrefix(key.Name, "uint"):
+				s.PropertyNames = &Schema{Pattern: "^[0-9]+$"}
+			}
+		case *ast.SimpleUserType, *ast.FullQualifiedType:
+			s.PropertyNames = TypeSchema(key, ref)
+		}
+		return s
+	case *ast.SimpleUserType:
+		return &Schema{Ref: ref(v.ResolvedType)}
+	case *ast.FullQualifiedType:
+		return &Schema{Ref: ref(v.ResolvedType)}
+	default:
+		return &Schema{}
+	}
+}
+
+func describe(s *Schema, comment []string, set ast.AnnotationSet) {
+	lines := make([]string, 0, len(comment))
+	for _, l := range comment {
+		lines = append(lines, strings.TrimPrefix(l, " "))
+	}
+	if ann := set.ByName("deprecated"); ann != nil {
+		s.Deprecated = true
+		if len(ann.Arguments) > 0 {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, fmt.Sprintf("Deprecated: %s", ann.Arguments[0]))
+		}
+	}
+	s.Description = strings.Join(lines, "\n")
+}
+
+// StructSchema returns the schema describing a given struct. Optional fields
+// are not listed as required.
+func StructSchema(st *ast.Struct, ref RefResolver) *Schema {
+	s := &Schema{
+		Title:                common.StructName(st),
+		Type:                 "object",
+		Properties:           Properties{},
+		AdditionalProperties: false,
+	}
+	describe(s, st.Comment, st.Annotations)
+	for _, f := range st.Fields {
+		prop := TypeSchema(f.Type, ref)
+		describe(prop, f.Comment, f.Annotations)
+		s.Properties = append(s.Properties, Property{Name: f.Name, Schema: prop})
+		if _, ok := f.Type.(*ast.OptionalType); !ok {
+			s.Required = append(s.Required, f.Name)
+		}
+	}
+	return s
+}
+
+// EnumSchema returns the schema describing a given enum. Members may be
+// represented either by their names or by their values.
+func EnumSchema(e *ast.Enum) *Schema {
+	s := &Schema{
+		Title: common.EnumName(e),
+	}
+	describe(s, e.Comment, e.Annotations)
+	var values []any
+	seen := map[int]bool{}
+	for _, m := range e.Members {
+		s.Enum = append(s.Enum, m.Name)
+		if !seen[m.Value] {
+			seen[m.Value] = true
+			values = append(values, m.Value)
+		}
+	}
+	s.Enum = append(s.Enum, values...)
+	return s
+}
@@ -0,0 +1,107 @@
+package arf
+
+import (
+	"errors"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/lint"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultLintConfig is the configuration file used by Lint when none is
+// provided through --config.
+const defaultLintConfig = "arflint.json"
+
+// Lint checks IDL files provided as arguments against the configured rule
+// set. Directories are walked for .arf files.
+func Lint(c *cli.Context) error {
+	if c.Bool("list-rules") {
+		for _, r := range lint.Rules {
+			fmt.Printf("%-18s %-8s %s\n", r.Name, r.Default, r.Description)
+		}
+		return nil
+	}
+	if c.NArg() == 0 {
+		return cli.Exit("at least one file or directory must be provided", 1)
+	}
+
+	// Configuration and parse errors fail the run, the same as error-severity
+	// findings, so they are never mistaken for a clean run.
+	config := lint.DefaultConfig()
+	configPath := c.String("config")
+	if configPath == "" {
+		if _, err := os.Stat(defaultLintConfig); err == nil {
+			configPath = defaultLintConfig
+		}
+	}
+	if configPath != "" {
+		var err error
+		if config, err = lint.LoadConfig(configPath); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+	for _, r := range c.StringSlice("rule") {
+		name, sev, ok := strings.Cut(r, "=")
+		if !ok {
+			return cli.Exit(fmt.Sprintf("invalid rule `%s': must be in the format name=severity", r), 1)
+		}
+		if err := config.Set(name, sev); err != nil {
+			return cli.Exit(err, 1)
+		}
+	}
+
+	paths, err := expandPaths(c.Args().Slice())
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+	files, trees, err := parseFiles(paths)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	errored := false
+	for _, d := range lint.Lint(files, trees, config) {
+		fmt.Println(d)
+		if d.Severity == lint.Error {
+			errored = true
+		}
+	}
+	if errored {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// parseFiles parses each of paths, returning their files along with the
+// trees containing them.
+func parseFiles(paths []string) ([]*ast.File, []*ast.Tree, error) {
+	var files []*ast.File
+	var trees []*ast.Tree
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		tree, err := parseTree(abs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error parsing %s: %w", path, err)
+		}
+		trees = append(trees, tree)
+		found := false
+		for _, pkg := range tree.Packages {
+			for _, f := range pkg.Files {
+				if f.Path == abs {
+					files = append(files, f)
+					found = true
+				}
+			}
+		}
+		if !found {
+			return nil, nil, errors.New(path + ": file not found in parsed tree")
+		}
+	}
+	return files, trees, nil
+}
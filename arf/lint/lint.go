@@ -0,0 +1,227 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/idl/ast"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Severity indicates how a rule violation is reported.
+type Severity int
+
+const (
+	Off Severity = iota
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return "off"
+	}
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(s) {
+	case "off":
+		return Off, nil
+	case "warning", "warn":
+		return Warning, nil
+	case "error":
+		return Error, nil
+	}
+	return Off, fmt.Errorf("invalid severity `%s': must be one of 'off', 'warning', or 'error'", s)
+}
+
+// Diagnostic represents a single rule violation.
+type Diagnostic struct {
+	Rule     string
+	Severity Severity
+	Position ast.Position
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", d.Position.Filename, d.Position.Line, d.Position.Column,
+		d.Severity, d.Message, d.Rule)
+}
+
+// Config maps rule names to the severity they are reported with. Rules absent
+// from the configuration use their default severity.
+type Config map[string]Severity
+
+// DefaultConfig returns a configuration containing the default severity of
+// every rule.
+func DefaultConfig() Config {
+	c := Config{}
+	for _, r := range Rules {
+		c[r.Name] = r.Default
+	}
+	return c
+}
+
+// LoadConfig reads a JSON configuration file in the following format, and
+// applies it over the default configuration:
+//
+//	{"rules": {"unused-types": "off", "method-comments": "error"}}
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Rules map[string]string `json:"rules"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c := DefaultConfig()
+	for name, sev := range raw.Rules {
+		if err = c.Set(name, sev); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// Set changes the severity of a given rule.
+func (c Config) Set(rule, severity string) error {
+	if _, ok := c[rule]; !ok {
+		return fmt.Errorf("unknown rule `%s'", rule)
+	}
+	sev, err := ParseSeverity(severity)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", rule, err)
+	}
+	c[rule] = sev
+	return nil
+}
+
+type linter struct {
+	config      Config
+	diagnostics []Diagnostic
+	// used holds keys of all structs and enums referenced by any type.
+	used map[string]bool
+}
+
+func (l *linter) report(rule string, pos ast.Position, format string, args ...any) {
+	sev := l.config[rule]
+	if sev == Off {
+		return
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Rule:     rule,
+		Severity: sev,
+		Position: pos,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// objectKey returns a key identifying a struct or enum across packages.
+func objectKey(obj ast.Object) string {
+	path := common.ObjectPath(obj)
+	if path == nil {
+		return ""
+	}
+	return obj.Pos().File.Package.Value + "." + strings.Join(path, ".")
+}
+
+// Lint checks files against rules enabled in config. All files of trees are
+// taken into account when looking for unused types, but only files are
+// reported on.
+func Lint(files []*ast.File, trees []*ast.Tree, config Config) []Diagnostic {
+	l := &linter{config: config, used: map[string]bool{}}
+	for _, t := range trees {
+		for _, pkg := range t.Packages {
+			for _, f := range pkg.Files {
+				l.collectUsages(f)
+			}
+		}
+	}
+
+	for _, f := range files {
+		for _, r := range Rules {
+			if config[r.Name] != Off {
+				r.check(l, f)
+			}
+		}
+	}
+
+	slices.SortStableFunc(l.diagnostics, func(a, b Diagnostic) int {
+		if v := strings.Compare(a.Position.Filename, b.Position.Filename); v != 0 {
+			return v
+		}
+		if a.Position.Line != b.Position.Line {
+			return a.Position.Line - b.Position.Line
+		}
+		return a.Position.Column - b.Position.Column
+	})
+	return l.diagnostics
+}
+
+func (l *linter) collectUsages(f *ast.File) {
+	var markType func(t ast.Type)
+	markType = func(t ast.Type) {
+		switch v := t.(type) {
+		case *ast.OptionalType:
+			markType(v.Type)
+		case *ast.ArrayType:
+			markType(v.Type)
+		case *ast.MapType:
+			markType(v.Key)
+			markType(v.Value)
+		case *ast.SimpleUserType:
+			if v.ResolvedType != nil {
+				l.used[objectKey(v.ResolvedType)] = true
+			}
+		case *ast.FullQualifiedType:
+			if v.ResolvedType != nil {
+				l.used[objectKey(v.ResolvedType)] = true
+			}
+		}
+	}
+	walkStructs(f.Structs, func(s *ast.Struct) {
+		for _, field := range s.Fields {
+			markType(field.Type)
+		}
+	})
+	for _, s := range f.Services {
+		for _, m := range s.Methods {
+			for _, p := range m.Params {
+				markType(p.Type)
+			}
+			for _, r := range m.Returns {
+				markType(r.Type)
+			}
+		}
+	}
+}
+
+// walkStructs calls fn for each struct in structs, including nested ones.
+func walkStructs(structs []ast.Struct, fn func(s *ast.Struct)) {
+	for i := range structs {
+		fn(&structs[i])
+		walkStructs(structs[i].Structs, fn)
+	}
+}
+
+// walkEnums calls fn for each enum declared in f, including nested ones.
+func walkEnums(f *ast.File, fn func(e *ast.Enum)) {
+	for i := range f.Enums {
+		fn(&f.Enums[i])
+	}
+	walkStructs(f.Structs, func(s *ast.Struct) {
+		for i := range s.Enums {
+			fn(&s.Enums[i])
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package lint
+
+import (
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+	"slices"
+)
+
+// Rule represents a check performed over IDL files.
+type Rule struct {
+	Name        string
+	Description string
+	Default     Severity
+	check       func(l *linter, f *ast.File)
+}
+
+// Rules lists all available rules.
+var Rules = []Rule{
+	{
+		Name:        "type-names",
+		Description: "Structs, enums and services must be named in CamelCase, as expected by generators",
+		Default:     Error,
+		check:       checkTypeNames,
+	},
+	{
+		Name:        "field-names",
+		Description: "Struct fields must be named in snake_case",
+		Default:     Error,
+		check:       checkFieldNames,
+	},
+	{
+		Name:        "enum-member-names",
+		Description: "Enum members must be named in SCREAMING_SNAKE_CASE",
+		Default:     Error,
+		check:       checkEnumMemberNames,
+	},
+	{
+		Name:        "method-names",
+		Description: "Service methods and their parameters must be named in snake_case",
+		Default:     Warning,
+		check:       checkMethodNames,
+	},
+	{
+		Name:        "service-comments",
+		Description: "Services must be documented by a comment",
+		Default:     Warning,
+		check:       checkServiceComments,
+	},
+	{
+		Name:        "method-comments",
+		Description: "Service methods must be documented by a comment",
+		Default:     Warning,
+		check:       checkMethodComments,
+	},
+	{
+		Name:        "field-id-order",
+		Description: "Field IDs must increase in declaration order",
+		Default:     Error,
+		check:       checkFieldIDOrder,
+	},
+	{
+		Name:        "field-id-gaps",
+		Description: "Field IDs must be sequential, starting from zero",
+		Default:     Warning,
+		check:       checkFieldIDGaps,
+	},
+	{
+		Name:        "unused-types",
+		Description: "Structs and enums must be referenced by a service or another type",
+		Default:     Warning,
+		check:       checkUnusedTypes,
+	},
+	{
+		Name:        "empty-services",
+		Description: "Services must declare at least one method",
+		Default:     Error,
+		check:       checkEmptyServices,
+	},
+	{
+		Name:        "deprecated-reason",
+		Description: "@deprecated annotations must indicate a reason or replacement",
+		Default:     Warning,
+		check:       checkDeprecatedReason,
+	},
+}
+
+func isCamel(name string) bool {
+	return strcase.ToCamel(strcase.ToSnake(name)) == name
+}
+
+func checkTypeNames(l *linter, f *ast.File) {
+	walkStructs(f.Structs, func(s *ast.Struct) {
+		if !isCamel(s.Name) {
+			l.report("type-names", s.Position, "struct %s should be named %s", s.Name, strcase.ToCamel(strcase.ToSnake(s.Name)))
+		}
+	})
+	walkEnums(f, func(e *ast.Enum) {
+		if !isCamel(e.Name) {
+			l.report("type-names", e.Position, "enum %s should be named %s", e.Name, strcase.ToCamel(strcase.ToSnake(e.Name)))
+		}
+	})
+	for _, s := range f.Services {
+		if !isCamel(s.Name) {
+			l.report("type-names", s.Position, "service %s should be named %s", s.Name, strcase.ToCamel(strcase.ToSnake(s.Name)))
+		}
+	}
+}
+
+func checkFieldNames(l *linter, f *ast.File) {
+	walkStructs(f.Structs, func(s *ast.Struct) {
+		for _, field := range s.Fields {
+			if strcase.ToSnake(field.Name) != field.Name {
+				l.report("field-names", field.Position, "field %s of %s should be named %s", field.Name, s.Name, strcase.ToSnake(field.Name))
+			}
+		}
+	})
+}
+
+func checkEnumMemberNames(l *linter, f *ast.File) {
+	walkEnums(f, func(e *ast.Enum) {
+		for _, m := range e.Members {
+			if strcase.ToScreamingSnake(m.Name) != m.Name {
+				l.report("enum-member-names", m.Position, "member %s of %s should be named %s", m.Name, e.Name, strcase.ToScreamingSnake(m.Name))
+			}
+		}
+	})
+}
+
+func checkMethodNames(l *linter, f *ast.File) {
+	for _, s := range f.Services {
+		for _, m := range s.Methods {
+			if strcase.ToSnake(m.Name) != m.Name {
+				l.report("method-names", m.Position, "method %s of %s should be named %s", m.Name, s.Name, strcase.ToSnake(m.Name))
+			}
+			for _, p := range m.Params {
+				if p.Name != nil && strcase.ToSnake(*p.Name) != *p.Name {
+					l.report("method-names", p.Position, "parameter %s of %s.%s should be named %s", *p.Name, s.Name, m.Name, strcase.ToSnake(*p.Name))
+				}
+			}
+		}
+	}
+}
+
+func checkServiceComments(l *linter, f *ast.File) {
+	for _, s := range f.Services {
+		if len(s.Comment) == 0 {
+			l.report("service-comments", s.Position, "service %s is not documented", s.Name)
+		}
+	}
+}
+
+func checkMethodComments(l *linter, f *ast.File) {
+	for _, s := range f.Services {
+		for _, m := range s.Methods {
+			if len(m.Comment) == 0 {
+				l.report("method-comments", m.Position, "method %s of %s is not documented", m.Name, s.Name)
+			}
+		}
+	}
+}
+
+func checkFieldIDOrder(l *linter, f *ast.File) {
+	walkStructs(f.Structs, func(s *ast.Struct) {
+		for i := 1; i < len(s.Fields); i++ {
+			prev, cur := s.Fields[i-1], s.Fields[i]
+			if cur.ID <= prev.ID {
+				l.report("field-id-order", cur.Position, "field %s of %s has ID %d, which does not follow %d of %s",
+					cur.Name, s.Name, cur.ID, prev.ID, prev.Name)
+			}
+		}
+	})
+}
+
+func checkFieldIDGaps(l *linter, f *ast.File) {
+	walkStructs(f.Structs, func(s *ast.Struct) {
+		ids := make([]int, len(s.Fields))
+		for i, field := range s.Fields {
+			ids[i] = field.ID
+		}
+		slices.Sort(ids)
+		ids = slices.Compact(ids)
+		for i, id := range ids {
+			if id != i {
+				l.report("field-id-gaps", s.Position, "field IDs of %s skip from %d to %d", s.Name, i-1, id)
+				return
+			}
+		}
+	})
+}
+
+func checkUnusedTypes(l *linter, f *ast.File) {
+	walkStructs(f.Structs, func(s *ast.Struct) {
+		if !l.used[objectKey(s)] {
+			l.report("unused-types", s.Position, "struct %s is not used by any service or type", s.Name)
+		}
+	})
+	walkEnums(f, func(e *ast.Enum) {
+		if !l.used[objectKey(e)] {
+			l.report("unused-types", e.Position, "enum %s is not used by any service or type", e.Name)
+		}
+	})
+}
+
+func checkEmptyServices(l *linter, f *ast.File) {
+	for _, s := range f.Services {
+		if len(s.Methods) == 0 {
+			l.report("empty-services", s.Position, "service %s declares no methods", s.Name)
+		}
+	}
+}
+
+func checkDeprecatedReason(l *linter, f *ast.File) {
+	check := func(set ast.AnnotationSet, what string) {
+		if a := set.ByName("deprecated"); a != nil && len(a.Arguments) == 0 {
+			l.report("deprecated-reason", a.Position, "@deprecated on %s does not indicate a reason", what)
+		}
+	}
+	walkStructs(f.Structs, func(s *ast.Struct) {
+		check(s.Annotations, "struct "+s.Name)
+		for _, field := range s.Fields {
+			check(field.Annotations, "field "+s.Name+"."+field.Name)
+		}
+	})
+	walkEnums(f, func(e *ast.Enum) {
+		check(e.Annotations, "enum "+e.Name)
+		for _, m := range e.Members {
+			check(m.Annotations, "member "+e.Name+"."+m.Name)
+		}
+	})
+	for _, s := range f.Services {
+		check(s.Annotations, "service "+s.Name)
+		for _, m := range s.Methods {
+			check(m.Annotations, "method "+s.Name+"."+m.Name)
+		}
+	}
+}
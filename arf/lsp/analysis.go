@@ -0,0 +1,430 @@
+package lsp
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl"
+	"github.com/arf-rpc/idl/ast"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// document represents a file opened by the client.
+type document struct {
+	uri   string
+	path  string
+	text  string
+	lines []string
+
+	// tree and file hold the result of the last successful parse, which is
+	// kept while the document contains errors. parsedPath is the path file
+	// was parsed from, and must be mapped to path.
+	tree       *ast.Tree
+	file       *ast.File
+	parsedPath string
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func (d *document) setText(text string) {
+	d.text = text
+	d.lines = strings.Split(text, "\n")
+}
+
+var errorPosition = regexp.MustCompile(`line (\d+), column (\d+)`)
+
+// importPath matches import statements placed at the beginning of a line,
+// capturing the text preceding their path, and the path itself, quoted by
+// either double or single quotes.
+var importPath = regexp.MustCompile(`(?m)^(\s*import\s+)(?:"((?:\\.|[^"\\\n])*)"|'((?:\\.|[^'\\\n])*)')`)
+
+// absoluteImports rewrites relative imports in text to absolute paths,
+// resolved against dir. Only columns within import statements change, so
+// lines of the rewritten text match those of text. The returned map holds
+// the original value of each rewritten import, keyed by its absolute path.
+func absoluteImports(text, dir string) (string, map[string]string) {
+	original := map[string]string{}
+	text = importPath.ReplaceAllStringFunc(text, func(stmt string) string {
+		m := importPath.FindStringSubmatch(stmt)
+		path := strings.ReplaceAll(m[2], `\"`, `"`)
+		if strings.HasSuffix(stmt, "'") {
+			path = strings.ReplaceAll(m[3], `\'`, "'")
+		}
+		if filepath.IsAbs(path) {
+			return stmt
+		}
+		abs := filepath.Join(dir, path)
+		original[abs] = path
+		return m[1] + `"` + strings.ReplaceAll(abs, `"`, `\"`) + `"`
+	})
+	return text, original
+}
+
+// source holds the result of parsing a document through parseSource.
+type source struct {
+	tree *ast.Tree
+	file *ast.File
+
+	// parsedPath is the temporary path the document was parsed from, which
+	// no longer exists, and must be mapped to the path of the document.
+	parsedPath string
+
+	// imports maps rewritten imports back to their original value.
+	imports map[string]string
+}
+
+// parseSource parses text as if it were located at path. Since the parser
+// only reads from disk, text is written to a file named after path within a
+// temporary directory, which is removed once parsing finishes. Relative
+// imports are rewritten so they still resolve against the directory of path.
+func parseSource(path, text string) (src source, errs []string) {
+	dir, err := os.MkdirTemp("", "arfc-lsp-")
+	if err != nil {
+		return src, []string{fmt.Sprintf("cannot create temporary directory to parse %s: %s", path, err)}
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	src.parsedPath = filepath.Join(dir, filepath.Base(path))
+	text, src.imports = absoluteImports(text, filepath.Dir(path))
+	if err = os.WriteFile(src.parsedPath, []byte(text), 0600); err != nil {
+		return src, []string{fmt.Sprintf("cannot write temporary file to parse %s: %s", path, err)}
+	}
+
+	tree, err := idl.ParseFile(src.parsedPath, func(err error) {
+		errs = append(errs, err.Error())
+	})
+	// Parse errors are reported both through the callback and the returned
+	// error, while validation errors are only returned.
+	if len(errs) == 0 && err != nil {
+		errs = strings.Split(err.Error(), "\n")
+	}
+	if len(errs) > 0 {
+		return src, errs
+	}
+	src.tree = tree
+	for _, pkg := range tree.Packages {
+		for _, f := range pkg.Files {
+			if f.Path == src.parsedPath {
+				src.file = f
+			}
+		}
+	}
+	return src, nil
+}
+
+// analyze parses the document, updating its tree, and returns diagnostics
+// for errors found.
+func (d *document) analyze() []diagnostic {
+	src, errs := parseSource(d.path, d.text)
+	parsedPath := src.parsedPath
+	if src.tree != nil {
+		d.tree, d.file, d.parsedPath = src.tree, src.file, parsedPath
+	}
+
+	diagnostics := []diagnostic{}
+	for _, msg := range errs {
+		if msg == "" {
+			continue
+		}
+		line := 0
+		// Errors found in imported files are reported on the first line.
+		if m := errorPosition.FindStringSubmatch(msg); m != nil && (parsedPath == "" || strings.Contains(msg, parsedPath) || !strings.Contains(msg, ".arf")) {
+			line, _ = strconv.Atoi(m[1])
+			line--
+		}
+		if parsedPath != "" {
+			msg = strings.ReplaceAll(msg, parsedPath, d.path)
+		}
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    d.lineRange(line),
+			Severity: severityError,
+			Source:   "arfc",
+			Message:  msg,
+		})
+	}
+	return diagnostics
+}
+
+func lineRange(lines []string, line int) textRange {
+	if line < 0 || line >= len(lines) {
+		return textRange{Start: position{Line: max(line, 0)}, End: position{Line: max(line, 0)}}
+	}
+	return textRange{
+		Start: position{Line: line},
+		End:   position{Line: line, Character: utf16Len(lines[line])},
+	}
+}
+
+func (d *document) lineRange(line int) textRange {
+	return lineRange(d.lines, line)
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// byteOffset converts a UTF-16 based character offset into a byte offset
+// within line.
+func byteOffset(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
+
+func isWordChar(b byte) bool {
+	return b == '_' || b == '.' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// wordAt returns the identifier (possibly dotted) at a given position,
+// along with its range.
+func (d *document) wordAt(pos position) (string, textRange) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return "", textRange{}
+	}
+	line := d.lines[pos.Line]
+	offset := byteOffset(line, pos.Character)
+	start, end := offset, offset
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isWordChar(line[end]) {
+		end++
+	}
+	word := strings.Trim(line[start:end], ".")
+	if word == "" {
+		return "", textRange{}
+	}
+	start += strings.Index(line[start:end], word)
+	end = start + len(word)
+	return word, textRange{
+		Start: position{Line: pos.Line, Character: utf16Len(line[:start])},
+		End:   position{Line: pos.Line, Character: utf16Len(line[:end])},
+	}
+}
+
+// typeRef represents a reference to a user type within a document.
+type typeRef struct {
+	line int
+	name string
+	obj  ast.Object
+}
+
+func collectRefs(t ast.Type, refs *[]typeRef) {
+	switch v := t.(type) {
+	case *ast.OptionalType:
+		collectRefs(v.Type, refs)
+	case *ast.ArrayType:
+		collectRefs(v.Type, refs)
+	case *ast.MapType:
+		collectRefs(v.Key, refs)
+		collectRefs(v.Value, refs)
+	case *ast.SimpleUserType:
+		*refs = append(*refs, typeRef{line: v.Position.Line, name: v.Name, obj: v.ResolvedType})
+	case *ast.FullQualifiedType:
+		*refs = append(*refs, typeRef{line: v.Position.Line, name: v.FullName, obj: v.ResolvedType})
+	}
+}
+
+func walkStructs(structs []ast.Struct, fn func(s *ast.Struct)) {
+	for i := range structs {
+		fn(&structs[i])
+		walkStructs(structs[i].Structs, fn)
+	}
+}
+
+func (d *document) refs() []typeRef {
+	var refs []typeRef
+	if d.file == nil {
+		return nil
+	}
+	walkStructs(d.file.Structs, func(s *ast.Struct) {
+		for _, f := range s.Fields {
+			collectRefs(f.Type, &refs)
+		}
+	})
+	for _, s := range d.file.Services {
+		for _, m := range s.Methods {
+			for _, p := range m.Params {
+				collectRefs(p.Type, &refs)
+			}
+			for _, r := range m.Returns {
+				collectRefs(r.Type, &refs)
+			}
+		}
+	}
+	return refs
+}
+
+// objectAt returns the struct, enum or service referenced or declared at a
+// given position.
+func (d *document) objectAt(pos position) (ast.Object, textRange) {
+	word, wordRange := d.wordAt(pos)
+	if word == "" || d.file == nil {
+		return nil, textRange{}
+	}
+	for _, r := range d.refs() {
+		if r.line == pos.Line+1 && r.name == word && r.obj != nil {
+			return r.obj, wordRange
+		}
+	}
+
+	var found ast.Object
+	walkStructs(d.file.Structs, func(s *ast.Struct) {
+		if s.Position.Line == pos.Line+1 && s.Name == word {
+			found = s
+		}
+		for i := range s.Enums {
+			if s.Enums[i].Position.Line == pos.Line+1 && s.Enums[i].Name == word {
+				found = &s.Enums[i]
+			}
+		}
+	})
+	for i := range d.file.Enums {
+		if d.file.Enums[i].Position.Line == pos.Line+1 && d.file.Enums[i].Name == word {
+			found = &d.file.Enums[i]
+		}
+	}
+	for i := range d.file.Services {
+		if d.file.Services[i].Position.Line == pos.Line+1 && d.file.Services[i].Name == word {
+			found = &d.file.Services[i]
+		}
+	}
+	if found == nil {
+		return nil, textRange{}
+	}
+	return found, wordRange
+}
+
+func objectName(obj ast.Object) string {
+	switch v := obj.(type) {
+	case *ast.Struct:
+		return v.Name
+	case *ast.Enum:
+		return v.Name
+	case *ast.Service:
+		return v.Name
+	}
+	return ""
+}
+
+func objectComment(obj ast.Object) []string {
+	switch v := obj.(type) {
+	case *ast.Struct:
+		return v.Comment
+	case *ast.Enum:
+		return v.Comment
+	case *ast.Service:
+		return v.Comment
+	}
+	return nil
+}
+
+// objectPath returns the path of obj within its package, including enclosing
+// structs.
+func objectPath(obj ast.Object) []string {
+	if s, ok := obj.(*ast.Service); ok {
+		return []string{s.Name}
+	}
+	if path := common.ObjectPath(obj); path != nil {
+		return path
+	}
+	return []string{objectName(obj)}
+}
+
+// goName returns the name of the Go type generated for obj, assuming the
+// default package name.
+func goName(obj ast.Object) string {
+	pkg := obj.Pos().File.Package.Components
+	path := objectPath(obj)
+	if s, ok := obj.(*ast.Service); ok {
+		return fmt.Sprintf("%s.%s, %s.%sClient", pkg[len(pkg)-1], s.Name, pkg[len(pkg)-1], s.Name)
+	}
+	names := make([]string, len(path))
+	for i, v := range path {
+		names[i] = strcase.ToCamel(v)
+	}
+	return pkg[len(pkg)-1] + "." + strings.Join(names, "")
+}
+
+// rubyName returns the name of the Ruby class generated for obj, assuming
+// the default module.
+func rubyName(obj ast.Object) string {
+	var names []string
+	for _, c := range obj.Pos().File.Package.Components {
+		names = append(names, strcase.ToCamel(c))
+	}
+	for _, v := range objectPath(obj) {
+		names = append(names, strcase.ToCamel(v))
+	}
+	name := strings.Join(names, "::")
+	if _, ok := obj.(*ast.Service); ok {
+		return name + ", " + name + "Client"
+	}
+	return name
+}
+
+func hoverText(obj ast.Object) string {
+	kind := "struct"
+	switch obj.(type) {
+	case *ast.Enum:
+		kind = "enum"
+	case *ast.Service:
+		kind = "service"
+	}
+	pkg := obj.Pos().File.Package.Value
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "```arf\n%s %s.%s\n```\n", kind, pkg, strings.Join(objectPath(obj), "."))
+	if c := objectComment(obj); len(c) > 0 {
+		sb.WriteString("\n")
+		for _, l := range c {
+			sb.WriteString(strings.TrimPrefix(l, " ") + "\n")
+		}
+	}
+	fmt.Fprintf(&sb, "\nGo: `%s`  \nRuby: `%s`\n", goName(obj), rubyName(obj))
+	return sb.String()
+}
+
+// nameRange returns the range of name within a given line of lines, or of
+// the whole line when name cannot be found.
+func nameRange(lines []string, line int, name string) textRange {
+	if line < 0 || line >= len(lines) {
+		return lineRange(lines, line)
+	}
+	text := lines[line]
+	for from := 0; from < len(text); {
+		i := strings.Index(text[from:], name)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(name)
+		if (start == 0 || !isWordChar(text[start-1])) && (end == len(text) || !isWordChar(text[end])) {
+			return textRange{
+				Start: position{Line: line, Character: utf16Len(text[:start])},
+				End:   position{Line: line, Character: utf16Len(text[:end])},
+			}
+		}
+		from = end
+	}
+	return lineRange(lines, line)
+}
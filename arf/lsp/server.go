@@ -0,0 +1,398 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/idlfmt"
+	"github.com/arf-rpc/idl/ast"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var primitives = []string{
+	"bool", "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64",
+	"float32", "float64", "string", "bytes", "timestamp",
+}
+
+var typeKeywords = []string{"optional", "array", "map", "stream"}
+
+// Server implements a Language Server for IDL files, communicating through
+// JSON-RPC over a pair of streams.
+type Server struct {
+	conn      *conn
+	docs      map[string]*document
+	shutdown  bool
+	exitCode  int
+	exited    bool
+	handlers  map[string]func(params json.RawMessage) (any, error)
+	notifiers map[string]func(params json.RawMessage) error
+}
+
+// NewServer returns a new Server reading requests from r and writing
+// responses to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	s := &Server{conn: newConn(r, w), docs: map[string]*document{}}
+	s.handlers = map[string]func(json.RawMessage) (any, error){
+		"initialize":                  s.initialize,
+		"shutdown":                    s.handleShutdown,
+		"textDocument/definition":     s.definition,
+		"textDocument/hover":          s.hover,
+		"textDocument/documentSymbol": s.documentSymbol,
+		"textDocument/completion":     s.completion,
+		"textDocument/formatting":     s.formatting,
+	}
+	s.notifiers = map[string]func(json.RawMessage) error{
+		"initialized":            func(json.RawMessage) error { return nil },
+		"exit":                   s.exit,
+		"textDocument/didOpen":   s.didOpen,
+		"textDocument/didChange": s.didChange,
+		"textDocument/didSave":   s.didSave,
+		"textDocument/didClose":  s.didClose,
+	}
+	return s
+}
+
+// Run processes messages until the client sends an exit notification or
+// closes the input stream, and returns the exit code the process should
+// terminate with.
+func (s *Server) Run() int {
+	for !s.exited {
+		msg, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return 1
+		}
+		if err != nil {
+			_ = s.conn.write(&message{Error: &responseError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+		if err = s.handle(msg); err != nil {
+			fmt.Fprintf(os.Stderr, "arfc lsp: %s\n", err)
+			return 1
+		}
+	}
+	return s.exitCode
+}
+
+func (s *Server) handle(msg *message) error {
+	if msg.ID == nil {
+		if fn, ok := s.notifiers[msg.Method]; ok {
+			if err := fn(msg.Params); err != nil {
+				fmt.Fprintf(os.Stderr, "arfc lsp: %s: %s\n", msg.Method, err)
+			}
+		}
+		return nil
+	}
+
+	reply := &message{ID: msg.ID}
+	fn, ok := s.handlers[msg.Method]
+	if !ok {
+		reply.Error = &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+		return s.conn.write(reply)
+	}
+	result, err := fn(msg.Params)
+	if err != nil {
+		reply.Error = &responseError{Code: codeInvalidParams, Message: err.Error()}
+		return s.conn.write(reply)
+	}
+	if reply.Result, err = json.Marshal(result); err != nil {
+		return err
+	}
+	return s.conn.write(reply)
+}
+
+func (s *Server) notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.conn.write(&message{Method: method, Params: data})
+}
+
+func (s *Server) initialize(json.RawMessage) (any, error) {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync": map[string]any{
+				"openClose": true,
+				"change":    1,
+				"save":      true,
+			},
+			"definitionProvider":     true,
+			"hoverProvider":          true,
+			"documentSymbolProvider": true,
+			"completionProvider": map[string]any{
+				"triggerCharacters": []string{"<", ",", "."},
+			},
+			"documentFormattingProvider": true,
+		},
+		"serverInfo": map[string]any{"name": "arfc"},
+	}, nil
+}
+
+func (s *Server) handleShutdown(json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) exit(json.RawMessage) error {
+	s.exited = true
+	if !s.shutdown {
+		s.exitCode = 1
+	}
+	return nil
+}
+
+func (s *Server) publish(d *document) error {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         d.uri,
+		Diagnostics: d.analyze(),
+	})
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p didOpenParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	d := &document{uri: p.TextDocument.URI, path: uriToPath(p.TextDocument.URI)}
+	d.setText(p.TextDocument.Text)
+	s.docs[d.uri] = d
+	return s.publish(d)
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p didChangeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok || len(p.ContentChanges) == 0 {
+		return nil
+	}
+	// Only full synchronization is advertised, so the last change holds the
+	// whole document.
+	d.setText(p.ContentChanges[len(p.ContentChanges)-1].Text)
+	return s.publish(d)
+}
+
+func (s *Server) didSave(params json.RawMessage) error {
+	var p documentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	if d, ok := s.docs[p.TextDocument.URI]; ok {
+		return s.publish(d)
+	}
+	return nil
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p didCloseParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []diagnostic{},
+	})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, fmt.Errorf("document %s is not open", uri)
+	}
+	return d, nil
+}
+
+func (s *Server) positionParams(params json.RawMessage) (*document, position, error) {
+	var p textDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, position{}, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	return d, p.Position, err
+}
+
+// locate returns the location of the declaration of obj, as seen from d.
+func (s *Server) locate(d *document, obj ast.Object) location {
+	pos := obj.Pos()
+	path := pos.Filename
+	if path == d.parsedPath {
+		path = d.path
+	}
+	uri := pathToURI(path)
+
+	var lines []string
+	if open, ok := s.docs[uri]; ok {
+		lines = open.lines
+	} else if data, err := os.ReadFile(path); err == nil {
+		lines = strings.Split(string(data), "\n")
+	}
+	return location{URI: uri, Range: nameRange(lines, pos.Line-1, objectName(obj))}
+}
+
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	d, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	obj, _ := d.objectAt(pos)
+	if obj == nil {
+		return nil, nil
+	}
+	return s.locate(d, obj), nil
+}
+
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	d, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	obj, r := d.objectAt(pos)
+	if obj == nil {
+		return nil, nil
+	}
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: hoverText(obj)},
+		Range:    &r,
+	}, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (any, error) {
+	var p documentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := []documentSymbol{}
+	if d.file == nil {
+		return symbols, nil
+	}
+
+	symbol := func(name, detail string, kind int, pos ast.Position) documentSymbol {
+		r := nameRange(d.lines, pos.Line-1, name)
+		return documentSymbol{Name: name, Detail: detail, Kind: kind, Range: d.lineRange(pos.Line - 1), SelectionRange: r}
+	}
+	enumSymbol := func(e *ast.Enum) documentSymbol {
+		sym := symbol(e.Name, "", symbolEnum, e.Position)
+		for _, m := range e.Members {
+			sym.Children = append(sym.Children, symbol(m.Name, fmt.Sprintf("= %d", m.Value), symbolEnumMember, m.Position))
+		}
+		return sym
+	}
+	var structSymbol func(st *ast.Struct) documentSymbol
+	structSymbol = func(st *ast.Struct) documentSymbol {
+		sym := symbol(st.Name, "", symbolStruct, st.Position)
+		for _, f := range st.Fields {
+			sym.Children = append(sym.Children, symbol(f.Name, fmt.Sprintf("%s = %d", idlfmt.TypeName(f.Type), f.ID), symbolField, f.Position))
+		}
+		for i := range st.Structs {
+			sym.Children = append(sym.Children, structSymbol(&st.Structs[i]))
+		}
+		for i := range st.Enums {
+			sym.Children = append(sym.Children, enumSymbol(&st.Enums[i]))
+		}
+		return sym
+	}
+
+	for i := range d.file.Structs {
+		symbols = append(symbols, structSymbol(&d.file.Structs[i]))
+	}
+	for i := range d.file.Enums {
+		symbols = append(symbols, enumSymbol(&d.file.Enums[i]))
+	}
+	for _, svc := range d.file.Services {
+		sym := symbol(svc.Name, "", symbolInterface, svc.Position)
+		for _, m := range svc.Methods {
+			sig := strings.TrimPrefix(idlfmt.MethodSignature(m), m.Name)
+			sym.Children = append(sym.Children, symbol(m.Name, sig, symbolMethod, m.Position))
+		}
+		symbols = append(symbols, sym)
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].Range.Start.Line < symbols[j].Range.Start.Line
+	})
+	return symbols, nil
+}
+
+func (s *Server) completion(params json.RawMessage) (any, error) {
+	d, _, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	items := []completionItem{}
+	for _, p := range primitives {
+		items = append(items, completionItem{Label: p, Kind: completionKeyword, Detail: "primitive"})
+	}
+	for _, k := range typeKeywords {
+		items = append(items, completionItem{Label: k, Kind: completionKeyword})
+	}
+	if d.tree == nil || d.file == nil {
+		return items, nil
+	}
+
+	current := d.file.Package.Value
+	add := func(obj ast.Object, name string, kind int) {
+		label := name
+		if pkg := obj.Pos().File.Package.Value; pkg != current {
+			label = pkg + "." + name
+		}
+		item := completionItem{Label: label, Kind: kind}
+		if c := objectComment(obj); len(c) > 0 {
+			item.Documentation = strings.TrimSpace(strings.Join(c, "\n"))
+		}
+		items = append(items, item)
+	}
+	for _, pkg := range d.tree.Packages {
+		for _, f := range pkg.Files {
+			for i := range f.Structs {
+				add(&f.Structs[i], f.Structs[i].Name, completionStruct)
+			}
+			for i := range f.Enums {
+				add(&f.Enums[i], f.Enums[i].Name, completionEnum)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (any, error) {
+	var p documentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	src, errs := parseSource(d.path, d.text)
+	if len(errs) > 0 {
+		return nil, errors.New(strings.ReplaceAll(strings.Join(errs, "\n"), src.parsedPath, d.path))
+	}
+	for i, imp := range src.file.Imports {
+		if value, ok := src.imports[imp.Value]; ok {
+			src.file.Imports[i].Value = value
+		}
+	}
+	formatted := idlfmt.FormatSource([]byte(d.text), src.file)
+	if string(formatted) == d.text {
+		return []textEdit{}, nil
+	}
+
+	last := len(d.lines) - 1
+	return []textEdit{{
+		Range: textRange{
+			Start: position{},
+			End:   position{Line: last, Character: utf16Len(d.lines[last])},
+		},
+		NewText: string(formatted),
+	}}, nil
+}
@@ -0,0 +1,239 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const commonSchema = `package org.example.common;
+
+# A reference to a person.
+struct Person {
+    name string = 0;
+}
+`
+
+const contactsSchema = `package org.example.contacts;
+
+import "common.arf";
+
+struct Contact {
+    person org.example.common.Person = 0;
+    email  string                    = 1;
+}
+
+service Contacts {
+    get(id string) -> Contact;
+}
+`
+
+// client drives a Server through a pair of pipes, as an editor would.
+type client struct {
+	t    *testing.T
+	conn *conn
+	id   int
+	exit chan int
+}
+
+func startServer(t *testing.T) *client {
+	t.Helper()
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, conn: newConn(clientIn, clientOut), exit: make(chan int, 1)}
+	go func() {
+		c.exit <- NewServer(serverIn, serverOut).Run()
+		_ = serverOut.Close()
+	}()
+	return c
+}
+
+func (c *client) send(id *json.RawMessage, method string, params any) {
+	c.t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err = c.conn.write(&message{ID: id, Method: method, Params: data}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(nil, method, params)
+}
+
+// call sends a request and decodes the result of its response into result.
+func (c *client) call(method string, params, result any) {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strconv.Itoa(c.id))
+	c.send(&id, method, params)
+	msg := c.read()
+	if msg.ID == nil || string(*msg.ID) != string(id) {
+		c.t.Fatalf("%s: expected a response to request %s, got %+v", method, id, msg)
+	}
+	if msg.Error != nil {
+		c.t.Fatalf("%s: %s", method, msg.Error.Message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatalf("%s: %s", method, err)
+		}
+	}
+}
+
+func (c *client) read() *message {
+	c.t.Helper()
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// diagnostics reads the diagnostics published for a document.
+func (c *client) diagnostics(uri string) []diagnostic {
+	c.t.Helper()
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %+v", msg)
+	}
+	var p publishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		c.t.Fatal(err)
+	}
+	if p.URI != uri {
+		c.t.Fatalf("expected diagnostics for %s, got %s", uri, p.URI)
+	}
+	return p.Diagnostics
+}
+
+func TestServerProtocol(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "common.arf"), []byte(commonSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "contacts.arf")
+	uri := pathToURI(path)
+	doc := map[string]any{"uri": uri}
+
+	c := startServer(t)
+	var init struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.call("initialize", map[string]any{}, &init)
+	if init.Capabilities["documentFormattingProvider"] != true {
+		t.Errorf("expected formatting to be advertised, got %v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "arf", "version": 1, "text": contactsSchema},
+	})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got %+v", diags)
+	}
+
+	// person's type is referenced at line 5.
+	var loc location
+	c.call("textDocument/definition", map[string]any{
+		"textDocument": doc, "position": position{Line: 5, Character: 30},
+	}, &loc)
+	if loc.URI != pathToURI(filepath.Join(dir, "common.arf")) || loc.Range.Start.Line != 3 {
+		t.Errorf("expected Person to be declared at line 3 of common.arf, got %+v", loc)
+	}
+
+	var h hover
+	c.call("textDocument/hover", map[string]any{
+		"textDocument": doc, "position": position{Line: 5, Character: 30},
+	}, &h)
+	if !strings.Contains(h.Contents.Value, "A reference to a person.") {
+		t.Errorf("expected hover to include the comment of Person, got %q", h.Contents.Value)
+	}
+
+	var symbols []documentSymbol
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": doc}, &symbols)
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "Contact,Contacts" {
+		t.Errorf("expected symbols Contact and Contacts, got %v", names)
+	}
+
+	var items []completionItem
+	c.call("textDocument/completion", map[string]any{
+		"textDocument": doc, "position": position{Line: 6, Character: 10},
+	}, &items)
+	found := false
+	for _, it := range items {
+		found = found || it.Label == "org.example.common.Person"
+	}
+	if !found {
+		t.Errorf("expected Person to be offered, got %+v", items)
+	}
+
+	var edits []textEdit
+	c.call("textDocument/formatting", map[string]any{"textDocument": doc}, &edits)
+	if len(edits) != 0 {
+		t.Errorf("expected the document to be formatted, got %+v", edits)
+	}
+
+	unformatted := strings.Replace(contactsSchema, "email  string                    = 1", "email string = 1", 1)
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{"text": unformatted}},
+	})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got %+v", diags)
+	}
+	c.call("textDocument/formatting", map[string]any{"textDocument": doc}, &edits)
+	if len(edits) != 1 || edits[0].NewText != contactsSchema {
+		t.Errorf("expected the document to be replaced by its formatted text, got %+v", edits)
+	}
+
+	broken := strings.Replace(contactsSchema, "string                    = 1", "strin = 1", 1)
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 3},
+		"contentChanges": []map[string]any{{"text": broken}},
+	})
+	diags := c.diagnostics(uri)
+	if len(diags) == 0 {
+		t.Fatal("expected diagnostics for the unknown type")
+	}
+	for _, d := range diags {
+		if d.Range.Start.Line != 6 {
+			t.Errorf("expected a diagnostic at line 6, got %+v", d)
+		}
+		if !strings.Contains(d.Message, path) {
+			t.Errorf("expected the message to refer to the document, got %q", d.Message)
+		}
+	}
+
+	c.notify("textDocument/didClose", map[string]any{"textDocument": doc})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("expected diagnostics to be cleared, got %+v", diags)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if code := <-c.exit; code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "common.arf" {
+			t.Errorf("expected only common.arf in %s, found %s", dir, e.Name())
+		}
+	}
+}
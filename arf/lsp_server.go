@@ -0,0 +1,16 @@
+package arf
+
+import (
+	"github.com/arf-rpc/arfc/arf/lsp"
+	"github.com/urfave/cli/v2"
+	"os"
+)
+
+// LanguageServer runs a Language Server for IDL files over the standard input
+// and output streams.
+func LanguageServer(*cli.Context) error {
+	if code := lsp.NewServer(os.Stdin, os.Stdout).Run(); code != 0 {
+		return cli.Exit("", code)
+	}
+	return nil
+}
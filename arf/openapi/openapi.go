@@ -0,0 +1,271 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/jsonschema"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.Generator {
+	return &Generator{
+		t:       tree,
+		objects: map[string]ast.Object{},
+	}
+}
+
+type Generator struct {
+	t *ast.PackageTree
+
+	// objects holds user types referenced by the document, keyed by their
+	// component name, and pending lists the ones which schemas were not yet
+	// generated.
+	objects map[string]ast.Object
+	pending []string
+}
+
+type entry[V any] struct {
+	Key   string
+	Value V
+}
+
+// orderedMap retains the insertion order of its entries when marshalled.
+type orderedMap[V any] []entry[V]
+
+func (m orderedMap[V]) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, e := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       info                  `json:"info"`
+	Tags       []tag                 `json:"tags,omitempty"`
+	Paths      orderedMap[*pathItem] `json:"paths"`
+	Components components            `json:"components"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type components struct {
+	Schemas jsonschema.Properties `json:"schemas"`
+}
+
+type pathItem struct {
+	Post *operation `json:"post"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Description string                `json:"description,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Tags        []string              `json:"tags"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   orderedMap[*response] `json:"responses"`
+}
+
+type mediaType struct {
+	Schema *jsonschema.Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                   `json:"required"`
+	Content  orderedMap[*mediaType] `json:"content"`
+}
+
+type response struct {
+	Description string                 `json:"description"`
+	Content     orderedMap[*mediaType] `json:"content,omitempty"`
+}
+
+func jsonContent(s *jsonschema.Schema) orderedMap[*mediaType] {
+	return orderedMap[*mediaType]{{Key: "application/json", Value: &mediaType{Schema: s}}}
+}
+
+func description(comment []string) string {
+	lines := make([]string, 0, len(comment))
+	for _, l := range comment {
+		lines = append(lines, strings.TrimPrefix(l, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string) {
+	targetDir = ctx.String("output")
+	targetFile = g.t.Package + ".openapi.json"
+
+	doc := &document{
+		OpenAPI: "3.1.0",
+		Info:    info{Title: g.t.Package, Version: "1.0.0"},
+		Paths:   orderedMap[*pathItem]{},
+		Components: components{
+			Schemas: jsonschema.Properties{},
+		},
+	}
+
+	// Types declared by the package are always exported, regardless of
+	// being referenced by a service.
+	for i := range g.t.Enums {
+		g.ref(&g.t.Enums[i])
+	}
+	for i := range g.t.Structures {
+		g.addStruct(&g.t.Structures[i])
+	}
+
+	for _, s := range g.t.Services {
+		doc.Tags = append(doc.Tags, tag{Name: s.Name, Description: description(s.Comment)})
+		for _, m := range s.Methods {
+			if op := g.makeOperation(&s, m); op != nil {
+				path := fmt.Sprintf("/%s/%s/%s", g.t.Package, s.Name, m.Name)
+				doc.Paths = append(doc.Paths, entry[*pathItem]{Key: path, Value: &pathItem{Post: op}})
+			}
+		}
+	}
+
+	for len(g.pending) > 0 {
+		name := g.pending[0]
+		g.pending = g.pending[1:]
+		var schema *jsonschema.Schema
+		switch v := g.objects[name].(type) {
+		case *ast.Struct:
+			schema = jsonschema.StructSchema(v, g.ref)
+		case *ast.Enum:
+			schema = jsonschema.EnumSchema(v)
+		}
+		doc.Components.Schemas = append(doc.Components.Schemas, jsonschema.Property{Name: name, Schema: schema})
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		output.Errorf("Failed encoding OpenAPI document for %s: %s", g.t.Package, err)
+	}
+	data = append(data, '\n')
+	return
+}
+
+func (g *Generator) addStruct(s *ast.Struct) {
+	for i := range s.Structs {
+		g.addStruct(&s.Structs[i])
+	}
+	for i := range s.Enums {
+		g.ref(&s.Enums[i])
+	}
+	g.ref(s)
+}
+
+// componentName returns the name of the component schema of a given user
+// type. Types from other packages are prefixed by their package name.
+func (g *Generator) componentName(obj ast.Object) string {
+	var name, pkg string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name, pkg = common.StructName(v), v.Position.File.Package.Value
+	case *ast.Enum:
+		name, pkg = common.EnumName(v), v.Position.File.Package.Value
+	}
+	if pkg == g.t.Package {
+		return name
+	}
+	return strcase.ToCamel(strings.ReplaceAll(pkg, ".", "_")) + name
+}
+
+// ref returns the reference to the component schema of a given user type,
+// scheduling it to be included in the document.
+func (g *Generator) ref(obj ast.Object) string {
+	name := g.componentName(obj)
+	if _, ok := g.objects[name]; !ok {
+		g.objects[name] = obj
+		g.pending = append(g.pending, name)
+	}
+	return "#/components/schemas/" + name
+}
+
+// makeOperation returns the operation for a given method, or nil in case the
+// method streams requests or responses, which cannot be described by OpenAPI.
+func (g *Generator) makeOperation(s *ast.Service, m *ast.ServiceMethod) *operation {
+	for _, p := range m.Params {
+		if p.Stream {
+			return nil
+		}
+	}
+	for _, r := range m.Returns {
+		if r.Stream {
+			return nil
+		}
+	}
+
+	op := &operation{
+		OperationID: s.Name + "_" + m.Name,
+		Description: description(m.Comment),
+		Deprecated:  m.Annotations.ByName("deprecated") != nil,
+		Tags:        []string{s.Name},
+		Responses:   orderedMap[*response]{},
+	}
+
+	if len(m.Params) > 0 {
+		body := &jsonschema.Schema{
+			Type:                 "object",
+			Properties:           jsonschema.Properties{},
+			AdditionalProperties: false,
+		}
+		for _, p := range m.Params {
+			body.Properties = append(body.Properties, jsonschema.Property{
+				Name:   *p.Name,
+				Schema: jsonschema.TypeSchema(p.Type, g.ref),
+			})
+			if _, ok := p.Type.(*ast.OptionalType); !ok {
+				body.Required = append(body.Required, *p.Name)
+			}
+		}
+		op.RequestBody = &requestBody{Required: true, Content: jsonContent(body)}
+	}
+
+	res := &response{Description: "Successful response"}
+	switch len(m.Returns) {
+	case 0:
+	case 1:
+		res.Content = jsonContent(jsonschema.TypeSchema(m.Returns[0].Type, g.ref))
+	default:
+		// Multiple return values are represented as a tuple.
+		n := len(m.Returns)
+		tuple := &jsonschema.Schema{Type: "array", MinItems: &n, MaxItems: &n}
+		for _, r := range m.Returns {
+			tuple.PrefixItems = append(tuple.PrefixItems, jsonschema.TypeSchema(r.Type, g.ref))
+		}
+		res.Content = jsonContent(tuple)
+	}
+	op.Responses = append(op.Responses, entry[*response]{Key: "200", Value: res})
+	return op
+}
@@ -0,0 +1,468 @@
+package php
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.MultiFileGenerator {
+	return &Generator{
+		t: tree,
+	}
+}
+
+type Generator struct {
+	t *ast.PackageTree
+
+	namespaceMapping map[string]string
+	targetDir        string
+	files            []common.File
+}
+
+var namespaceValidator = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\\[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+func (g *Generator) loadNamespaceMapping(ctx *cli.Context) {
+	g.namespaceMapping = map[string]string{}
+	for _, ns := range ctx.StringSlice("php-namespace") {
+		comps := strings.SplitN(ns, "=", 2)
+		if len(comps) != 2 {
+			output.Errorf("Invalid value for php-namespace: %s", ns)
+		}
+		pkg, name := strings.TrimSpace(comps[0]), strings.Trim(strings.TrimSpace(comps[1]), `\`)
+		if !namespaceValidator.MatchString(name) {
+			output.Errorf("Invalid namespace %s for php-namespace: %s", pkg, name)
+		}
+		g.namespaceMapping[pkg] = name
+	}
+}
+
+func (g *Generator) namespaceFor(pkg string) string {
+	if ns, ok := g.namespaceMapping[pkg]; ok {
+		return ns
+	}
+	comps := strings.Split(pkg, ".")
+	for i, c := range comps {
+		comps[i] = strcase.ToCamel(c)
+	}
+	return strings.Join(comps, `\`)
+}
+
+func variableName(name string) string {
+	return "$" + strcase.ToLowerCamel(name)
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(s) + "'"
+}
+
+func (g *Generator) GenFiles(ctx *cli.Context) []common.File {
+	g.loadNamespaceMapping(ctx)
+	// Following PSR-4, each namespace component maps to a directory, and each
+	// type is written to a file named after it.
+	dirs := strings.Split(g.namespaceFor(g.t.Package), `\`)
+	g.targetDir = filepath.Join(append([]string{ctx.String("output")}, dirs...)...)
+
+	for _, e := range g.t.Enums {
+		g.makeEnum(&e)
+	}
+
+	for _, s := range g.t.Structures {
+		g.makeStruct(&s)
+	}
+
+	for _, s := range g.t.Services {
+		g.makeService(&s)
+		g.makeClient(&s)
+	}
+
+	return append(g.files, runtimeFiles(ctx.String("output"))...)
+}
+
+func (g *Generator) newFile() *common.Writer {
+	w := &common.Writer{Indentation: "    "}
+	w.Writelnf("<?php")
+	w.Break()
+	w.Writelnf("// Code generated by arfc. DO NOT EDIT.")
+	w.Break()
+	w.Writelnf("declare(strict_types=1);")
+	w.Break()
+	w.Writelnf("namespace %s;", g.namespaceFor(g.t.Package))
+	return w
+}
+
+func (g *Generator) addFile(name string, w *common.Writer) {
+	g.files = append(g.files, common.File{
+		Data:       []byte(w.String()),
+		TargetDir:  g.targetDir,
+		TargetFile: name + ".php",
+	})
+}
+
+func writeDocBlock(w *common.Writer, c []string, set ast.AnnotationSet, tags []string) {
+	lines := make([]string, 0, len(c))
+	for _, l := range c {
+		lines = append(lines, strings.TrimPrefix(l, " "))
+	}
+	if len(tags) > 0 && len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, tags...)
+	if ann := set.ByName("deprecated"); ann != nil {
+		if len(tags) == 0 && len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		if len(ann.Arguments) > 0 {
+			lines = append(lines, fmt.Sprintf("@deprecated %s", ann.Arguments[0]))
+		} else {
+			lines = append(lines, "@deprecated")
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	w.Writelnf("/**")
+	for _, l := range lines {
+		if l == "" {
+			w.Writelnf(" *")
+			continue
+		}
+		w.Writelnf(" * %s", strings.ReplaceAll(l, "*/", "*\\/"))
+	}
+	w.Writelnf(" */")
+}
+
+func caseName(name string) string {
+	return strcase.ToCamel(name)
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	name := common.PathName(e)
+	w := g.newFile()
+	w.Break()
+	writeDocBlock(w, e.Comment, e.Annotations, nil)
+	w.Writelnf("enum %s: int", name)
+	w.Writelnf("{")
+	w.IncreaseIndent()
+	// Backed enums cannot declare two cases with the same value, so aliases
+	// are exposed as constants referencing the first case declared with it.
+	cases := map[int]string{}
+	var aliases []ast.EnumMember
+	for _, v := range e.Members {
+		if _, ok := cases[v.Value]; ok {
+			aliases = append(aliases, v)
+			continue
+		}
+		cases[v.Value] = caseName(v.Name)
+		writeDocBlock(w, v.Comment, v.Annotations, nil)
+		w.Writelnf("case %s = %d;", caseName(v.Name), v.Value)
+	}
+	if len(aliases) > 0 {
+		w.Break()
+	}
+	for _, v := range aliases {
+		writeDocBlock(w, v.Comment, v.Annotations, nil)
+		w.Writelnf("public const %s = self::%s;", caseName(v.Name), cases[v.Value])
+	}
+	w.DecreaseIndent()
+	w.Writelnf("}")
+	g.addFile(name, w)
+}
+
+func (g *Generator) makeStruct(s *ast.Struct) {
+	for _, st := range s.Structs {
+		g.makeStruct(&st)
+	}
+
+	for _, e := range s.Enums {
+		g.makeEnum(&e)
+	}
+
+	name := common.PathName(s)
+	w := g.newFile()
+	w.Break()
+	writeDocBlock(w, s.Comment, s.Annotations, nil)
+	w.Writelnf("#[\\%s\\ArfStruct(%s)]", runtimeNamespace, quote(common.CanonicalStructName(g.t.Package, s)))
+	w.Writelnf("final class %s", name)
+	w.Writelnf("{")
+	w.IncreaseIndent()
+
+	var tags []string
+	for _, f := range s.Fields {
+		if doc := g.docType(f.Type); doc != g.phpType(f.Type) {
+			tags = append(tags, fmt.Sprintf("@param %s %s", doc, variableName(f.Name)))
+		}
+	}
+	writeDocBlock(w, nil, nil, tags)
+	if len(s.Fields) == 0 {
+		w.Writelnf("public function __construct()")
+		w.Writelnf("{")
+		w.Writelnf("}")
+	} else {
+		w.Writelnf("public function __construct(")
+		w.IncreaseIndent()
+		for i, f := range s.Fields {
+			if i > 0 {
+				w.Break()
+			}
+			writeDocBlock(w, f.Comment, f.Annotations, nil)
+			w.Writelnf("#[\\%s\\ArfField(%d)]", runtimeNamespace, f.ID)
+			w.Writelnf("public readonly %s %s = %s,", g.phpType(f.Type), variableName(f.Name), g.defaultValue(f.Type))
+		}
+		w.DecreaseIndent()
+		w.Writelnf(") {")
+		w.Writelnf("}")
+	}
+
+	w.DecreaseIndent()
+	w.Writelnf("}")
+	g.addFile(name, w)
+}
+
+type method struct {
+	name       string
+	params     []string
+	paramNames []string
+	tags       []string
+	returnType string
+	outputs    int
+	hasStream  bool
+	outStream  bool
+}
+
+func (g *Generator) makeMethod(m *ast.ServiceMethod) *method {
+	def := &method{name: strcase.ToLowerCamel(m.Name)}
+	for _, p := range m.Params {
+		if p.Stream {
+			def.hasStream = true
+			def.tags = append(def.tags, fmt.Sprintf("@param iterable<%s> $stream", g.docType(p.Type)))
+			continue
+		}
+		name := variableName(*p.Name)
+		def.params = append(def.params, fmt.Sprintf("%s %s", g.phpType(p.Type), name))
+		def.paramNames = append(def.paramNames, name)
+		if doc := g.docType(p.Type); doc != g.phpType(p.Type) {
+			def.tags = append(def.tags, fmt.Sprintf("@param %s %s", doc, name))
+		}
+	}
+	if def.hasStream {
+		def.params = append(def.params, "iterable $stream")
+	}
+
+	var native, docs []string
+	for _, r := range m.Returns {
+		if r.Stream {
+			def.outStream = true
+			continue
+		}
+		def.outputs++
+		native = append(native, g.phpType(r.Type))
+		docs = append(docs, g.docType(r.Type))
+	}
+	if def.outStream {
+		var stream ast.Type
+		for _, r := range m.Returns {
+			if r.Stream {
+				stream = r.Type
+			}
+		}
+		native = append(native, "iterable")
+		docs = append(docs, fmt.Sprintf("iterable<%s>", g.docType(stream)))
+	}
+
+	switch len(native) {
+	case 0:
+		def.returnType = "void"
+	case 1:
+		def.returnType = native[0]
+		if docs[0] != native[0] {
+			def.tags = append(def.tags, fmt.Sprintf("@return %s", docs[0]))
+		}
+	default:
+		def.returnType = "array"
+		def.tags = append(def.tags, fmt.Sprintf("@return array{%s}", strings.Join(docs, ", ")))
+	}
+	return def
+}
+
+func (g *Generator) makeService(s *ast.Service) {
+	name := strcase.ToCamel(s.Name)
+	w := g.newFile()
+	w.Break()
+	writeDocBlock(w, s.Comment, s.Annotations, nil)
+	w.Writelnf("interface %s", name)
+	w.Writelnf("{")
+	w.IncreaseIndent()
+	w.Writelnf("public const SERVICE_ID = %s;", quote(fmt.Sprintf("%s/%s", g.t.Package, s.Name)))
+
+	for _, m := range s.Methods {
+		def := g.makeMethod(m)
+		w.Break()
+		writeDocBlock(w, m.Comment, m.Annotations, def.tags)
+		w.Writelnf("public function %s(%s): %s;", def.name, strings.Join(def.params, ", "), def.returnType)
+	}
+
+	w.DecreaseIndent()
+	w.Writelnf("}")
+	g.addFile(name, w)
+}
+
+func (g *Generator) makeClient(s *ast.Service) {
+	service := strcase.ToCamel(s.Name)
+	name := service + "Client"
+	w := g.newFile()
+	w.Break()
+	writeDocBlock(w, s.Comment, s.Annotations, nil)
+	w.Writelnf("final class %s implements %s", name, service)
+	w.Writelnf("{")
+	w.IncreaseIndent()
+	w.Writelnf("public function __construct(private readonly \\%s\\Client $client)", runtimeNamespace)
+	w.Writelnf("{")
+	w.Writelnf("}")
+
+	for _, m := range s.Methods {
+		def := g.makeMethod(m)
+		w.Break()
+		writeDocBlock(w, nil, nil, []string{"{@inheritDoc}"})
+		w.Writelnf("public function %s(%s): %s", def.name, strings.Join(def.params, ", "), def.returnType)
+		w.Writelnf("{")
+		w.IncreaseIndent()
+		w.Writef("$call = $this->client->call(self::SERVICE_ID, %s, [%s]", quote(m.Name), strings.Join(def.paramNames, ", "))
+		if def.hasStream {
+			w.Writef(", stream: $stream")
+		}
+		w.Writelnf(");")
+		switch {
+		case def.outputs == 0 && !def.outStream:
+			w.Writelnf("$call->result();")
+		case def.outputs == 0:
+			w.Writelnf("return $call->responses();")
+		case def.outStream:
+			w.Writelnf("return [...$call->result(), $call->responses()];")
+		case def.outputs == 1:
+			w.Writelnf("return $call->result()[0];")
+		default:
+			w.Writelnf("return $call->result();")
+		}
+		w.DecreaseIndent()
+		w.Writelnf("}")
+	}
+
+	w.DecreaseIndent()
+	w.Writelnf("}")
+	g.addFile(name, w)
+}
+
+func resolved(t ast.Type) ast.Object {
+	switch v := t.(type) {
+	case *ast.SimpleUserType:
+		return v.ResolvedType
+	case *ast.FullQualifiedType:
+		return v.ResolvedType
+	}
+	return nil
+}
+
+func (g *Generator) userTypeName(obj ast.Object) string {
+	var name, pkg string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name, pkg = common.PathName(v), v.Position.File.Package.Value
+	case *ast.Enum:
+		name, pkg = common.PathName(v), v.Position.File.Package.Value
+	default:
+		return "INVALID"
+	}
+	if pkg != g.t.Package {
+		return `\` + g.namespaceFor(pkg) + `\` + name
+	}
+	return name
+}
+
+// phpType returns the native type declaration for a given type.
+func (g *Generator) phpType(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string", "bytes":
+			return "string"
+		case "bool":
+			return "bool"
+		case "float32", "float64":
+			return "float"
+		case "timestamp":
+			return `\DateTimeImmutable`
+		default:
+			return "int"
+		}
+	case *ast.OptionalType:
+		return "?" + g.phpType(v.Type)
+	case *ast.ArrayType, *ast.MapType:
+		return "array"
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		return g.userTypeName(resolved(v))
+	default:
+		return "INVALID"
+	}
+}
+
+// docType returns the type of t as understood by static analysis tools,
+// which is more specific than phpType for collections.
+func (g *Generator) docType(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.OptionalType:
+		if inner := g.docType(v.Type); inner != g.phpType(v.Type) {
+			return inner + "|null"
+		}
+		return g.phpType(t)
+	case *ast.ArrayType:
+		return fmt.Sprintf("list<%s>", g.docType(v.Type))
+	case *ast.MapType:
+		// Array keys can only be integers or strings, so enum keys are
+		// represented by their backing values.
+		key := g.docType(v.Key)
+		if _, ok := resolved(v.Key).(*ast.Enum); ok {
+			key = "int"
+		}
+		return fmt.Sprintf("array<%s, %s>", key, g.docType(v.Value))
+	default:
+		return g.phpType(t)
+	}
+}
+
+func (g *Generator) defaultValue(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string", "bytes":
+			return "''"
+		case "bool":
+			return "false"
+		case "float32", "float64":
+			return "0.0"
+		case "timestamp":
+			return `new \DateTimeImmutable('@0')`
+		default:
+			return "0"
+		}
+	case *ast.OptionalType:
+		return "null"
+	case *ast.ArrayType, *ast.MapType:
+		return "[]"
+	case *ast.SimpleUserType, *ast.FullQualifiedType:
+		switch obj := resolved(v).(type) {
+		case *ast.Enum:
+			return g.userTypeName(obj) + "::" + caseName(obj.Members[0].Name)
+		case *ast.Struct:
+			return "new " + g.userTypeName(obj) + "()"
+		}
+	}
+	return "null"
+}
@@ -0,0 +1,126 @@
+package php
+
+import (
+	"github.com/arf-rpc/arfc/arf/common"
+	"path/filepath"
+)
+
+// runtimeNamespace is the namespace of the contract generated sources depend
+// on. It is the same for all packages, and written alongside each of them.
+const runtimeNamespace = `Arf\Rpc`
+
+// runtime holds the sources declaring runtimeNamespace, keyed by class name.
+// Client and Call are implemented by applications over the transport of their
+// choice; ArfStruct and ArfField describe generated structs to them.
+var runtime = []struct {
+	name string
+	data string
+}{
+	{"Client", `<?php
+
+// Code generated by arfc. DO NOT EDIT.
+
+declare(strict_types=1);
+
+namespace Arf\Rpc;
+
+/**
+ * Client carries calls made by generated clients to arf services.
+ */
+interface Client
+{
+    /**
+     * Starts a call to method of the service identified by $serviceId.
+     * $params holds the values of the method's parameters, in declaration
+     * order. $stream, when present, yields the values of its streamed
+     * parameter.
+     *
+     * @param list<mixed> $params
+     * @param iterable<mixed>|null $stream
+     */
+    public function call(string $serviceId, string $method, array $params, ?iterable $stream = null): Call;
+}
+`},
+	{"Call", `<?php
+
+// Code generated by arfc. DO NOT EDIT.
+
+declare(strict_types=1);
+
+namespace Arf\Rpc;
+
+/**
+ * Call is a call started through a Client.
+ */
+interface Call
+{
+    /**
+     * Waits for the call to complete, returning the values of the method's
+     * non-streamed return values, in declaration order. Throws when the
+     * service reports an error.
+     *
+     * @return list<mixed>
+     */
+    public function result(): array;
+
+    /**
+     * Returns the values of the method's streamed return value, as they are
+     * received.
+     *
+     * @return iterable<mixed>
+     */
+    public function responses(): iterable;
+}
+`},
+	{"ArfStruct", `<?php
+
+// Code generated by arfc. DO NOT EDIT.
+
+declare(strict_types=1);
+
+namespace Arf\Rpc;
+
+/**
+ * ArfStruct marks a class generated for an arf struct, holding the struct's
+ * canonical name.
+ */
+#[\Attribute(\Attribute::TARGET_CLASS)]
+final class ArfStruct
+{
+    public function __construct(public readonly string $name)
+    {
+    }
+}
+`},
+	{"ArfField", `<?php
+
+// Code generated by arfc. DO NOT EDIT.
+
+declare(strict_types=1);
+
+namespace Arf\Rpc;
+
+/**
+ * ArfField marks a property generated for a field of an arf struct, holding
+ * the field's ID.
+ */
+#[\Attribute(\Attribute::TARGET_PROPERTY | \Attribute::TARGET_PARAMETER)]
+final class ArfField
+{
+    public function __construct(public readonly int $id)
+    {
+    }
+}
+`},
+}
+
+// runtimeFiles returns the files declaring runtimeNamespace, placed under root
+// following PSR-4.
+func runtimeFiles(root string) []common.File {
+	dir := filepath.Join(root, "Arf", "Rpc")
+	files := make([]common.File, 0, len(runtime))
+	for _, r := range runtime {
+		files = append(files, common.File{Data: []byte(r.data), TargetDir: dir, TargetFile: r.name + ".php"})
+	}
+	return files
+}
@@ -0,0 +1,502 @@
+package proto
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/idlfmt"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// scalars maps protobuf scalar types to arf primitives.
+var scalars = map[string]string{
+	"double":   "float64",
+	"float":    "float32",
+	"int32":    "int32",
+	"sint32":   "int32",
+	"sfixed32": "int32",
+	"int64":    "int64",
+	"sint64":   "int64",
+	"sfixed64": "int64",
+	"uint32":   "uint32",
+	"fixed32":  "uint32",
+	"uint64":   "uint64",
+	"fixed64":  "uint64",
+	"bool":     "bool",
+	"string":   "string",
+	"bytes":    "bytes",
+}
+
+// wrappers maps well-known wrapper messages to the arf primitive they carry.
+// Those are translated as optional values.
+var wrappers = map[string]string{
+	"google.protobuf.DoubleValue": "float64",
+	"google.protobuf.FloatValue":  "float32",
+	"google.protobuf.Int32Value":  "int32",
+	"google.protobuf.Int64Value":  "int64",
+	"google.protobuf.UInt32Value": "uint32",
+	"google.protobuf.UInt64Value": "uint64",
+	"google.protobuf.BoolValue":   "bool",
+	"google.protobuf.StringValue": "string",
+	"google.protobuf.BytesValue":  "bytes",
+}
+
+const (
+	timestampType = "google.protobuf.Timestamp"
+	emptyType     = "google.protobuf.Empty"
+)
+
+// symbol represents a message or enum declared in a parsed .proto file.
+type symbol struct {
+	file    *protoFile
+	message bool
+}
+
+// Importer converts .proto files into arf IDL files.
+type Importer struct {
+	// IncludePaths lists directories used to resolve imports, in the same
+	// fashion as protoc's --proto_path.
+	IncludePaths []string
+
+	files   map[string]*protoFile
+	symbols map[string]symbol
+	// arfNames maps the names arf resolves types by to their
+	// fully-qualified protobuf names. See arfName.
+	arfNames map[string]string
+}
+
+// Import converts the .proto file at path, returning the relative path of
+// the .arf file to be created (mirroring the path of the source file within
+// its include path), and its contents.
+func (im *Importer) Import(path string) (target string, data []byte, err error) {
+	if im.files == nil {
+		im.files = map[string]*protoFile{}
+		im.symbols = map[string]symbol{}
+		im.arfNames = map[string]string{}
+	}
+
+	rel, root := im.relativePath(path)
+	f, err := im.load(rel, root)
+	if err != nil {
+		return "", nil, err
+	}
+	if f.pkg == "" {
+		return "", nil, fmt.Errorf("%s: files without a package declaration cannot be imported", path)
+	}
+
+	c := &converter{im: im, f: f, file: &ast.File{Path: path}}
+	c.convert()
+	target = strings.TrimSuffix(rel, ".proto") + ".arf"
+	return target, idlfmt.Format(c.file), nil
+}
+
+// relativePath returns the path of a given file relative to the include path
+// containing it, along with the include path itself. In case no include path
+// contains it, the file's directory is used.
+func (im *Importer) relativePath(path string) (rel, root string) {
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		for _, inc := range im.IncludePaths {
+			incAbs, err := filepath.Abs(inc)
+			if err != nil {
+				continue
+			}
+			r, err := filepath.Rel(incAbs, abs)
+			if err == nil && !strings.HasPrefix(r, "..") {
+				return filepath.ToSlash(r), inc
+			}
+		}
+	}
+	return filepath.Base(path), filepath.Dir(path)
+}
+
+// load parses the file at a given path relative to root, along with its
+// imports, registering declared types.
+func (im *Importer) load(rel, root string) (*protoFile, error) {
+	if f, ok := im.files[rel]; ok {
+		return f, nil
+	}
+	data, err := os.ReadFile(filepath.Join(root, rel))
+	if err != nil {
+		return nil, err
+	}
+	f, err := parseProto(rel, data)
+	if err != nil {
+		return nil, err
+	}
+	im.files[rel] = f
+	for _, m := range f.messages {
+		im.registerMessage(f, f.pkg, m)
+	}
+	for _, e := range f.enums {
+		im.register(f, qualify(f.pkg, e.name), false)
+	}
+
+	for _, imp := range f.imports {
+		if strings.HasPrefix(imp, "google/protobuf/") {
+			continue
+		}
+		if _, ok := im.files[imp]; ok {
+			continue
+		}
+		found := false
+		for _, dir := range append([]string{root}, im.IncludePaths...) {
+			if _, err := os.Stat(filepath.Join(dir, imp)); err == nil {
+				if _, err := im.load(imp, dir); err != nil {
+					return nil, err
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			output.Warnf("%s: cannot locate import %s; types declared by it will be referenced as written", rel, imp)
+		}
+	}
+	return f, nil
+}
+
+func (im *Importer) registerMessage(f *protoFile, scope string, m *protoMessage) {
+	name := qualify(scope, m.name)
+	im.register(f, name, true)
+	for _, n := range m.messages {
+		im.registerMessage(f, name, n)
+	}
+	for _, e := range m.enums {
+		im.register(f, qualify(name, e.name), false)
+	}
+}
+
+func (im *Importer) register(f *protoFile, fqn string, message bool) {
+	im.symbols[fqn] = symbol{file: f, message: message}
+	im.arfNames[arfName(f.pkg, fqn)] = fqn
+}
+
+// arfName returns the name arf knows a type by. The IDL parser only records
+// the immediate parent of nested declarations, so types are named after
+// their package, their parent, if any, and themselves.
+func arfName(pkg, fqn string) string {
+	comps := strings.Split(strings.TrimPrefix(fqn, pkg+"."), ".")
+	if len(comps) > 2 {
+		comps = comps[len(comps)-2:]
+	}
+	return qualify(pkg, strings.Join(comps, "."))
+}
+
+// arfScope returns the scope arf resolves types referenced by fields of a
+// given message from. As with arfName, parents of the message are omitted.
+func arfScope(pkg, scope string) string {
+	if scope == pkg {
+		return pkg
+	}
+	return qualify(pkg, scope[strings.LastIndex(scope, ".")+1:])
+}
+
+// arfResolve returns the fully-qualified protobuf name of the type arf
+// resolves name to, walking outwards from its scope in the same fashion as
+// the IDL parser. Returns an empty string if none matches.
+func (im *Importer) arfResolve(name string) string {
+	comps := strings.Split(name, ".")
+	last := comps[len(comps)-1]
+	for comps = comps[:len(comps)-1]; len(comps) > 0; comps = comps[:len(comps)-1] {
+		if fqn, ok := im.arfNames[strings.Join(append(comps, last), ".")]; ok {
+			return fqn
+		}
+	}
+	return ""
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// resolve returns the fully-qualified name of a type referenced as name from
+// within scope, following protobuf's scoping rules. ok is false when the
+// type is not known.
+func (im *Importer) resolve(scope, name string) (fqn string, ok bool) {
+	if strings.HasPrefix(name, ".") {
+		fqn = name[1:]
+		_, ok = im.symbols[fqn]
+		return fqn, ok
+	}
+	for {
+		candidate := qualify(scope, name)
+		if _, ok := im.symbols[candidate]; ok {
+			return candidate, true
+		}
+		if scope == "" {
+			return name, false
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+type converter struct {
+	im   *Importer
+	f    *protoFile
+	file *ast.File
+}
+
+func (c *converter) convert() {
+	c.file.Package = ast.Package{Value: c.f.pkg, Components: strings.Split(c.f.pkg, ".")}
+	dir := filepath.Dir(c.f.path)
+	for _, imp := range c.f.imports {
+		if strings.HasPrefix(imp, "google/protobuf/") {
+			continue
+		}
+		rel, err := filepath.Rel(dir, strings.TrimSuffix(imp, ".proto"))
+		if err != nil {
+			rel = strings.TrimSuffix(imp, ".proto")
+		}
+		c.file.Imports = append(c.file.Imports, ast.Import{Value: filepath.ToSlash(rel)})
+	}
+
+	for _, m := range c.f.messages {
+		c.file.Structs = append(c.file.Structs, c.convertMessage(c.f.pkg, m))
+	}
+	for _, e := range c.f.enums {
+		c.file.Enums = append(c.file.Enums, convertEnum(qualify(c.f.pkg, e.name), e))
+	}
+	for _, s := range c.f.services {
+		c.file.Services = append(c.file.Services, c.convertService(s))
+	}
+}
+
+// annotations returns the comments and annotations of a declaration. A
+// trailing "Deprecated:" comment line, as emitted by the proto generator,
+// becomes the message of the @deprecated annotation.
+func annotations(comments []string, deprecated bool) ([]string, ast.AnnotationSet) {
+	if !deprecated {
+		return comments, nil
+	}
+	ann := ast.Annotation{Name: "deprecated"}
+	if n := len(comments); n > 0 {
+		if msg, ok := strings.CutPrefix(strings.TrimSpace(comments[n-1]), "Deprecated:"); ok {
+			comments = comments[:n-1]
+			if msg = strings.TrimSpace(msg); msg != "" {
+				ann.Arguments = []any{msg}
+			}
+		}
+	}
+	return comments, ast.AnnotationSet{ann}
+}
+
+// warnReserved reports reserved tags or values, and names, of a given message
+// or enum, as arf has no means of reserving them. offset is subtracted from
+// reserved tags to obtain the corresponding arf field IDs.
+func warnReserved(kind, fqn string, r protoReserved, offset int) {
+	var items []string
+	for _, rng := range r.ranges {
+		switch {
+		case rng.end == nil:
+			items = append(items, fmt.Sprintf("%d to max", rng.start-offset))
+		case *rng.end != rng.start:
+			items = append(items, fmt.Sprintf("%d to %d", rng.start-offset, *rng.end-offset))
+		default:
+			items = append(items, fmt.Sprint(rng.start-offset))
+		}
+	}
+	for _, n := range r.names {
+		items = append(items, strconv.Quote(n))
+	}
+	if len(items) > 0 {
+		output.Warnf("%s %s reserves %s, which cannot be represented in arf and were dropped; make sure they are not reused",
+			kind, fqn, strings.Join(items, ", "))
+	}
+}
+
+func (c *converter) convertMessage(scope string, m *protoMessage) ast.Struct {
+	name := qualify(scope, m.name)
+	s := ast.Struct{Position: ast.Position{Line: m.line}, Name: m.name}
+	s.Comment, s.Annotations = annotations(m.comments, m.deprec)
+	warnReserved("Message", name, m.reserved, TagOffset)
+	for _, f := range m.fields {
+		t, ok := c.fieldType(name, f)
+		if !ok {
+			output.Warnf("Field %s of %s cannot be represented in arf and was omitted", f.name, name)
+			continue
+		}
+		field := ast.StructField{
+			Position: ast.Position{Line: f.line},
+			Name:     f.name,
+			Type:     t,
+			ID:       f.tag - TagOffset,
+		}
+		field.Comment, field.Annotations = annotations(f.comments, f.deprec)
+		s.Fields = append(s.Fields, field)
+	}
+	for _, n := range m.messages {
+		s.Structs = append(s.Structs, c.convertMessage(name, n))
+	}
+	for _, e := range m.enums {
+		s.Enums = append(s.Enums, convertEnum(qualify(name, e.name), e))
+	}
+	return s
+}
+
+func convertEnum(fqn string, e *protoEnum) ast.Enum {
+	en := ast.Enum{Position: ast.Position{Line: e.line}, Name: e.name}
+	en.Comment, en.Annotations = annotations(e.comments, e.deprec)
+	warnReserved("Enum", fqn, e.reserved, 0)
+	names := memberNames(e)
+	for i, v := range e.values {
+		m := ast.EnumMember{Name: names[i], Value: v.value}
+		m.Comment, m.Annotations = annotations(v.comments, v.deprec)
+		en.Members = append(en.Members, m)
+	}
+	return en
+}
+
+// memberNames returns the names of the values of an enum. Values prefixed
+// by the name of their enum, as the style guide recommends and the proto
+// generator emits, have their prefix removed, unless doing so would leave a
+// value without a valid or unique name.
+func memberNames(e *protoEnum) []string {
+	prefix := strcase.ToScreamingSnake(e.name) + "_"
+	names := make([]string, 0, len(e.values))
+	stripped := make([]string, 0, len(e.values))
+	seen := map[string]bool{}
+	for _, v := range e.values {
+		names = append(names, v.name)
+		name, ok := strings.CutPrefix(v.name, prefix)
+		if ok && name != "" && unicode.IsLetter(rune(name[0])) && !seen[name] {
+			seen[name] = true
+			stripped = append(stripped, name)
+		}
+	}
+	if len(stripped) == len(names) {
+		return stripped
+	}
+	return names
+}
+
+func (c *converter) fieldType(scope string, f *protoField) (ast.Type, bool) {
+	if f.mapKey != "" {
+		key, ok := c.typeFor(scope, f.mapKey)
+		if !ok || isOptional(key) {
+			return nil, false
+		}
+		value, ok := c.typeFor(scope, f.mapValue)
+		if !ok {
+			return nil, false
+		}
+		return &ast.MapType{Key: key, Value: value}, true
+	}
+
+	t, ok := c.typeFor(scope, f.typ)
+	if !ok {
+		return nil, false
+	}
+	switch {
+	case f.label == "repeated":
+		if isOptional(t) {
+			return nil, false
+		}
+		return &ast.ArrayType{Type: t}, true
+	case isOptional(t):
+		return t, true
+	case f.label == "optional", f.oneof:
+		return &ast.OptionalType{Type: t}, true
+	case f.label != "required" && c.isMessage(scope, f.typ):
+		// Singular message fields carry presence.
+		return &ast.OptionalType{Type: t}, true
+	}
+	return t, true
+}
+
+func isOptional(t ast.Type) bool {
+	_, ok := t.(*ast.OptionalType)
+	return ok
+}
+
+func (c *converter) isMessage(scope, name string) bool {
+	if _, ok := scalars[name]; ok {
+		return false
+	}
+	fqn, ok := c.im.resolve(scope, name)
+	if !ok {
+		return false
+	}
+	return c.im.symbols[fqn].message
+}
+
+// typeFor returns the arf type corresponding to a protobuf type referenced
+// from within scope. Well-known wrapper types yield optional types.
+func (c *converter) typeFor(scope, name string) (ast.Type, bool) {
+	if p, ok := scalars[name]; ok {
+		return &ast.PrimitiveType{Name: p}, true
+	}
+	fqn, known := c.im.resolve(scope, name)
+	switch fqn {
+	case timestampType:
+		return &ast.PrimitiveType{Name: "timestamp"}, true
+	}
+	if p, ok := wrappers[fqn]; ok {
+		return &ast.OptionalType{Type: &ast.PrimitiveType{Name: p}}, true
+	}
+	if strings.HasPrefix(fqn, "google.protobuf.") {
+		return nil, false
+	}
+	if !known {
+		return &ast.FullQualifiedType{FullName: strings.TrimPrefix(name, ".")}, true
+	}
+
+	// Types are referenced by their simple names whenever arf resolves those
+	// to them from scope, and by their fully-qualified names otherwise.
+	simple := fqn[strings.LastIndex(fqn, ".")+1:]
+	if c.im.arfResolve(arfScope(c.f.pkg, scope)+"."+simple) == fqn {
+		return &ast.SimpleUserType{Name: simple}, true
+	}
+	if c.im.arfResolve(fqn) != fqn {
+		output.Warnf("%s cannot be referenced from %s, as arf only resolves types nested more than one level deep from within their parent; consider moving it to an outer scope", fqn, scope)
+	}
+	return &ast.FullQualifiedType{FullName: fqn}, true
+}
+
+func (c *converter) convertService(s *protoService) ast.Service {
+	svc := ast.Service{Position: ast.Position{Line: s.line}, Name: s.name}
+	svc.Comment, svc.Annotations = annotations(s.comments, s.deprec)
+	for _, r := range s.rpcs {
+		m := &ast.ServiceMethod{Name: r.name}
+		m.Comment, m.Annotations = annotations(r.comments, r.deprec)
+		if t, ok := c.methodType(s, r, r.input); ok {
+			p := &ast.MethodParam{Type: t, Stream: r.inputStream}
+			if !r.inputStream {
+				name := "request"
+				p.Name = &name
+			}
+			m.Params = append(m.Params, p)
+		}
+		if t, ok := c.methodType(s, r, r.output); ok {
+			m.Returns = append(m.Returns, &ast.MethodReturn{Type: t, Stream: r.outputStream})
+		}
+		svc.Methods = append(svc.Methods, m)
+	}
+	return svc
+}
+
+// methodType returns the type of a parameter or return value of a given rpc.
+// ok is false when no value should be declared, as is the case for
+// google.protobuf.Empty.
+func (c *converter) methodType(s *protoService, r *protoRPC, name string) (ast.Type, bool) {
+	if fqn, _ := c.im.resolve(c.f.pkg, name); fqn == emptyType {
+		return nil, false
+	}
+	t, ok := c.typeFor(c.f.pkg, name)
+	if !ok {
+		output.Warnf("Type %s used by %s.%s cannot be represented in arf and was omitted", name, s.name, r.name)
+	}
+	return t, ok
+}
@@ -0,0 +1,648 @@
+package proto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// protoFile represents the subset of a proto2/proto3 file that can be
+// translated into arf: options, extensions and custom options are parsed and
+// discarded.
+type protoFile struct {
+	path     string
+	syntax   string
+	pkg      string
+	imports  []string
+	messages []*protoMessage
+	enums    []*protoEnum
+	services []*protoService
+}
+
+type protoMessage struct {
+	line     int
+	name     string
+	comments []string
+	fields   []*protoField
+	messages []*protoMessage
+	enums    []*protoEnum
+	deprec   bool
+	reserved protoReserved
+}
+
+// protoReserved holds the tags or values, and names, a message or enum
+// reserves.
+type protoReserved struct {
+	ranges []reservedRange
+	names  []string
+}
+
+// reservedRange is an inclusive range of reserved tags or values. end is nil
+// for ranges extending to max.
+type reservedRange struct {
+	start int
+	end   *int
+}
+
+type protoField struct {
+	line     int
+	label    string
+	typ      string
+	mapKey   string
+	mapValue string
+	name     string
+	tag      int
+	comments []string
+	deprec   bool
+	// oneof indicates the field is a member of a oneof group, and therefore
+	// has presence.
+	oneof bool
+}
+
+type protoEnum struct {
+	line     int
+	name     string
+	comments []string
+	values   []*protoEnumValue
+	deprec   bool
+	reserved protoReserved
+}
+
+type protoEnumValue struct {
+	name     string
+	value    int
+	comments []string
+	deprec   bool
+}
+
+type protoService struct {
+	line     int
+	name     string
+	comments []string
+	rpcs     []*protoRPC
+	deprec   bool
+}
+
+type protoRPC struct {
+	name         string
+	comments     []string
+	input        string
+	inputStream  bool
+	output       string
+	outputStream bool
+	deprec       bool
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+	// comments holds comments immediately preceding the token.
+	comments []string
+}
+
+type lexer struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (l *lexer) tokens() ([]token, error) {
+	var toks []token
+	var comments []string
+	lastLine := 0
+	for {
+		// Skip whitespace, collecting comments. A blank line detaches
+		// comments from the following token, and comments placed on the
+		// same line as a previous token are ignored.
+		for l.pos < len(l.src) {
+			c := l.src[l.pos]
+			switch {
+			case c == '\n':
+				if l.pos > 0 && l.lineIsBlank() {
+					comments = nil
+				}
+				l.line++
+				l.pos++
+			case unicode.IsSpace(c):
+				l.pos++
+			case c == '/' && l.peek(1) == '/':
+				start := l.pos + 2
+				for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+					l.pos++
+				}
+				if l.line != lastLine || len(toks) == 0 {
+					comments = append(comments, string(l.src[start:l.pos]))
+				}
+			case c == '/' && l.peek(1) == '*':
+				start := l.pos + 2
+				sameLine := l.line == lastLine && len(toks) > 0
+				l.pos += 2
+				for l.pos < len(l.src) && !(l.src[l.pos] == '*' && l.peek(1) == '/') {
+					if l.src[l.pos] == '\n' {
+						l.line++
+					}
+					l.pos++
+				}
+				if l.pos >= len(l.src) {
+					return nil, fmt.Errorf("line %d: unterminated comment", l.line)
+				}
+				text := string(l.src[start:l.pos])
+				l.pos += 2
+				if !sameLine {
+					lines := strings.Split(text, "\n")
+					for i, line := range lines {
+						line = strings.TrimSpace(line)
+						line = strings.TrimPrefix(strings.TrimPrefix(line, "*"), " ")
+						// Skip the blank lines usually following /* and
+						// preceding */.
+						if line == "" && (i == 0 || i == len(lines)-1) {
+							continue
+						}
+						comments = append(comments, " "+line)
+					}
+				}
+			default:
+				goto lex
+			}
+		}
+	lex:
+		if l.pos >= len(l.src) {
+			toks = append(toks, token{kind: tokenEOF, line: l.line})
+			return toks, nil
+		}
+
+		t := token{line: l.line, comments: comments}
+		comments = nil
+		lastLine = l.line
+		c := l.src[l.pos]
+		start := l.pos
+		switch {
+		case unicode.IsLetter(c) || c == '_' || c == '.' && unicode.IsLetter(l.peek(1)):
+			l.pos++
+			for l.pos < len(l.src) && (unicode.IsLetter(l.src[l.pos]) || unicode.IsDigit(l.src[l.pos]) || l.src[l.pos] == '_' || l.src[l.pos] == '.') {
+				l.pos++
+			}
+			t.kind = tokenIdent
+		case unicode.IsDigit(c) || c == '-' && unicode.IsDigit(l.peek(1)):
+			l.pos++
+			for l.pos < len(l.src) && (unicode.IsLetter(l.src[l.pos]) || unicode.IsDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+				l.pos++
+			}
+			t.kind = tokenNumber
+		case c == '"' || c == '\'':
+			l.pos++
+			var sb strings.Builder
+			for l.pos < len(l.src) && l.src[l.pos] != c {
+				if l.src[l.pos] == '\\' && l.pos+1 < len(l.src) {
+					l.pos++
+				}
+				sb.WriteRune(l.src[l.pos])
+				l.pos++
+			}
+			if l.pos >= len(l.src) {
+				return nil, fmt.Errorf("line %d: unterminated string", l.line)
+			}
+			l.pos++
+			t.kind = tokenString
+			t.value = sb.String()
+			toks = append(toks, t)
+			continue
+		default:
+			l.pos++
+			t.kind = tokenSymbol
+		}
+		t.value = string(l.src[start:l.pos])
+		toks = append(toks, t)
+	}
+}
+
+func (l *lexer) peek(n int) rune {
+	if l.pos+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+n]
+}
+
+// lineIsBlank reports whether the line ending at the current position
+// contains only whitespace.
+func (l *lexer) lineIsBlank() bool {
+	for i := l.pos - 1; i >= 0 && l.src[i] != '\n'; i-- {
+		if !unicode.IsSpace(l.src[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+type parser struct {
+	toks []token
+	pos  int
+	path string
+}
+
+// parseProto parses the contents of a .proto file located at path.
+func parseProto(path string, src []byte) (f *protoFile, err error) {
+	l := &lexer{src: []rune(string(src)), line: 1}
+	toks, err := l.tokens()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p := &parser{toks: toks, path: path}
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			f, err = nil, perr
+		}
+	}()
+	return p.file(), nil
+}
+
+type parseError struct {
+	msg string
+}
+
+func (e parseError) Error() string { return e.msg }
+
+func (p *parser) fail(format string, args ...any) {
+	t := p.peek()
+	panic(parseError{msg: fmt.Sprintf("%s: line %d: %s", p.path, t.line, fmt.Sprintf(format, args...))})
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(value string) bool {
+	t := p.peek()
+	return (t.kind == tokenIdent || t.kind == tokenSymbol) && t.value == value
+}
+
+func (p *parser) expect(value string) token {
+	if !p.is(value) {
+		p.fail("expected `%s', found `%s'", value, p.peek().value)
+	}
+	return p.next()
+}
+
+func (p *parser) ident() string {
+	t := p.next()
+	if t.kind != tokenIdent {
+		p.fail("expected identifier, found `%s'", t.value)
+	}
+	return t.value
+}
+
+func (p *parser) number() int {
+	t := p.next()
+	if t.kind != tokenNumber {
+		p.fail("expected number, found `%s'", t.value)
+	}
+	v, err := strconv.ParseInt(t.value, 0, 64)
+	if err != nil {
+		p.fail("invalid number `%s'", t.value)
+	}
+	return int(v)
+}
+
+func (p *parser) str() string {
+	t := p.next()
+	if t.kind != tokenString {
+		p.fail("expected string, found `%s'", t.value)
+	}
+	return t.value
+}
+
+// skipStatement discards tokens up to the end of the current statement,
+// including any block it may contain.
+func (p *parser) skipStatement() {
+	depth := 0
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			p.fail("unexpected end of file")
+		case t.value == "{":
+			depth++
+		case t.value == "}":
+			depth--
+			if depth == 0 {
+				if p.is(";") {
+					p.next()
+				}
+				return
+			}
+		case t.value == ";" && depth == 0:
+			return
+		}
+	}
+}
+
+// options parses a field option list, such as [deprecated = true], and
+// reports whether the deprecated option is set.
+func (p *parser) options() (deprecated bool) {
+	if !p.is("[") {
+		return false
+	}
+	p.next()
+	for !p.is("]") {
+		name := p.next().value
+		if name == "(" {
+			p.skipBalanced("(", ")")
+			name = ""
+		}
+		for p.is(".") || p.peek().kind == tokenIdent && strings.HasPrefix(p.peek().value, ".") {
+			p.next()
+		}
+		p.expect("=")
+		value := p.next()
+		if value.value == "{" {
+			p.pos--
+			p.skipBalanced("{", "}")
+		}
+		if name == "deprecated" && value.value == "true" {
+			deprecated = true
+		}
+		if p.is(",") {
+			p.next()
+		}
+	}
+	p.expect("]")
+	return
+}
+
+func (p *parser) skipBalanced(open, close string) {
+	depth := 0
+	if p.is(open) {
+		p.next()
+	}
+	depth++
+	for depth > 0 {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			p.fail("unexpected end of file")
+		case t.value == open:
+			depth++
+		case t.value == close:
+			depth--
+		}
+	}
+}
+
+// optionDeprecated parses an option statement, and reports whether it sets
+// the deprecated option.
+func (p *parser) optionDeprecated() bool {
+	p.expect("option")
+	name := p.peek().value
+	p.skipStatement()
+	if name != "deprecated" {
+		return false
+	}
+	// Look behind for the assigned value.
+	return p.toks[p.pos-2].value == "true"
+}
+
+func (p *parser) file() *protoFile {
+	f := &protoFile{path: p.path, syntax: "proto2"}
+	for p.peek().kind != tokenEOF {
+		switch {
+		case p.is("syntax"), p.is("edition"):
+			p.next()
+			p.expect("=")
+			f.syntax = p.str()
+			p.expect(";")
+		case p.is("package"):
+			p.next()
+			f.pkg = p.ident()
+			p.expect(";")
+		case p.is("import"):
+			p.next()
+			if p.is("public") || p.is("weak") {
+				p.next()
+			}
+			f.imports = append(f.imports, p.str())
+			p.expect(";")
+		case p.is("message"):
+			f.messages = append(f.messages, p.message())
+		case p.is("enum"):
+			f.enums = append(f.enums, p.enum())
+		case p.is("service"):
+			f.services = append(f.services, p.service())
+		case p.is(";"):
+			p.next()
+		default:
+			p.skipStatement()
+		}
+	}
+	return f
+}
+
+func (p *parser) message() *protoMessage {
+	start := p.expect("message")
+	m := &protoMessage{line: start.line, name: p.ident(), comments: start.comments}
+	p.expect("{")
+	p.messageBody(m, false)
+	return m
+}
+
+func (p *parser) messageBody(m *protoMessage, oneof bool) {
+	for !p.is("}") {
+		switch {
+		case p.peek().kind == tokenEOF:
+			p.fail("unexpected end of file")
+		case p.is("message"):
+			m.messages = append(m.messages, p.message())
+		case p.is("enum"):
+			m.enums = append(m.enums, p.enum())
+		case p.is("oneof"):
+			p.next()
+			p.ident()
+			p.expect("{")
+			p.messageBody(m, true)
+		case p.is("option"):
+			if p.optionDeprecated() {
+				m.deprec = true
+			}
+		case p.is("reserved"):
+			p.reserved(&m.reserved)
+		case p.is("extensions"), p.is("extend"), p.is("group"):
+			p.skipStatement()
+		case p.is(";"):
+			p.next()
+		default:
+			m.fields = append(m.fields, p.field(oneof))
+		}
+	}
+	p.expect("}")
+}
+
+func (p *parser) field(oneof bool) *protoField {
+	start := p.peek()
+	f := &protoField{line: start.line, comments: start.comments, oneof: oneof}
+	if p.is("optional") || p.is("repeated") || p.is("required") {
+		f.label = p.next().value
+	}
+	if p.is("map") {
+		p.next()
+		p.expect("<")
+		f.mapKey = p.ident()
+		p.expect(",")
+		f.mapValue = p.ident()
+		p.expect(">")
+	} else {
+		f.typ = p.ident()
+	}
+	f.name = p.ident()
+	p.expect("=")
+	f.tag = p.number()
+	f.deprec = p.options()
+	p.expect(";")
+	return f
+}
+
+// reserved parses a reserved statement, adding the ranges and names it
+// declares to r.
+func (p *parser) reserved(r *protoReserved) {
+	p.expect("reserved")
+	for {
+		if p.peek().kind == tokenString {
+			r.names = append(r.names, p.str())
+		} else {
+			start := p.number()
+			rng := reservedRange{start: start, end: &start}
+			if p.is("to") {
+				p.next()
+				if p.is("max") {
+					p.next()
+					rng.end = nil
+				} else {
+					end := p.number()
+					rng.end = &end
+				}
+			}
+			r.ranges = append(r.ranges, rng)
+		}
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	p.expect(";")
+}
+
+func (p *parser) enum() *protoEnum {
+	start := p.expect("enum")
+	e := &protoEnum{line: start.line, name: p.ident(), comments: start.comments}
+	p.expect("{")
+	for !p.is("}") {
+		switch {
+		case p.peek().kind == tokenEOF:
+			p.fail("unexpected end of file")
+		case p.is("option"):
+			if p.optionDeprecated() {
+				e.deprec = true
+			}
+		case p.is("reserved"):
+			p.reserved(&e.reserved)
+		case p.is(";"):
+			p.next()
+		default:
+			t := p.peek()
+			v := &protoEnumValue{name: p.ident(), comments: t.comments}
+			p.expect("=")
+			v.value = p.number()
+			v.deprec = p.options()
+			p.expect(";")
+			e.values = append(e.values, v)
+		}
+	}
+	p.expect("}")
+	return e
+}
+
+func (p *parser) service() *protoService {
+	start := p.expect("service")
+	s := &protoService{line: start.line, name: p.ident(), comments: start.comments}
+	p.expect("{")
+	for !p.is("}") {
+		switch {
+		case p.peek().kind == tokenEOF:
+			p.fail("unexpected end of file")
+		case p.is("rpc"):
+			s.rpcs = append(s.rpcs, p.rpc())
+		case p.is("option"):
+			if p.optionDeprecated() {
+				s.deprec = true
+			}
+		case p.is(";"):
+			p.next()
+		default:
+			p.skipStatement()
+		}
+	}
+	p.expect("}")
+	return s
+}
+
+func (p *parser) rpc() *protoRPC {
+	start := p.expect("rpc")
+	r := &protoRPC{name: p.ident(), comments: start.comments}
+	p.expect("(")
+	if p.is("stream") && p.toks[p.pos+1].kind == tokenIdent {
+		p.next()
+		r.inputStream = true
+	}
+	r.input = p.ident()
+	p.expect(")")
+	p.expect("returns")
+	p.expect("(")
+	if p.is("stream") && p.toks[p.pos+1].kind == tokenIdent {
+		p.next()
+		r.outputStream = true
+	}
+	r.output = p.ident()
+	p.expect(")")
+	if p.is("{") {
+		p.next()
+		for !p.is("}") {
+			if p.peek().kind == tokenEOF {
+				p.fail("unexpected end of file")
+			}
+			if p.is("option") {
+				if p.optionDeprecated() {
+					r.deprec = true
+				}
+				continue
+			}
+			p.skipStatement()
+		}
+		p.expect("}")
+	}
+	if p.is(";") {
+		p.next()
+	}
+	return r
+}
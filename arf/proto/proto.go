@@ -0,0 +1,394 @@
+package proto
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/arfc/output"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"slices"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.Generator {
+	return &Generator{
+		t:    tree,
+		w:    &common.Writer{},
+		body: &common.Writer{},
+	}
+}
+
+type Generator struct {
+	t    *ast.PackageTree
+	w    *common.Writer
+	body *common.Writer
+
+	imports []string
+	// wrappers holds messages synthesized to carry parameters and return
+	// values of methods, which are emitted after services.
+	wrappers *common.Writer
+}
+
+// FileName returns the name of the .proto file generated for a given package.
+func FileName(pkg string) string {
+	return pkg + ".proto"
+}
+
+// TagOffset is added to arf field IDs to obtain protobuf tag numbers, since
+// protobuf reserves zero.
+const TagOffset = 1
+
+func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string) {
+	targetDir = ctx.String("output")
+	targetFile = FileName(g.t.Package)
+	g.wrappers = &common.Writer{}
+
+	for i := range g.t.Enums {
+		g.body.Break()
+		g.makeEnum(&g.t.Enums[i])
+	}
+
+	for i := range g.t.Structures {
+		g.body.Break()
+		g.makeMessage(&g.t.Structures[i])
+	}
+
+	for i := range g.t.Services {
+		g.body.Break()
+		g.makeService(&g.t.Services[i])
+	}
+
+	g.w.Writelnf("// Code generated by arfc. DO NOT EDIT.")
+	g.w.Writelnf("// Field tags are arf field IDs offset by %d.", TagOffset)
+	g.w.Break()
+	g.w.Writelnf(`syntax = "proto3";`)
+	g.w.Break()
+	g.w.Writelnf("package %s;", g.t.Package)
+	if len(g.imports) > 0 {
+		g.w.Break()
+		slices.Sort(g.imports)
+	}
+	for _, imp := range g.imports {
+		g.w.Writelnf("import %q;", imp)
+	}
+
+	data = []byte(g.w.String() + g.body.String() + g.wrappers.String())
+	return
+}
+
+func (g *Generator) requireImport(path string) {
+	if !slices.Contains(g.imports, path) {
+		g.imports = append(g.imports, path)
+	}
+}
+
+func writeComments(w *common.Writer, c []string, set ast.AnnotationSet) {
+	for _, l := range c {
+		w.Writelnf("//%s", l)
+	}
+	if ann := set.ByName("deprecated"); ann != nil && len(ann.Arguments) > 0 {
+		w.Writelnf("// Deprecated: %s", ann.Arguments[0])
+	}
+}
+
+func deprecatedOption(set ast.AnnotationSet) bool {
+	return set.ByName("deprecated") != nil
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	w := g.body
+	writeComments(w, e.Comment, e.Annotations)
+	w.Writelnf("enum %s {", e.Name)
+	w.IncreaseIndent()
+	if deprecatedOption(e.Annotations) {
+		w.Writelnf("option deprecated = true;")
+	}
+
+	seen := map[int]bool{}
+	aliased := false
+	for _, m := range e.Members {
+		if seen[m.Value] {
+			aliased = true
+		}
+		seen[m.Value] = true
+	}
+	if aliased {
+		w.Writelnf("option allow_alias = true;")
+	}
+	// Enum members share the scope of their enum, so they are prefixed by
+	// its name, as the style guide recommends. proto3 also requires the
+	// first member of an enum to be zero.
+	prefix := strcase.ToScreamingSnake(e.Name) + "_"
+	if !seen[0] {
+		w.Writelnf("%sUNSPECIFIED = 0;", prefix)
+	}
+	members := slices.Clone(e.Members)
+	slices.SortStableFunc(members, func(a, b ast.EnumMember) int {
+		if a.Value == 0 && b.Value != 0 {
+			return -1
+		}
+		if b.Value == 0 && a.Value != 0 {
+			return 1
+		}
+		return 0
+	})
+	for _, m := range members {
+		writeComments(w, m.Comment, m.Annotations)
+		name := prefix + strcase.ToScreamingSnake(m.Name)
+		if deprecatedOption(m.Annotations) {
+			w.Writelnf("%s = %d [deprecated = true];", name, m.Value)
+		} else {
+			w.Writelnf("%s = %d;", name, m.Value)
+		}
+	}
+	w.DecreaseIndent()
+	w.Writelnf("}")
+}
+
+func (g *Generator) makeMessage(s *ast.Struct) {
+	w := g.body
+	writeComments(w, s.Comment, s.Annotations)
+	w.Writelnf("message %s {", s.Name)
+	w.IncreaseIndent()
+	if deprecatedOption(s.Annotations) {
+		w.Writelnf("option deprecated = true;")
+	}
+
+	for i := range s.Enums {
+		g.makeEnum(&s.Enums[i])
+		w.Break()
+	}
+	for i := range s.Structs {
+		g.makeMessage(&s.Structs[i])
+		w.Break()
+	}
+
+	for _, f := range s.Fields {
+		writeComments(w, f.Comment, f.Annotations)
+		decl, ok := g.fieldType(f.Type)
+		if !ok {
+			output.Warnf("Field %s of %s cannot be represented in proto3 and was omitted", f.Name, s.FQN())
+			w.Writelnf("reserved %d;", f.ID+TagOffset)
+			continue
+		}
+		opts := ""
+		if deprecatedOption(f.Annotations) {
+			opts = " [deprecated = true]"
+		}
+		w.Writelnf("%s %s = %d%s;", decl, f.Name, f.ID+TagOffset, opts)
+	}
+	w.DecreaseIndent()
+	w.Writelnf("}")
+}
+
+// fieldType returns the declaration of a field of type t, including its
+// label. ok is false when t cannot be represented by a proto3 field.
+func (g *Generator) fieldType(t ast.Type) (decl string, ok bool) {
+	switch v := t.(type) {
+	case *ast.OptionalType:
+		switch v.Type.(type) {
+		case *ast.ArrayType, *ast.MapType, *ast.OptionalType:
+			// Repeated and map fields have no presence, so optional
+			// collections can only be represented as empty ones.
+			return g.fieldType(v.Type)
+		}
+		return "optional " + g.typeName(v.Type), true
+	case *ast.ArrayType:
+		switch v.Type.(type) {
+		case *ast.ArrayType, *ast.MapType, *ast.OptionalType:
+			return "", false
+		}
+		return "repeated " + g.typeName(v.Type), true
+	case *ast.MapType:
+		switch v.Value.(type) {
+		case *ast.ArrayType, *ast.MapType, *ast.OptionalType:
+			return "", false
+		}
+		key, ok := g.mapKey(v.Key)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("map<%s, %s>", key, g.typeName(v.Value)), true
+	default:
+		return g.typeName(t), true
+	}
+}
+
+func (g *Generator) mapKey(t ast.Type) (string, bool) {
+	p, ok := t.(*ast.PrimitiveType)
+	if !ok {
+		return "", false
+	}
+	switch p.Name {
+	case "float32", "float64", "bytes", "timestamp":
+		return "", false
+	}
+	return g.typeName(t), true
+}
+
+func (g *Generator) typeName(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "int8", "int16", "int32":
+			return "int32"
+		case "uint8", "uint16", "uint32":
+			return "uint32"
+		case "float32":
+			return "float"
+		case "float64":
+			return "double"
+		case "timestamp":
+			g.requireImport("google/protobuf/timestamp.proto")
+			return "google.protobuf.Timestamp"
+		default:
+			return v.Name
+		}
+	case *ast.SimpleUserType:
+		return g.userTypeName(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return g.userTypeName(v.ResolvedType)
+	default:
+		return "INVALID"
+	}
+}
+
+// userTypeName returns the fully-qualified name of a given struct or enum,
+// which is unambiguous regardless of the scope it is referenced from.
+func (g *Generator) userTypeName(obj ast.Object) string {
+	names := common.ObjectPath(obj)
+	if names == nil {
+		return "INVALID"
+	}
+	pkg := obj.Pos().File.Package.Value
+	if pkg != g.t.Package {
+		g.requireImport(FileName(pkg))
+	}
+	return "." + pkg + "." + strings.Join(names, ".")
+}
+
+func isMessage(t ast.Type) bool {
+	switch v := t.(type) {
+	case *ast.SimpleUserType:
+		_, ok := v.ResolvedType.(*ast.Struct)
+		return ok
+	case *ast.FullQualifiedType:
+		_, ok := v.ResolvedType.(*ast.Struct)
+		return ok
+	}
+	return false
+}
+
+type wrapperField struct {
+	name string
+	typ  ast.Type
+}
+
+// messageFor returns the message type used to transmit values and stream
+// items of a method. When values is composed of a single struct and no stream
+// is present (or the other way around), the struct is used directly.
+// Otherwise, a wrapper message named name is synthesized.
+func (g *Generator) messageFor(name string, values []wrapperField, stream ast.Type) string {
+	switch {
+	case len(values) == 0 && stream == nil:
+		g.requireImport("google/protobuf/empty.proto")
+		return "google.protobuf.Empty"
+	case len(values) == 1 && stream == nil && isMessage(values[0].typ):
+		return g.typeName(values[0].typ)
+	case len(values) == 0 && stream != nil && isMessage(stream):
+		return g.typeName(stream)
+	}
+
+	w := g.wrappers
+	w.Break()
+	w.Writelnf("message %s {", name)
+	w.IncreaseIndent()
+	if stream != nil && len(values) > 0 {
+		w.Writelnf("// Values are only set in the first message of the stream.")
+	}
+	tag := 1
+	for _, v := range values {
+		decl, ok := g.fieldType(v.typ)
+		if !ok {
+			output.Warnf("Value %s of %s cannot be represented in proto3 and was omitted", v.name, name)
+			tag++
+			continue
+		}
+		w.Writelnf("%s %s = %d;", decl, v.name, tag)
+		tag++
+	}
+	if stream != nil {
+		decl, ok := g.fieldType(stream)
+		if !ok {
+			output.Warnf("Stream items of %s cannot be represented in proto3 and were omitted", name)
+		} else {
+			w.Writelnf("%s item = %d;", decl, tag)
+		}
+	}
+	w.DecreaseIndent()
+	w.Writelnf("}")
+	return name
+}
+
+func (g *Generator) makeService(s *ast.Service) {
+	w := g.body
+	writeComments(w, s.Comment, s.Annotations)
+	w.Writelnf("service %s {", s.Name)
+	w.IncreaseIndent()
+	if deprecatedOption(s.Annotations) {
+		w.Writelnf("option deprecated = true;")
+	}
+
+	for _, m := range s.Methods {
+		prefix := strcase.ToCamel(s.Name) + strcase.ToCamel(m.Name)
+
+		var params []wrapperField
+		var inputStream ast.Type
+		for _, p := range m.Params {
+			if p.Stream {
+				inputStream = p.Type
+				continue
+			}
+			params = append(params, wrapperField{name: *p.Name, typ: p.Type})
+		}
+		var returns []wrapperField
+		var outputStream ast.Type
+		for _, r := range m.Returns {
+			if r.Stream {
+				outputStream = r.Type
+				continue
+			}
+			returns = append(returns, wrapperField{typ: r.Type})
+		}
+		for i := range returns {
+			returns[i].name = "result"
+			if len(returns) > 1 {
+				returns[i].name = fmt.Sprintf("result_%d", i)
+			}
+		}
+
+		req := g.messageFor(prefix+"Request", params, inputStream)
+		res := g.messageFor(prefix+"Response", returns, outputStream)
+		if inputStream != nil {
+			req = "stream " + req
+		}
+		if outputStream != nil {
+			res = "stream " + res
+		}
+
+		writeComments(w, m.Comment, m.Annotations)
+		if deprecatedOption(m.Annotations) {
+			w.Writelnf("rpc %s(%s) returns (%s) {", m.Name, req, res)
+			w.IncreaseIndent()
+			w.Writelnf("option deprecated = true;")
+			w.DecreaseIndent()
+			w.Writelnf("}")
+		} else {
+			w.Writelnf("rpc %s(%s) returns (%s);", m.Name, req, res)
+		}
+	}
+
+	w.DecreaseIndent()
+	w.Writelnf("}")
+}
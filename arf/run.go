@@ -3,14 +3,26 @@ package arf
 import (
 	"fmt"
 	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/cpp"
+	"github.com/arf-rpc/arfc/arf/csharp"
+	"github.com/arf-rpc/arfc/arf/dart"
+	"github.com/arf-rpc/arfc/arf/docs"
+	"github.com/arf-rpc/arfc/arf/elixir"
 	"github.com/arf-rpc/arfc/arf/golang"
+	"github.com/arf-rpc/arfc/arf/jsonschema"
+	"github.com/arf-rpc/arfc/arf/openapi"
+	"github.com/arf-rpc/arfc/arf/php"
+	"github.com/arf-rpc/arfc/arf/proto"
 	"github.com/arf-rpc/arfc/arf/ruby"
+	"github.com/arf-rpc/arfc/arf/swift"
 	"github.com/arf-rpc/arfc/output"
 	"github.com/arf-rpc/idl"
 	"github.com/arf-rpc/idl/ast"
 	"github.com/urfave/cli/v2"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -20,7 +32,50 @@ type outputFile struct {
 	outputFile string
 }
 
+// languageFlags lists flags that only affect a single output language, keyed
+// by the canonical name of that language.
+var languageFlags = map[string][]string{
+	"ruby":   {"ruby-flat", "ruby-module"},
+	"go":     {"golang-package", "go-module", "go-legacy-enum-constants", "go-validate-params", "go-getters", "go-mocks", "go-local-clients", "go-require-unimplemented"},
+	"csharp": {"csharp-namespace"},
+	"elixir": {"elixir-module"},
+	"docs":   {"docs-format"},
+	"php":    {"php-namespace"},
+}
+
+// warnForeignFlags emits a warning for each flag provided by the user that
+// belongs to a language other than lang.
+func warnForeignFlags(c *cli.Context, lang, displayName string) {
+	for _, l := range slices.Sorted(maps.Keys(languageFlags)) {
+		if l == lang {
+			continue
+		}
+		for _, f := range languageFlags[l] {
+			if c.IsSet(f) {
+				output.Warnf("Providing %s with lang %s has no effect", f, displayName)
+			}
+		}
+	}
+}
+
 func Run(c *cli.Context) error {
+	// Flags are not marked as required, since subcommands do not take them.
+	// lang and output may be omitted when only a descriptor is requested.
+	required := []string{"lang", "input", "output"}
+	descriptorOnly := c.IsSet("descriptor-out") && !c.IsSet("lang") && !c.IsSet("output")
+	if descriptorOnly {
+		required = []string{"input"}
+	}
+	var missing []string
+	for _, f := range required {
+		if !c.IsSet(f) {
+			missing = append(missing, `"`+f+`"`)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Required flags %s not set", strings.Join(missing, ", "))
+	}
+
 	inputArg := c.String("input")
 
 	errored := false
@@ -35,33 +90,73 @@ func Run(c *cli.Context) error {
 		return fmt.Errorf("Error parsing input: %w\n", err)
 	}
 
+	if c.IsSet("descriptor-out") {
+		if err = writeDescriptor(c, fs, inputArg); err != nil {
+			return fmt.Errorf("Failed writing descriptor: %w", err)
+		}
+		if descriptorOnly {
+			return nil
+		}
+	}
+
 	rawLang := c.String("lang")
 	lang := strings.ToLower(rawLang)
 	var makeGen func(tree *ast.PackageTree) common.Generator
+	var makeMultiGen func(tree *ast.PackageTree) common.MultiFileGenerator
 
 	switch lang {
 	case "ruby":
-		if c.IsSet("golang-package") {
-			output.Warnf("Providing golang-package with lang Ruby has no effect")
-		}
-		if c.IsSet("golang-module") {
-			output.Warnf("Providing golang-module with lang Ruby has no effect")
-		}
+		warnForeignFlags(c, "ruby", "Ruby")
 		makeGen = ruby.NewGenerator
 	case "go", "golang":
-		if c.IsSet("ruby-module") {
-			output.Warnf("Providing ruby-module with lang Goland has no effect")
-		}
-		if c.IsSet("ruby-flat") {
-			output.Warnf("Providing ruby-flat with lang Goland has no effect")
-		}
-		makeGen = golang.NewGenerator
+		warnForeignFlags(c, "go", "Golang")
+		makeMultiGen = golang.NewGenerator
+	case "swift":
+		warnForeignFlags(c, "swift", "Swift")
+		makeMultiGen = swift.NewGenerator
+	case "csharp", "c#":
+		warnForeignFlags(c, "csharp", "C#")
+		makeMultiGen = csharp.NewGenerator
+	case "elixir":
+		warnForeignFlags(c, "elixir", "Elixir")
+		makeMultiGen = elixir.NewGenerator
+	case "dart":
+		warnForeignFlags(c, "dart", "Dart")
+		makeMultiGen = dart.NewGenerator
+	case "cpp", "c++":
+		warnForeignFlags(c, "cpp", "C++")
+		makeMultiGen = cpp.NewGenerator
+	case "php":
+		warnForeignFlags(c, "php", "PHP")
+		makeMultiGen = php.NewGenerator
+	case "jsonschema":
+		warnForeignFlags(c, "jsonschema", "JSON Schema")
+		makeMultiGen = jsonschema.NewGenerator
+	case "openapi":
+		warnForeignFlags(c, "openapi", "OpenAPI")
+		makeGen = openapi.NewGenerator
+	case "proto", "protobuf":
+		warnForeignFlags(c, "proto", "Protocol Buffers")
+		makeGen = proto.NewGenerator
+	case "docs":
+		warnForeignFlags(c, "docs", "docs")
+		makeGen = docs.NewGenerator
 	default:
-		output.Errorf("unknown output language `%s': only 'go'/'golang', and 'ruby' are supported.", lang)
+		output.Errorf("unknown output language `%s': only 'go'/'golang', 'ruby', 'swift', 'csharp', 'elixir', 'dart', 'cpp', 'php', 'jsonschema', 'openapi', 'proto', and 'docs' are supported.", lang)
 	}
 	var outputs []*outputFile
 
 	for _, t := range fs.Packages {
+		if makeMultiGen != nil {
+			for _, f := range makeMultiGen(t).GenFiles(c) {
+				outputs = append(outputs, &outputFile{
+					data:       f.Data,
+					outputDir:  f.TargetDir,
+					outputFile: f.TargetFile,
+				})
+			}
+			continue
+		}
 		gen := makeGen(t)
 		data, targetDir, targetFile := gen.GenFile(c)
 		outputs = append(outputs, &outputFile{
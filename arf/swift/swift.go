@@ -0,0 +1,511 @@
+package swift
+
+import (
+	"fmt"
+	"github.com/arf-rpc/arfc/arf/common"
+	"github.com/arf-rpc/arfc/arf/strcase"
+	"github.com/arf-rpc/idl/ast"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+func NewGenerator(tree *ast.PackageTree) common.MultiFileGenerator {
+	return &Generator{
+		t: tree,
+		w: &common.Writer{},
+	}
+}
+
+type Generator struct {
+	t *ast.PackageTree
+	w *common.Writer
+}
+
+var keywords = map[string]struct{}{
+	"associatedtype": {}, "class": {}, "deinit": {}, "enum": {}, "extension": {},
+	"fileprivate": {}, "func": {}, "import": {}, "init": {}, "inout": {},
+	"internal": {}, "let": {}, "open": {}, "operator": {}, "private": {},
+	"protocol": {}, "public": {}, "rethrows": {}, "static": {}, "struct": {},
+	"subscript": {}, "typealias": {}, "var": {}, "break": {}, "case": {},
+	"continue": {}, "default": {}, "defer": {}, "do": {}, "else": {},
+	"fallthrough": {}, "for": {}, "guard": {}, "if": {}, "in": {},
+	"repeat": {}, "return": {}, "switch": {}, "where": {}, "while": {},
+	"as": {}, "catch": {}, "false": {}, "is": {}, "nil": {}, "self": {},
+	"Self": {}, "super": {}, "throw": {}, "throws": {}, "true": {}, "try": {},
+	"Type": {}, "actor": {}, "async": {}, "await": {},
+}
+
+func escape(name string) string {
+	if _, ok := keywords[name]; ok {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+// Namespace returns the name of the caseless enum used to scope all types
+// generated for a given arf package.
+func Namespace(pkg string) string {
+	return strcase.ToCamel(pkg)
+}
+
+// transportFile is the name of the file declaring the protocols generated
+// clients make calls through. It is the same for all packages, and written
+// alongside each of them.
+const transportFile = "ArfTransport.arf.swift"
+
+// transport declares the protocols generated clients make calls through,
+// which applications implement over the network stack of their choice, along
+// with ArfIndirect, used by recursive structs.
+const transport = `// Code generated by arfc. DO NOT EDIT.
+
+import Foundation
+
+/// ArfTransport carries calls made by generated clients to arf services.
+public protocol ArfTransport: Sendable {
+    /// Starts a call to a method of the service identified by service, with
+    /// the provided parameters. Calls to methods taking an input stream are
+    /// started with streaming set.
+    func call(service: String, method: String, params: [any Encodable & Sendable], streaming: Bool) async throws -> any ArfCall
+}
+
+/// ArfCall is a call started through an ArfTransport.
+public protocol ArfCall: Sendable {
+    /// Sends the items of stream to the service, for methods taking an input
+    /// stream.
+    func send<T: Encodable & Sendable>(_ stream: AsyncStream<T>)
+
+    /// Waits for the service to respond, returning the values it responded
+    /// with.
+    func result() async throws -> any ArfResult
+
+    /// Returns the items streamed by the service, for methods returning a
+    /// stream.
+    func responses<T: Decodable & Sendable>(of type: T.Type) -> AsyncThrowingStream<T, Error>
+}
+
+/// ArfResult holds the values a service responded with.
+public protocol ArfResult: Sendable {
+    /// Decodes the value at index as type.
+    func decode<T: Decodable>(_ type: T.Type, at index: Int) throws -> T
+}
+
+/// ArfIndirect stores optional fields referring back to the struct declaring
+/// them out of line, as Swift does not allow recursive value types.
+@propertyWrapper
+public struct ArfIndirect<Value: Sendable>: Sendable {
+    private final class Storage: Sendable {
+        let value: Value
+
+        init(_ value: Value) {
+            self.value = value
+        }
+    }
+
+    private var storage: Storage
+
+    public init(wrappedValue: Value) {
+        storage = Storage(wrappedValue)
+    }
+
+    public var wrappedValue: Value {
+        get { storage.value }
+        set { storage = Storage(newValue) }
+    }
+}
+
+extension ArfIndirect: Codable where Value: Codable {
+    public init(from decoder: Decoder) throws {
+        self.init(wrappedValue: try decoder.singleValueContainer().decode(Value.self))
+    }
+
+    public func encode(to encoder: Encoder) throws {
+        var container = encoder.singleValueContainer()
+        try container.encode(wrappedValue)
+    }
+}
+
+extension KeyedDecodingContainer {
+    /// Decodes an absent field stored through ArfIndirect as nil, as done for
+    /// other optional fields.
+    public func decode<T: Codable & Sendable>(_ type: ArfIndirect<T?>.Type, forKey key: Key) throws -> ArfIndirect<T?> {
+        ArfIndirect(wrappedValue: try decodeIfPresent(T.self, forKey: key))
+    }
+}
+
+extension KeyedEncodingContainer {
+    /// Omits a nil field stored through ArfIndirect, as done for other
+    /// optional fields.
+    public mutating func encode<T: Codable & Sendable>(_ value: ArfIndirect<T?>, forKey key: Key) throws {
+        try encodeIfPresent(value.wrappedValue, forKey: key)
+    }
+}
+`
+
+// GenFiles generates the source file of the package, along with the file
+// declaring ArfTransport.
+func (g *Generator) GenFiles(ctx *cli.Context) []common.File {
+	data, targetDir, targetFile := g.GenFile(ctx)
+	return []common.File{
+		{Data: data, TargetDir: targetDir, TargetFile: targetFile},
+		{Data: []byte(transport), TargetDir: targetDir, TargetFile: transportFile},
+	}
+}
+
+func (g *Generator) GenFile(ctx *cli.Context) (data []byte, targetDir string, targetFile string) {
+	ns := Namespace(g.t.Package)
+	targetDir = ctx.String("output")
+	targetFile = ns + ".arf.swift"
+
+	g.w.Writelnf("// Code generated by arfc. DO NOT EDIT.")
+	g.w.Break()
+	g.w.Writelnf("import Foundation")
+	g.w.Break()
+	g.w.Writelnf("public enum %s {", ns)
+	g.w.IncreaseIndent()
+
+	for _, e := range g.t.Enums {
+		g.makeEnum(&e)
+	}
+
+	for _, s := range g.t.Structures {
+		g.makeStruct(&s)
+	}
+
+	for _, s := range g.t.Services {
+		g.makeClient(&s)
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+
+	data = []byte(g.w.String())
+	return
+}
+
+func (g *Generator) writeComments(c []string) {
+	g.w.Break()
+	for _, c := range c {
+		g.w.Writelnf("///%s", c)
+	}
+}
+
+func (g *Generator) writeDeprecation(set ast.AnnotationSet) {
+	ann := set.ByName("deprecated")
+	if ann == nil {
+		return
+	}
+	if len(ann.Arguments) == 0 {
+		g.w.Writelnf("@available(*, deprecated)")
+		return
+	}
+	g.w.Writelnf("@available(*, deprecated, message: %q)", fmt.Sprint(ann.Arguments[0]))
+}
+
+func (g *Generator) makeStruct(s *ast.Struct) {
+	g.writeComments(s.Comment)
+	g.writeDeprecation(s.Annotations)
+	name := strcase.ToCamel(s.Name)
+	g.w.Writelnf("public struct %s: Codable, Sendable {", name)
+	g.w.IncreaseIndent()
+	g.w.Writelnf("public static let arfStructID = %q", common.CanonicalStructName(g.t.Package, s))
+
+	if len(s.Fields) > 0 {
+		g.w.Break()
+	}
+	for _, f := range s.Fields {
+		for _, c := range f.Comment {
+			g.w.Writelnf("///%s", c)
+		}
+		g.writeDeprecation(f.Annotations)
+		if indirect(s, f.Type) {
+			g.w.Writelnf("@ArfIndirect")
+		}
+		g.w.Writelnf("public var %s: %s", escape(strcase.ToLowerCamel(f.Name)), g.convertType(f.Type))
+	}
+
+	g.w.Break()
+	g.w.Writef("public init(")
+	for i, f := range s.Fields {
+		if i != 0 {
+			g.w.Writef(", ")
+		}
+		g.w.Writef("%s: %s = %s", escape(strcase.ToLowerCamel(f.Name)), g.convertType(f.Type), g.zeroValue(f.Type))
+	}
+	g.w.Writelnf(") {")
+	g.w.IncreaseIndent()
+	for _, f := range s.Fields {
+		name := strcase.ToLowerCamel(f.Name)
+		g.w.Writelnf("self.%s = %s", name, escape(name))
+	}
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+
+	if len(s.Fields) > 0 {
+		g.w.Break()
+		g.w.Writelnf("enum CodingKeys: Int, CodingKey {")
+		g.w.IncreaseIndent()
+		for _, f := range s.Fields {
+			g.w.Writelnf("case %s = %d", escape(strcase.ToLowerCamel(f.Name)), f.ID)
+		}
+		g.w.DecreaseIndent()
+		g.w.Writelnf("}")
+	}
+
+	for _, st := range s.Structs {
+		g.makeStruct(&st)
+	}
+
+	for _, e := range s.Enums {
+		g.makeEnum(&e)
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func (g *Generator) makeEnum(e *ast.Enum) {
+	g.writeComments(e.Comment)
+	g.writeDeprecation(e.Annotations)
+	name := strcase.ToCamel(e.Name)
+	g.w.Writelnf("public enum %s: Int, Codable, Sendable, CaseIterable {", name)
+	g.w.IncreaseIndent()
+
+	// Swift does not allow two cases sharing the same raw value, so members
+	// reusing a value are emitted as aliases to the first one declared.
+	declared := map[int]string{}
+	var aliases []ast.EnumMember
+	for _, v := range e.Members {
+		if _, ok := declared[v.Value]; ok {
+			aliases = append(aliases, v)
+			continue
+		}
+		memberName := escape(strcase.ToLowerCamel(v.Name))
+		declared[v.Value] = memberName
+		for _, c := range v.Comment {
+			g.w.Writelnf("///%s", c)
+		}
+		g.writeDeprecation(v.Annotations)
+		g.w.Writelnf("case %s = %d", memberName, v.Value)
+	}
+
+	for _, v := range aliases {
+		for _, c := range v.Comment {
+			g.w.Writelnf("///%s", c)
+		}
+		g.writeDeprecation(v.Annotations)
+		g.w.Writelnf("public static let %s = %s.%s", escape(strcase.ToLowerCamel(v.Name)), name, declared[v.Value])
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func (g *Generator) makeClient(s *ast.Service) {
+	g.writeComments(s.Comment)
+	g.writeDeprecation(s.Annotations)
+	g.w.Writelnf("public actor %sClient {", strcase.ToCamel(s.Name))
+	g.w.IncreaseIndent()
+	g.w.Writelnf("public static let serviceID = %q", fmt.Sprintf("%s/%s", g.t.Package, s.Name))
+	g.w.Break()
+	g.w.Writelnf("private let transport: any ArfTransport")
+	g.w.Break()
+	g.w.Writelnf("public init(transport: any ArfTransport) {")
+	g.w.IncreaseIndent()
+	g.w.Writelnf("self.transport = transport")
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+
+	for _, m := range s.Methods {
+		g.makeClientMethod(m)
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+func (g *Generator) makeClientMethod(m *ast.ServiceMethod) {
+	var (
+		params       []string
+		paramNames   []string
+		outputs      []string
+		inputStream  ast.Type
+		outputStream ast.Type
+	)
+
+	for _, p := range m.Params {
+		if p.Stream {
+			inputStream = p.Type
+			continue
+		}
+		name := escape(strcase.ToLowerCamel(*p.Name))
+		params = append(params, fmt.Sprintf("%s: %s", name, g.convertType(p.Type)))
+		paramNames = append(paramNames, name)
+	}
+	if inputStream != nil {
+		params = append(params, fmt.Sprintf("stream: AsyncStream<%s>", g.convertType(inputStream)))
+	}
+
+	for _, r := range m.Returns {
+		if r.Stream {
+			outputStream = r.Type
+			continue
+		}
+		outputs = append(outputs, g.convertType(r.Type))
+	}
+
+	returnTypes := append([]string{}, outputs...)
+	if outputStream != nil {
+		returnTypes = append(returnTypes, fmt.Sprintf("AsyncThrowingStream<%s, Error>", g.convertType(outputStream)))
+	}
+
+	g.writeComments(m.Comment)
+	g.writeDeprecation(m.Annotations)
+	g.w.Writef("public func %s(%s) async throws", escape(strcase.ToLowerCamel(m.Name)), strings.Join(params, ", "))
+	switch len(returnTypes) {
+	case 0:
+	case 1:
+		g.w.Writef(" -> %s", returnTypes[0])
+	default:
+		g.w.Writef(" -> (%s)", strings.Join(returnTypes, ", "))
+	}
+	g.w.Writelnf(" {")
+	g.w.IncreaseIndent()
+
+	g.w.Writelnf("let call = try await transport.call(service: Self.serviceID, method: %q, params: [%s], streaming: %t)",
+		m.Name, strings.Join(paramNames, ", "), inputStream != nil)
+	if inputStream != nil {
+		g.w.Writelnf("call.send(stream)")
+	}
+
+	var results []string
+	if len(outputs) > 0 {
+		g.w.Writelnf("let result = try await call.result()")
+		for i, o := range outputs {
+			results = append(results, fmt.Sprintf("try result.decode(%s.self, at: %d)", o, i))
+		}
+	} else if outputStream == nil {
+		g.w.Writelnf("_ = try await call.result()")
+	}
+	if outputStream != nil {
+		results = append(results, fmt.Sprintf("call.responses(of: %s.self)", g.convertType(outputStream)))
+	}
+
+	switch len(results) {
+	case 0:
+	case 1:
+		g.w.Writelnf("return %s", results[0])
+	default:
+		g.w.Writelnf("return (%s)", strings.Join(results, ", "))
+	}
+
+	g.w.DecreaseIndent()
+	g.w.Writelnf("}")
+}
+
+// typePath returns the dotted path to a user type, relative to the
+// namespace of the package being generated.
+func (g *Generator) typePath(obj ast.Object) string {
+	var name, pkg string
+	switch v := obj.(type) {
+	case *ast.Struct:
+		name, pkg = v.Name, v.Position.File.Package.Value
+	case *ast.Enum:
+		name, pkg = v.Name, v.Position.File.Package.Value
+	default:
+		return "INVALID"
+	}
+	names := common.ObjectPath(obj)
+	if names == nil {
+		names = []string{name}
+	}
+	for i, n := range names {
+		names[i] = strcase.ToCamel(n)
+	}
+	if pkg != g.t.Package {
+		names = append([]string{Namespace(pkg)}, names...)
+	}
+	return strings.Join(names, ".")
+}
+
+// indirect reports whether a field of s with type t is stored through
+// ArfIndirect, as is the case for optional structs referring back to s,
+// which would otherwise make s a recursive value type.
+func indirect(s *ast.Struct, t ast.Type) bool {
+	opt, ok := t.(*ast.OptionalType)
+	if !ok || !common.IsUserType(opt.Type) {
+		return false
+	}
+	return common.RefersTo(opt.Type, s)
+}
+
+func (g *Generator) convertType(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			return "String"
+		case "bool":
+			return "Bool"
+		case "float32":
+			return "Float"
+		case "float64":
+			return "Double"
+		case "bytes":
+			return "Data"
+		case "timestamp":
+			return "Date"
+		default:
+			// int8..int64, uint8..uint64
+			return strings.Replace(strcase.ToCamel(v.Name), "Uint", "UInt", 1)
+		}
+	case *ast.OptionalType:
+		return g.convertType(v.Type) + "?"
+	case *ast.ArrayType:
+		return "[" + g.convertType(v.Type) + "]"
+	case *ast.MapType:
+		return fmt.Sprintf("[%s: %s]", g.convertType(v.Key), g.convertType(v.Value))
+	case *ast.SimpleUserType:
+		return g.typePath(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return g.typePath(v.ResolvedType)
+	default:
+		return "INVALID"
+	}
+}
+
+func (g *Generator) zeroValue(t ast.Type) string {
+	switch v := t.(type) {
+	case *ast.PrimitiveType:
+		switch v.Name {
+		case "string":
+			return `""`
+		case "bool":
+			return "false"
+		case "bytes":
+			return "Data()"
+		case "timestamp":
+			return "Date(timeIntervalSince1970: 0)"
+		default:
+			return "0"
+		}
+	case *ast.OptionalType:
+		return "nil"
+	case *ast.ArrayType:
+		return "[]"
+	case *ast.MapType:
+		return "[:]"
+	case *ast.SimpleUserType:
+		return g.userZeroValue(v.ResolvedType)
+	case *ast.FullQualifiedType:
+		return g.userZeroValue(v.ResolvedType)
+	default:
+		return "INVALID"
+	}
+}
+
+func (g *Generator) userZeroValue(obj ast.Object) string {
+	if e, ok := obj.(*ast.Enum); ok {
+		return fmt.Sprintf("%s.%s", g.typePath(e), escape(strcase.ToLowerCamel(e.Members[0].Name)))
+	}
+	return g.typePath(obj) + "()"
+}
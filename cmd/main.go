@@ -15,23 +15,20 @@ func main() {
 		Description: "Compiles arf idl files into source files",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:     "lang",
-				Usage:    "The destination language",
-				Required: true,
-				Aliases:  []string{"l"},
+				Name:    "lang",
+				Usage:   "The destination language",
+				Aliases: []string{"l"},
 			},
 			&cli.StringFlag{
 				Name:      "input",
 				Usage:     "The input file to generate sources from",
-				Required:  true,
 				TakesFile: true,
 				Aliases:   []string{"i"},
 			},
 			&cli.StringFlag{
-				Name:     "output",
-				Usage:    "The output directory to write to",
-				Required: true,
-				Aliases:  []string{"o"},
+				Name:    "output",
+				Usage:   "The output directory to write to",
+				Aliases: []string{"o"},
 			},
 			&cli.BoolFlag{
 				Name: "ruby-flat",
@@ -53,14 +50,205 @@ func main() {
 			},
 			&cli.StringFlag{
 				Name: "go-module",
-				Usage: "When lang is set to \"go\", sets the base module name to be used in generated code. When unset, " +
-					"the compiler will try to locate a go.mod file in the output directory, and take the value from it. " +
-					"If unset, and no go module is present in the output directory, up to the disk root, the compiler " +
-					"emits an error and exits.",
+				Usage: "When lang is set to \"go\", sets the import path of the output directory, used to import " +
+					"packages generated for other arf packages. When unset, the compiler locates the closest go.mod in " +
+					"the directory of each generated package or any of its parents, and computes import paths relative " +
+					"to its root, emitting an error if none is found.",
 				Category: "Go",
 			},
+			&cli.BoolFlag{
+				Name: "go-legacy-enum-constants",
+				Usage: "When lang is set to \"go\", names enum constants after their members as declared in the idl " +
+					"(e.g. ACTIVE), instead of prefixing them with the name of their enum (e.g. StatusActive)",
+				Category: "Go",
+			},
+			&cli.BoolFlag{
+				Name: "go-validate-params",
+				Usage: "When lang is set to \"go\", makes generated services call Validate on incoming struct " +
+					"parameters, responding with an invalid argument status when constraints are not met",
+				Category: "Go",
+			},
+			&cli.BoolFlag{
+				Name: "go-getters",
+				Usage: "When lang is set to \"go\", generates a GetX method for each struct field, returning its " +
+					"value, or the zero value when called on a nil struct or when the field is absent",
+				Category: "Go",
+			},
+			&cli.BoolFlag{
+				Name: "go-mocks",
+				Usage: "When lang is set to \"go\", generates an interface satisfied by each service client, and a " +
+					"<package>mock package holding configurable fakes of services and clients",
+				Category: "Go",
+			},
+			&cli.BoolFlag{
+				Name: "go-local-clients",
+				Usage: "When lang is set to \"go\", generates an XLocalClient for each service X, " +
+					"calling an implementation in-process through the executors registered by RegisterX",
+				Category: "Go",
+			},
+			&cli.BoolFlag{
+				Name: "go-require-unimplemented",
+				Usage: "When lang is set to \"go\", makes RegisterX only accept implementations of service X " +
+					"embedding UnimplementedX, so methods added to X do not break them",
+				Category: "Go",
+			},
+			&cli.StringSliceFlag{
+				Name: "csharp-namespace",
+				Usage: "When lang is set to \"csharp\", overrides the generated namespace for a given package. Must " +
+					"be in the format some.package.name=Some.Namespace",
+				Category: "C#",
+			},
+			&cli.StringSliceFlag{
+				Name: "elixir-module",
+				Usage: "When lang is set to \"elixir\", overrides the generated module name for a given package. Must " +
+					"be in the format some.package.name=Module.Name",
+				Category: "Elixir",
+			},
+			&cli.StringSliceFlag{
+				Name: "php-namespace",
+				Usage: "When lang is set to \"php\", overrides the generated namespace for a given package. Must " +
+					"be in the format some.package.name=Some\\Namespace",
+				Category: "PHP",
+			},
+			&cli.StringFlag{
+				Name:     "docs-format",
+				Usage:    "When lang is set to \"docs\", defines the format of generated pages. Must be either markdown or html",
+				Value:    "markdown",
+				Category: "Docs",
+			},
+			&cli.StringFlag{
+				Name: "descriptor-out",
+				Usage: "Writes a descriptor of all packages, types and services resolved from the input to the " +
+					"provided file. When set, lang and output may be omitted",
+				TakesFile: true,
+				Category:  "Descriptor",
+			},
+			&cli.StringFlag{
+				Name: "descriptor-format",
+				Usage: "The format of the descriptor written through descriptor-out. Must be either json or binary. " +
+					"Defaults to json for files with the .json extension, and binary otherwise",
+				Category: "Descriptor",
+			},
 		},
 		Action: arf.Run,
+		Commands: []*cli.Command{
+			{
+				Name:      "import-proto",
+				Usage:     "Converts .proto files into arf idl files",
+				ArgsUsage: "FILE...",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Usage:   "The output directory to write to, or - to write to the standard output",
+						Value:   ".",
+						Aliases: []string{"o"},
+					},
+					&cli.StringSliceFlag{
+						Name:    "proto-path",
+						Usage:   "A directory in which to search for imports. Output files mirror their paths relative to it",
+						Aliases: []string{"I"},
+					},
+				},
+				Action: arf.ImportProto,
+			},
+			{
+				Name:      "fmt",
+				Usage:     "Formats idl files in a canonical layout",
+				ArgsUsage: "FILE|DIR...",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "write",
+						Usage:   "Writes the result to the source file instead of the standard output",
+						Aliases: []string{"w"},
+					},
+					&cli.BoolFlag{
+						Name:    "list",
+						Usage:   "Lists files whose formatting differs from the canonical layout",
+						Aliases: []string{"l"},
+					},
+					&cli.BoolFlag{
+						Name:  "check",
+						Usage: "Lists files that are not formatted, and exits with a non-zero status if any",
+					},
+				},
+				Action: arf.Fmt,
+			},
+			{
+				Name:      "breaking",
+				Usage:     "Reports wire-breaking and source-breaking changes between two versions of an idl file",
+				ArgsUsage: "FILE",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name: "against",
+						Usage: "The previous version of the file, or a directory (such as a checkout of a previous " +
+							"revision) containing it under the same relative path",
+						Required:  true,
+						TakesFile: true,
+					},
+					&cli.BoolFlag{
+						Name:  "wire-only",
+						Usage: "Only reports changes affecting compatibility between peers",
+					},
+				},
+				Action: arf.Breaking,
+			},
+			{
+				Name:      "lint",
+				Usage:     "Checks idl files against a configurable set of rules",
+				ArgsUsage: "FILE|DIR...",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name: "config",
+						Usage: "A JSON file configuring the severity of each rule. Defaults to arflint.json in the " +
+							"current directory, when present",
+						TakesFile: true,
+					},
+					&cli.StringSliceFlag{
+						Name:  "rule",
+						Usage: "Overrides the severity of a rule. Must be in the format rule-name=off|warning|error",
+					},
+					&cli.BoolFlag{
+						Name:  "list-rules",
+						Usage: "Lists available rules along with their default severity",
+					},
+				},
+				Action: arf.Lint,
+			},
+			{
+				Name: "lsp",
+				Usage: "Runs a Language Server over the standard input and output, providing diagnostics, " +
+					"navigation, completion and formatting for idl files to editors",
+				Action: arf.LanguageServer,
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Prints or queries a descriptor written through --descriptor-out, or an idl file",
+				ArgsUsage: "FILE",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "type",
+						Usage: "Prints a single struct, enum or service, given its full name or a suffix of it",
+					},
+					&cli.StringFlag{
+						Name:  "uses",
+						Usage: "Lists fields and methods referencing a given struct or enum",
+					},
+					&cli.StringFlag{
+						Name:  "streams",
+						Usage: "Lists methods streaming a given struct or enum, either as input or output",
+					},
+					&cli.BoolFlag{
+						Name:  "methods",
+						Usage: "Lists all methods along with their signatures",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Prints the descriptor, or the type selected through --type, as JSON",
+					},
+				},
+				Action: arf.Inspect,
+			},
+		},
 		Authors: []*cli.Author{
 			{Name: "Vito Sartori", Email: "hey@vito.io"},
 		},
@@ -68,6 +256,7 @@ func main() {
 		Suggest:   true,
 	}
 	if err := app.Run(os.Args); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }